@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+	"url-crawler-backend/internal/crawler"
+	"url-crawler-backend/internal/logging"
+	"url-crawler-backend/internal/model"
+)
+
+const usage = `Usage: crawl [flags] [url ...]
+
+Crawls each URL, without the server or a database, and prints what it
+found. URLs without a scheme get https://. With no arguments the URLs are
+read from standard input, one per line; blank lines and lines starting
+with # are skipped.
+
+The exit status is 1 if a crawl fails or a page has more broken links than
+-max-broken allows, and 2 for bad usage.
+
+Flags:
+`
+
+// Output formats.
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// result is what the crawl of one URL found.
+type result struct {
+	URL           string       `json:"url"`
+	Status        string       `json:"status"`
+	HTMLVersion   string       `json:"html_version,omitempty"`
+	Title         string       `json:"title,omitempty"`
+	Headings      string       `json:"headings,omitempty"`
+	InternalLinks int          `json:"internal_links"`
+	ExternalLinks int          `json:"external_links"`
+	BrokenLinks   int          `json:"broken_links"`
+	Broken        []brokenLink `json:"broken,omitempty"`
+	HasLoginForm  bool         `json:"has_login_form"`
+	ErrorClass    string       `json:"error_class,omitempty"`
+	Error         string       `json:"error,omitempty"`
+	DurationMS    int64        `json:"duration_ms"`
+}
+
+type brokenLink struct {
+	Href string `json:"href"`
+	// StatusCode is zero when the link could not be reached at all.
+	StatusCode int `json:"status_code,omitempty"`
+}
+
+func main() {
+	timeout := flag.Duration("timeout", 30*time.Second, "time allowed for each page, including its link checks")
+	concurrency := flag.Int("concurrency", 4, "number of pages crawled at once")
+	checkLinks := flag.Bool("check-links", true, "send a HEAD request to every link to find broken ones")
+	format := flag.String("format", formatTable, "output format: table or json")
+	maxBroken := flag.Int("max-broken", -1, "most broken links a page may have before the exit status is 1; -1 for no limit")
+	verbose := flag.Bool("v", false, "log each crawl phase to standard error")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *format != formatTable && *format != formatJSON {
+		usageError("-format must be table or json, got %q", *format)
+	}
+	if *concurrency < 1 {
+		usageError("-concurrency must be at least 1")
+	}
+	if *timeout <= 0 {
+		usageError("-timeout must be positive")
+	}
+
+	level := "error"
+	if *verbose {
+		level = "debug"
+	}
+	logger, err := logging.New(os.Stderr, logging.Config{Level: level, Format: logging.FormatText})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+
+	urls := flag.Args()
+	if len(urls) == 0 {
+		if urls, err = readURLs(os.Stdin); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read URLs: %v\n", err)
+			os.Exit(1)
+		}
+		if len(urls) == 0 {
+			usageError("no URLs given")
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	opts := crawler.Options{SkipLinkCheck: !*checkLinks}
+	results := crawlAll(ctx, urls, *concurrency, *timeout, opts)
+
+	if *format == formatJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(results)
+	} else {
+		err = writeTable(os.Stdout, results)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write results: %v\n", err)
+		os.Exit(1)
+	}
+
+	var failed, overLimit int
+	for _, r := range results {
+		if r.Status != "done" {
+			failed++
+		} else if *maxBroken >= 0 && r.BrokenLinks > *maxBroken {
+			overLimit++
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d crawls failed\n", failed, len(results))
+	}
+	if overLimit > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d pages have more than %d broken links\n", overLimit, len(results), *maxBroken)
+	}
+	if failed > 0 || overLimit > 0 {
+		os.Exit(1)
+	}
+}
+
+func usageError(format string, args ...any) {
+	fmt.Fprintf(flag.CommandLine.Output(), format+"\n\n", args...)
+	flag.Usage()
+	os.Exit(2)
+}
+
+// readURLs reads one URL per line, skipping blank lines and comments.
+func readURLs(r io.Reader) ([]string, error) {
+	var urls []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	return urls, scanner.Err()
+}
+
+// crawlAll crawls the URLs, concurrency at a time, and returns their
+// results in the same order.
+func crawlAll(ctx context.Context, urls []string, concurrency int, timeout time.Duration, opts crawler.Options) []result {
+	results := make([]result, len(urls))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(concurrency, len(urls)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = crawl(ctx, urls[i], timeout, opts)
+			}
+		}()
+	}
+	for i := range urls {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func crawl(ctx context.Context, rawURL string, timeout time.Duration, opts crawler.Options) result {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	u := model.URL{URL: rawURL}
+	// The outcome is recorded on u.
+	_ = crawler.Crawl(ctx, &u, opts)
+
+	r := result{
+		URL:           u.URL,
+		Status:        u.Status,
+		HTMLVersion:   u.HTMLVersion,
+		Title:         u.PageTitle,
+		Headings:      u.Headings,
+		InternalLinks: u.InternalLinks,
+		ExternalLinks: u.ExternalLinks,
+		BrokenLinks:   u.BrokenLinks,
+		HasLoginForm:  u.HasLoginForm,
+		ErrorClass:    u.ErrorClass,
+		Error:         u.ErrorMessage,
+		DurationMS:    time.Since(start).Milliseconds(),
+	}
+	for _, link := range u.Links {
+		if link.Broken {
+			r.Broken = append(r.Broken, brokenLink{Href: link.Href, StatusCode: link.StatusCode})
+		}
+	}
+	return r
+}
+
+// writeTable prints a line per page, followed by the failures and broken
+// links.
+func writeTable(out io.Writer, results []result) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "URL\tSTATUS\tTITLE\tINTERNAL\tEXTERNAL\tBROKEN\tLOGIN FORM\tTIME")
+	for _, r := range results {
+		status := r.Status
+		if r.ErrorClass != "" {
+			status = r.ErrorClass
+		}
+		loginForm := "no"
+		if r.HasLoginForm {
+			loginForm = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%s\t%s\n",
+			r.URL, status, truncate(r.Title, 40), r.InternalLinks, r.ExternalLinks, r.BrokenLinks, loginForm,
+			(time.Duration(r.DurationMS) * time.Millisecond).String())
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	var details []string
+	for _, r := range results {
+		if r.Error != "" {
+			details = append(details, fmt.Sprintf("%s: %s", r.URL, r.Error))
+		}
+		for _, link := range r.Broken {
+			code := "unreachable"
+			if link.StatusCode != 0 {
+				code = fmt.Sprint(link.StatusCode)
+			}
+			details = append(details, fmt.Sprintf("%s: broken link %s (%s)", r.URL, link.Href, code))
+		}
+	}
+	if len(details) > 0 {
+		_, err := fmt.Fprintf(out, "\n%s\n", strings.Join(details, "\n"))
+		return err
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -1,33 +1,174 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 	"url-crawler-backend/internal/api"
+	"url-crawler-backend/internal/config"
 	"url-crawler-backend/internal/db"
+	"url-crawler-backend/internal/idempotency"
+	"url-crawler-backend/internal/jwtkeys"
+	"url-crawler-backend/internal/logging"
+	"url-crawler-backend/internal/metrics"
+	"url-crawler-backend/internal/migrate"
+	"url-crawler-backend/internal/ratelimit"
+	"url-crawler-backend/internal/sso"
+	"url-crawler-backend/internal/tracing"
+	"url-crawler-backend/internal/trash"
+	"url-crawler-backend/internal/version"
 
-	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"gorm.io/gorm"
 )
 
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("Warning: .env file not found")
+	configFile := flag.String("config", "", "path to a YAML config file (default $CONFIG_FILE)")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		fatal("Invalid configuration", err)
+	}
+
+	logger, err := logging.New(os.Stderr, cfg.Log)
+	if err != nil {
+		fatal("Failed to set up logging", err)
+	}
+	slog.SetDefault(logger)
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		fatal("Failed to set up tracing", err)
+	}
+
+	registry := metrics.New()
+	conn, err := db.Open(cfg.Database, registry)
+	if err != nil {
+		fatal("Failed to connect to DB", err)
 	}
 
-	db.Connect()
+	if cfg.Database.MigrateOnStart {
+		if _, err := migrate.Up(conn); err != nil {
+			fatal("Failed to migrate the database", err)
+		}
+	} else if err := migrate.Check(conn); err != nil {
+		fatal("Database schema is not up to date; run `go run ./cmd/migrate up` first", err)
+	}
+
+	keys, err := jwtkeys.Load(cfg.JWT.Secret, cfg.JWT.KeysFile)
+	if err != nil {
+		fatal("Failed to load JWT signing keys", err)
+	}
+
+	trash.StartPurger(ctx, conn, cfg.TrashRetention)
+	idempotency.StartPurger(ctx, conn)
+
+	srv := api.NewServer(conn)
+	srv.Keys = keys
+	srv.Metrics = registry
+	srv.IdempotencyTTL = cfg.IdempotencyTTL
+	srv.RateLimits = cfg.RateLimits
+	srv.CrawlQuota = ratelimit.NewConcurrency(cfg.CrawlConcurrency)
+	srv.TOTPIssuer = cfg.TOTPIssuer
+
+	if cfg.OIDC.Enabled() {
+		provider, err := sso.NewProvider(ctx, cfg.OIDC)
+		if err != nil {
+			fatal("Failed to configure OIDC", err)
+		}
+		srv.SSO = provider
+		srv.OIDCSuccessRedirect = cfg.OIDCSuccessRedirect
+	}
 
 	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	e.IPExtractor = api.IPExtractor(cfg.TrustedProxies)
 
+	e.Use(api.RequestID)
+	e.Use(api.RequestLog)
+	e.Use(srv.MetricsMiddleware)
+	e.Use(api.Tracing)
+	e.Use(api.RenderErrors)
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins:     []string{"http://localhost:3000", "http://localhost:5173", "http://12700.13000", "http://1270.1"},
+		AllowOrigins:     cfg.CORSOrigins,
 		AllowMethods:     []string{echo.GET, echo.HEAD, echo.PUT, echo.PATCH, echo.POST, echo.DELETE},
-		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization},
+		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, api.HeaderWorkspaceID, api.HeaderIdempotencyKey, api.HeaderTraceparent},
+		ExposeHeaders:    []string{api.HeaderWorkspaceID, echo.HeaderXRequestID, api.HeaderIdempotentReplayed, api.HeaderRateLimitLimit, api.HeaderRateLimitRemaining, api.HeaderRateLimitReset, api.HeaderRateLimitPolicy, echo.HeaderRetryAfter},
 		AllowCredentials: true,
 		MaxAge:           86400,
 	}))
 
-	api.RegisterRoutes(e)
+	srv.RegisterRoutes(e)
+
+	if n, err := srv.ResumeCrawls(ctx); err != nil {
+		slog.Error("Failed to resume unfinished crawls", "error", err)
+	} else if n > 0 {
+		slog.Info("Resuming unfinished crawls", "count", n)
+	}
+
+	slog.Info("Starting server", "addr", cfg.Addr, "version", version.Get().Version)
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- e.Start(cfg.Addr)
+	}()
+
+	select {
+	case err = <-serverErr:
+		slog.Error("Server failed", "error", err)
+	case <-ctx.Done():
+		slog.Info("Shutting down", "timeout", cfg.ShutdownTimeout)
+	}
+	// A second signal kills the process without waiting.
+	stop()
+
+	shutdown(e, srv, conn, shutdownTracing, cfg.ShutdownTimeout)
+	if err != nil {
+		os.Exit(1)
+	}
+	slog.Info("Server stopped")
+}
+
+// shutdown stops accepting requests and waits, up to timeout in all, for
+// the requests being served and the crawls running to finish. Crawls still
+// running after that are cancelled and their URLs queued again. It then
+// flushes the buffered spans and closes the database.
+func shutdown(e *echo.Echo, srv *api.Server, conn *gorm.DB, shutdownTracing func(context.Context) error, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := e.Shutdown(ctx); err != nil {
+		slog.Error("Failed to finish serving requests", "error", err)
+	}
+	if err := srv.DrainCrawls(ctx); err != nil {
+		slog.Warn("Crawls did not finish in time and were queued again", "error", err)
+	}
+
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+
+	sqlDB, err := conn.DB()
+	if err == nil {
+		err = sqlDB.Close()
+	}
+	if err != nil {
+		slog.Error("Failed to close the database", "error", err)
+	}
+}
 
-	e.Logger.Fatal(e.Start(":8080"))
+// fatal logs err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
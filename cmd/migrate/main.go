@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"url-crawler-backend/internal/config"
+	"url-crawler-backend/internal/db"
+	"url-crawler-backend/internal/migrate"
+)
+
+const usage = `Usage: migrate [-config file] <command>
+
+Commands:
+  up        apply every pending migration
+  down [n]  roll back the last n migrations (default 1)
+  status    list migrations and whether they are applied
+`
+
+func main() {
+	configFile := flag.String("config", "", "path to a YAML config file (default $CONFIG_FILE)")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configFile, config.WithoutJWT())
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	conn, err := db.Open(cfg.Database, nil)
+	if err != nil {
+		log.Fatalf("Failed to connect to DB: %v", err)
+	}
+	m, err := migrate.New(conn, migrate.All)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch flag.Arg(0) {
+	case "up":
+		applied, err := m.Up()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			log.Println("Database is up to date")
+		}
+
+	case "down":
+		steps := 1
+		if flag.NArg() > 1 {
+			if steps, err = strconv.Atoi(flag.Arg(1)); err != nil || steps < 1 {
+				log.Fatalf("down takes a positive number of migrations, got %q", flag.Arg(1))
+			}
+		}
+		rolledBack, err := m.Down(steps)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(rolledBack) == 0 {
+			log.Println("No migrations to roll back")
+		}
+
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			log.Fatal(err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			name := s.Name
+			if s.Up == nil {
+				name += " (unknown to this build)"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, name, appliedAt)
+		}
+		w.Flush()
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -1,19 +1,33 @@
 package main
 
 import (
+	"context"
 	"log"
+	"url-crawler-backend/internal/config"
 	"url-crawler-backend/internal/db"
+	"url-crawler-backend/internal/migrate"
 	"url-crawler-backend/internal/model"
+	"url-crawler-backend/internal/store"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 func main() {
-	db.Connect()
+	cfg, err := config.Load("", config.WithoutJWT())
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	conn, err := db.Open(cfg.Database, nil)
+	if err != nil {
+		log.Fatalf("Failed to connect to DB: %v", err)
+	}
+	if err := migrate.Check(conn); err != nil {
+		log.Fatalf("%v; run `go run ./cmd/migrate up` first", err)
+	}
 
-	var existing model.User
-	result := db.DB.Where("username = ?", "admin").First(&existing)
-	if result.Error == nil {
+	ctx := context.Background()
+	users := store.NewGormUserStore(conn)
+	if _, err := users.ByUsername(ctx, "admin"); err == nil {
 		log.Println("User 'admin' already exists. Skipping seed.")
 		return
 	}
@@ -26,9 +40,10 @@ func main() {
 	user := model.User{
 		Username: "admin",
 		Password: string(hashed),
+		IsAdmin:  true,
 	}
 
-	if err := db.DB.Create(&user).Error; err != nil {
+	if err := users.Create(ctx, &user); err != nil {
 		log.Fatal("Failed to create user:", err)
 	}
 
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"url-crawler-backend/internal/apperr"
+	"url-crawler-backend/internal/audit"
+	"url-crawler-backend/internal/model"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// recordAudit fills in the actor, workspace and client IP from the request
+// and appends the entry. A failed write is logged but never fails the
+// request that triggered it.
+func (s *Server) recordAudit(c echo.Context, e audit.Entry) {
+	if token, ok := c.Get("user").(*jwt.Token); ok {
+		if claims, ok := token.Claims.(jwt.MapClaims); ok {
+			if id, ok := claims["id"].(float64); ok && e.ActorID == 0 {
+				e.ActorID = uint(id)
+			}
+			if username, ok := claims["username"].(string); ok && e.ActorUsername == "" {
+				e.ActorUsername = username
+			}
+		}
+	}
+	if m, ok := c.Get(membershipContextKey).(model.Membership); ok && e.WorkspaceID == 0 {
+		e.WorkspaceID = m.WorkspaceID
+	}
+	e.IP = c.RealIP()
+
+	// The entry is written even if the client has gone away.
+	if err := s.Audit.Record(context.WithoutCancel(c.Request().Context()), e); err != nil {
+		slog.ErrorContext(c.Request().Context(), "Failed to write audit log entry", "action", e.Action, "error", err)
+	}
+}
+
+// RequireAdmin allows only users flagged as system administrators.
+func (s *Server) RequireAdmin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user, err := s.loadCurrentUser(c)
+		if err != nil {
+			return err
+		}
+		if !user.IsAdmin {
+			return apperr.Forbidden("Administrator access required")
+		}
+		return next(c)
+	}
+}
+
+func (s *Server) GetAuditLog(c echo.Context) error {
+	filter := audit.Filter{
+		Actor:      c.QueryParam("actor"),
+		Action:     c.QueryParam("action"),
+		Outcome:    c.QueryParam("outcome"),
+		TargetType: c.QueryParam("target_type"),
+		Limit:      defaultAuditLimit,
+	}
+
+	var err error
+	if filter.ActorID, err = uintQueryParam(c, "actor_id"); err != nil {
+		return err
+	}
+	if filter.WorkspaceID, err = uintQueryParam(c, "workspace_id"); err != nil {
+		return err
+	}
+	if filter.TargetID, err = uintQueryParam(c, "target_id"); err != nil {
+		return err
+	}
+	if filter.From, err = timeQueryParam(c, "from"); err != nil {
+		return err
+	}
+	if filter.To, err = timeQueryParam(c, "to"); err != nil {
+		return err
+	}
+
+	if raw := c.QueryParam("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			return apperr.BadRequest("limit must be between 1 and 1000")
+		}
+		filter.Limit = limit
+	}
+	if raw := c.QueryParam("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return apperr.BadRequest("offset must be a non-negative integer")
+		}
+		filter.Offset = offset
+	}
+
+	entries, total, err := s.Audit.Query(c.Request().Context(), filter)
+	if err != nil {
+		return apperr.Internal(err, "Failed to fetch audit log")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"entries": entries,
+		"total":   total,
+	})
+}
+
+func uintQueryParam(c echo.Context, name string) (uint, error) {
+	raw := c.QueryParam(name)
+	if raw == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return 0, apperr.BadRequest(name + " must be a positive integer")
+	}
+	return uint(v), nil
+}
+
+func timeQueryParam(c echo.Context, name string) (time.Time, error) {
+	raw := c.QueryParam(name)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, apperr.BadRequest(name + " must be an RFC 3339 timestamp")
+	}
+	return t, nil
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"url-crawler-backend/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestAuditLogRecordsActionsForAdmins(t *testing.T) {
+	t.Parallel()
+	testDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	testDB.AutoMigrate(&model.URL{}, &model.User{}, &model.Workspace{}, &model.Membership{}, &model.AuditLog{})
+
+	s := NewServer(testDB)
+
+	hashed, _ := bcrypt.GenerateFromPassword([]byte("testpassword"), bcrypt.MinCost)
+	admin := model.User{Username: "admin", Password: string(hashed), IsAdmin: true}
+	user := model.User{Username: "bob", Password: string(hashed)}
+	testDB.Create(&admin)
+	testDB.Create(&user)
+
+	rec := callAs(0, "", http.MethodPost, "/login", map[string]string{"username": "bob", "password": "nope"}, s.Login)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = callAs(user.ID, "", http.MethodPost, "/api/urls", map[string]string{"url": "https://example.com"}, s.WorkspaceMiddleware(s.AddURL))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var created model.URL
+	json.Unmarshal(rec.Body.Bytes(), &created)
+
+	rec = callAs(user.ID, "", http.MethodGet, "/api/audit", nil, s.RequireAdmin(s.GetAuditLog))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = callAs(admin.ID, "", http.MethodGet, "/api/audit?action=auth.login&outcome=failure", nil, s.RequireAdmin(s.GetAuditLog))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response struct {
+		Entries []model.AuditLog `json:"entries"`
+		Total   int64            `json:"total"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Equal(t, int64(1), response.Total)
+	assert.Equal(t, "bob", response.Entries[0].ActorUsername)
+	assert.NotEmpty(t, response.Entries[0].IP)
+
+	rec = callAs(admin.ID, "", http.MethodGet, "/api/audit?target_id="+strconv.Itoa(int(created.ID)), nil, s.RequireAdmin(s.GetAuditLog))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Equal(t, int64(1), response.Total)
+	assert.Equal(t, "url.create", response.Entries[0].Action)
+	assert.Equal(t, user.ID, *response.Entries[0].ActorID)
+	assert.NotNil(t, response.Entries[0].WorkspaceID)
+
+	rec = callAs(admin.ID, "", http.MethodGet, "/api/audit?from=yesterday", nil, s.RequireAdmin(s.GetAuditLog))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -2,10 +2,10 @@ package api
 
 import (
 	"net/http"
-	"os"
 	"time"
 
-	"url-crawler-backend/internal/db"
+	"url-crawler-backend/internal/apperr"
+	"url-crawler-backend/internal/audit"
 	"url-crawler-backend/internal/model"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -14,39 +14,99 @@ import (
 )
 
 type LoginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
 }
 
-func Login(c echo.Context) error {
+func (s *Server) Login(c echo.Context) error {
 	var req LoginRequest
 
-	if err := c.Bind(&req); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
+	if err := bindRequest(c, &req); err != nil {
+		return err
 	}
 
-	var user model.User
-	if err := db.DB.Where("username = ?", req.Username).First(&user).Error; err != nil {
-		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid username or password")
+	user, err := s.Users.ByUsername(c.Request().Context(), req.Username)
+	if err != nil {
+		s.recordAudit(c, audit.Entry{Action: "auth.login", Outcome: model.AuditFailure, ActorUsername: req.Username, Detail: "unknown user"})
+		return apperr.Unauthorized("Invalid username or password")
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid username or password")
+		s.recordAudit(c, audit.Entry{Action: "auth.login", Outcome: model.AuditFailure, ActorID: user.ID, ActorUsername: user.Username, Detail: "wrong password"})
+		return apperr.Unauthorized("Invalid username or password")
 	}
 
-	claims := jwt.MapClaims{
-		"id":       user.ID,
-		"username": user.Username,
-		"exp":      time.Now().Add(72 * time.Hour).Unix(),
+	s.recordAudit(c, audit.Entry{Action: "auth.login", ActorID: user.ID, ActorUsername: user.Username})
+
+	if user.TOTPEnabled {
+		challenge, err := s.issueChallengeToken(user, "pwd")
+		if err != nil {
+			return apperr.Internal(err, "Failed to sign token")
+		}
+		return c.JSON(http.StatusOK, echo.Map{
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+		})
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	t, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	t, err := s.issueToken(user, "pwd")
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to sign token")
+		return apperr.Internal(err, "Failed to sign token")
 	}
 
 	return c.JSON(http.StatusOK, echo.Map{
 		"token": t,
 	})
 }
+
+// issueToken signs an access token. amr lists the authentication methods
+// the user completed (RFC 8176), e.g. "pwd" and "otp".
+func (s *Server) issueToken(user model.User, amr ...string) (string, error) {
+	claims := jwt.MapClaims{
+		"id":       user.ID,
+		"username": user.Username,
+		"exp":      time.Now().Add(72 * time.Hour).Unix(),
+	}
+	if len(amr) > 0 {
+		claims["amr"] = amr
+	}
+
+	return s.Keys.Sign(claims)
+}
+
+// issueChallengeToken signs the short-lived token that proves the first
+// factor succeeded. factor is its amr value ("pwd" or "sso"), carried over
+// to the access token. The typ claim keeps JWTMiddleware from accepting it.
+func (s *Server) issueChallengeToken(user model.User, factor string) (string, error) {
+	claims := jwt.MapClaims{
+		"id":     user.ID,
+		"typ":    challengeTokenType,
+		"factor": factor,
+		"exp":    time.Now().Add(challengeTokenTTL).Unix(),
+	}
+
+	return s.Keys.Sign(claims)
+}
+
+// currentUserID returns the user ID from the access token set by
+// JWTMiddleware.
+func currentUserID(c echo.Context) (uint, error) {
+	token, ok := c.Get("user").(*jwt.Token)
+	if !ok {
+		return 0, apperr.Unauthorized("Missing token")
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, apperr.Unauthorized("Invalid token")
+	}
+	id, ok := claims["id"].(float64)
+	if !ok || id <= 0 {
+		return 0, apperr.Unauthorized("Invalid token")
+	}
+	return uint(id), nil
+}
+
+func (s *Server) JWKS(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, s.Keys.JWKS())
+}
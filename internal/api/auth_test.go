@@ -7,7 +7,6 @@ import (
 	"net/http/httptest"
 	"testing"
 
-	"url-crawler-backend/internal/db"
 	"url-crawler-backend/internal/model"
 
 	"github.com/labstack/echo/v4"
@@ -18,6 +17,7 @@ import (
 )
 
 func TestLogin(t *testing.T) {
+	t.Parallel()
 	e := echo.New()
 	testDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	if err != nil {
@@ -71,7 +71,7 @@ func TestLogin(t *testing.T) {
 				"username": "",
 				"password": "",
 			},
-			expectedStatus: http.StatusUnauthorized,
+			expectedStatus: http.StatusBadRequest,
 			expectedError:  true,
 		},
 	}
@@ -84,11 +84,9 @@ func TestLogin(t *testing.T) {
 			rec := httptest.NewRecorder()
 			c := e.NewContext(req, rec)
 
-			originalDB := db.DB
-			db.DB = testDB
-			defer func() { db.DB = originalDB }()
+			s := NewServer(testDB)
 
-			err := Login(c)
+			err := s.Login(c)
 
 			if tt.expectedError {
 				assert.Error(t, err)
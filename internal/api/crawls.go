@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+// crawlPool runs crawls in the background. Once stopped it refuses new
+// crawls, and the readiness check reports the server as not ready.
+type crawlPool struct {
+	mu      sync.Mutex
+	stopped bool
+	running sync.WaitGroup
+
+	// abort cancels the contexts of the running crawls.
+	ctx   context.Context
+	abort context.CancelFunc
+}
+
+func newCrawlPool() *crawlPool {
+	ctx, abort := context.WithCancel(context.Background())
+	return &crawlPool{ctx: ctx, abort: abort}
+}
+
+// Go runs crawl in a new goroutine, unless the pool has stopped. The
+// context crawl gets carries the values of ctx and is cancelled if the
+// pool gives up waiting for it in Shutdown.
+func (p *crawlPool) Go(ctx context.Context, crawl func(ctx context.Context)) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return false
+	}
+
+	p.running.Add(1)
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(p.ctx, cancel)
+	go func() {
+		defer p.running.Done()
+		defer cancel()
+		defer stop()
+		crawl(ctx)
+	}()
+	return true
+}
+
+// Stop makes the pool refuse new crawls. Crawls already running go on.
+func (p *crawlPool) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopped = true
+}
+
+func (p *crawlPool) Running() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !p.stopped
+}
+
+// Shutdown stops the pool and waits for the running crawls to finish. If
+// ctx ends first, it cancels them and returns ctx's error once they have
+// returned.
+func (p *crawlPool) Shutdown(ctx context.Context) error {
+	p.Stop()
+	done := make(chan struct{})
+	go func() {
+		p.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		p.abort()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// DrainCrawls stops the server from starting crawls and waits for the
+// running ones. Crawls still running or waiting for their user's quota
+// when ctx ends are cancelled and their URLs queued again, still marked
+// pending for ResumeCrawls.
+func (s *Server) DrainCrawls(ctx context.Context) error {
+	return s.crawls.Shutdown(ctx)
+}
+
+// ResumeCrawls starts the crawls a shutdown or crash interrupted again and
+// returns how many there were. URLs nobody started a crawl for stay
+// queued. Who started a crawl is not recorded, so resumed crawls count
+// against a crawl quota of their workspace.
+func (s *Server) ResumeCrawls(ctx context.Context) (int, error) {
+	urls, err := s.URLs.Interrupted(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, u := range urls {
+		s.runCrawl(ctx, u, "workspace:"+strconv.FormatUint(uint64(u.WorkspaceID), 10))
+	}
+	return len(urls), nil
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-crawler-backend/internal/model"
+	"url-crawler-backend/internal/ratelimit"
+	"url-crawler-backend/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrawlPoolShutdown(t *testing.T) {
+	t.Parallel()
+	pool := newCrawlPool()
+	finish := make(chan struct{})
+	var finished bool
+	require.True(t, pool.Go(context.Background(), func(ctx context.Context) {
+		<-finish
+		finished = ctx.Err() == nil
+	}))
+
+	go close(finish)
+	require.NoError(t, pool.Shutdown(context.Background()))
+	assert.True(t, finished)
+	assert.False(t, pool.Go(context.Background(), func(context.Context) {}))
+
+	// Crawls still running at the deadline are cancelled and waited for.
+	pool = newCrawlPool()
+	var cancelled bool
+	pool.Go(context.Background(), func(ctx context.Context) {
+		<-ctx.Done()
+		cancelled = true
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, pool.Shutdown(ctx), context.DeadlineExceeded)
+	assert.True(t, cancelled)
+}
+
+func TestDrainCrawlsRequeuesUnfinished(t *testing.T) {
+	t.Parallel()
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer page.Close()
+
+	s := NewServer(nil)
+	s.URLs = store.NewMemoryURLStore()
+	s.CrawlQuota = ratelimit.NewConcurrency(1)
+	running := model.URL{WorkspaceID: 1, URL: page.URL}
+	waiting := model.URL{WorkspaceID: 1, URL: page.URL + "/next"}
+	require.NoError(t, s.URLs.Create(context.Background(), &running, nil))
+	require.NoError(t, s.URLs.Create(context.Background(), &waiting, nil))
+
+	s.startCrawl(context.Background(), running, 7)
+	s.startCrawl(context.Background(), waiting, 7)
+	saved := func(u model.URL) model.URL {
+		saved, err := s.URLs.Get(context.Background(), 1, u.ID)
+		require.NoError(t, err)
+		return saved
+	}
+	status := func(u model.URL) string { return saved(u).Status }
+	assert.Equal(t, "running", status(running))
+	assert.Equal(t, "queued", status(waiting))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.DrainCrawls(ctx), context.DeadlineExceeded)
+
+	assert.Equal(t, "queued", status(running))
+	assert.Equal(t, "queued", status(waiting))
+	assert.True(t, saved(running).CrawlPending)
+	assert.True(t, saved(waiting).CrawlPending)
+	assert.False(t, s.crawls.Running())
+
+	// Crawls started during the shutdown are left queued.
+	done := model.URL{WorkspaceID: 1, URL: page.URL + "/late", Status: "done"}
+	require.NoError(t, s.URLs.Create(context.Background(), &done, nil))
+	s.startCrawl(context.Background(), done, 7)
+	assert.Equal(t, "queued", status(done))
+	assert.True(t, saved(done).CrawlPending)
+}
+
+func TestResumeCrawls(t *testing.T) {
+	t.Parallel()
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<!DOCTYPE html><html><head><title>Resumed</title></head><body></body></html>"))
+	}))
+	defer page.Close()
+
+	s := NewServer(nil)
+	s.URLs = store.NewMemoryURLStore()
+	s.CrawlQuota = ratelimit.NewConcurrency(1)
+	urls := []model.URL{
+		{WorkspaceID: 1, URL: page.URL + "/added", Status: "queued"},
+		{WorkspaceID: 1, URL: page.URL + "/waiting", Status: "queued", CrawlPending: true},
+		{WorkspaceID: 2, URL: page.URL + "/running", Status: "running", CrawlPending: true},
+		{WorkspaceID: 1, URL: page.URL + "/done", Status: "done"},
+	}
+	for i := range urls {
+		require.NoError(t, s.URLs.Create(context.Background(), &urls[i], nil))
+	}
+	get := func(u model.URL) model.URL {
+		saved, err := s.URLs.Get(context.Background(), u.WorkspaceID, u.ID)
+		require.NoError(t, err)
+		return saved
+	}
+
+	// Resumed crawls are charged to their workspace.
+	release, ok := s.CrawlQuota.TryAcquire("workspace:1")
+	require.True(t, ok)
+
+	n, err := s.ResumeCrawls(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	require.Eventually(t, func() bool { return get(urls[2]).Status == "done" }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "queued", get(urls[1]).Status)
+
+	release()
+	require.NoError(t, s.DrainCrawls(context.Background()))
+
+	// Only crawls that were interrupted are resumed.
+	assert.Equal(t, "queued", get(urls[0]).Status)
+	assert.Empty(t, get(urls[0]).PageTitle)
+	for _, u := range urls[1:3] {
+		saved := get(u)
+		assert.Equal(t, "done", saved.Status, u.URL)
+		assert.Equal(t, "Resumed", saved.PageTitle, u.URL)
+		assert.False(t, saved.CrawlPending, u.URL)
+	}
+	assert.Empty(t, get(urls[3]).PageTitle)
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+
+	"url-crawler-backend/internal/apperr"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrorHandler renders every error as an apperr.Error tagged with the
+// request ID, and records it on the request's span. It is the only place
+// errors are logged: the cause of server errors is logged, not returned.
+func ErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	ctx := c.Request().Context()
+	status, body := apperr.From(err)
+	body.RequestID = requestID(c)
+	trace.SpanFromContext(ctx).RecordError(err)
+	if status >= http.StatusInternalServerError {
+		slog.ErrorContext(ctx, "Request failed", "method", c.Request().Method, "path", c.Request().URL.Path, "error", err)
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(status)
+	} else {
+		err = c.JSON(status, body)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to write error response", "error", err)
+	}
+}
+
+// RenderErrors hands errors to ErrorHandler as soon as a handler returns
+// them and does not pass them on. The middlewares installed before it,
+// such as RequestLog, Metrics and Tracing, only read the status of the
+// response it wrote.
+func RenderErrors(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if err := next(c); err != nil {
+			c.Error(err)
+		}
+		return nil
+	}
+}
+
+func requestID(c echo.Context) string {
+	if id := c.Response().Header().Get(echo.HeaderXRequestID); id != "" {
+		return id
+	}
+	return c.Request().Header.Get(echo.HeaderXRequestID)
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"url-crawler-backend/internal/apperr"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorHandler(t *testing.T) {
+	t.Parallel()
+	e := echo.New()
+	e.Use(middleware.RequestID())
+	NewServer(nil).RegisterRoutes(e)
+
+	for _, path := range []string{"/api/urls", "/missing"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		var body apperr.Error
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body), path)
+		assert.NotEmpty(t, body.Code, path)
+		assert.NotEmpty(t, body.Message, path)
+		assert.Equal(t, rec.Header().Get(echo.HeaderXRequestID), body.RequestID, path)
+		assert.NotEmpty(t, body.RequestID, path)
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"url-crawler-backend/internal/apperr"
+	"url-crawler-backend/internal/audit"
+	"url-crawler-backend/internal/export"
+	"url-crawler-backend/internal/model"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ExportURLs streams the workspace's URLs, narrowed by the same filters as
+// GetURLs, as CSV, NDJSON or XLSX. With links=true each URL's links from
+// its last crawl are included. URLs are read in batches so memory use does
+// not grow with the size of the export.
+func (s *Server) ExportURLs(c echo.Context) error {
+	membership, err := requireRole(c, model.RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	format, err := export.ParseFormat(c.QueryParam("format"))
+	if err != nil {
+		return apperr.BadRequest(err.Error())
+	}
+
+	withLinks := false
+	if raw := c.QueryParam("links"); raw != "" {
+		if withLinks, err = strconv.ParseBool(raw); err != nil {
+			return apperr.BadRequest("'links' must be true or false")
+		}
+	}
+
+	filter, err := urlFilter(c, membership.WorkspaceID)
+	if err != nil {
+		return err
+	}
+	s.recordAudit(c, audit.Entry{Action: "url.export", TargetType: "workspace", TargetIDs: []uint{membership.WorkspaceID}, Detail: string(format)})
+
+	res := c.Response()
+	filename := fmt.Sprintf("urls-%s.%s", time.Now().UTC().Format("20060102-150405"), format.Extension())
+	res.Header().Set(echo.HeaderContentType, format.ContentType())
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	res.WriteHeader(http.StatusOK)
+
+	writer, err := export.NewWriter(res, format, withLinks)
+	if err != nil {
+		slog.ErrorContext(c.Request().Context(), "Failed to start export", "error", err)
+		return nil
+	}
+
+	err = s.URLs.Each(c.Request().Context(), filter, withLinks, func(batch []model.URL) error {
+		for _, u := range batch {
+			if err := writer.Write(u); err != nil {
+				return err
+			}
+		}
+		res.Flush()
+		return nil
+	})
+
+	// The status line has already been sent, so a failure can only cut the
+	// download short.
+	if err != nil {
+		slog.ErrorContext(c.Request().Context(), "Export failed", "workspace_id", membership.WorkspaceID, "error", err)
+		return nil
+	}
+	if err := writer.Close(); err != nil {
+		slog.ErrorContext(c.Request().Context(), "Export failed", "workspace_id", membership.WorkspaceID, "error", err)
+	}
+	return nil
+}
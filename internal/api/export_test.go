@@ -0,0 +1,81 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"net/http"
+	"strings"
+	"testing"
+
+	"url-crawler-backend/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestExportURLs(t *testing.T) {
+	t.Parallel()
+	testDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	testDB.AutoMigrate(&model.URL{}, &model.Link{}, &model.User{}, &model.Workspace{}, &model.Membership{}, &model.AuditLog{})
+
+	s := NewServer(testDB)
+
+	user := model.User{Username: "alice"}
+	testDB.Create(&user)
+	workspace := model.Workspace{Name: "Team"}
+	testDB.Create(&workspace)
+	testDB.Create(&model.Membership{WorkspaceID: workspace.ID, UserID: user.ID, Role: model.RoleViewer})
+
+	crawled := model.URL{WorkspaceID: workspace.ID, URL: "https://crawled.example", Status: "running"}
+	queued := model.URL{WorkspaceID: workspace.ID, URL: "https://queued.example", Status: "queued"}
+	other := model.URL{WorkspaceID: workspace.ID + 1, URL: "https://other.example", Status: "done"}
+	for _, u := range []*model.URL{&crawled, &queued, &other} {
+		require.NoError(t, testDB.Create(u).Error)
+	}
+
+	crawled.Status = "done"
+	crawled.BrokenLinks = 1
+	crawled.Links = []model.Link{
+		{Href: "https://crawled.example/about", Internal: true, StatusCode: 200},
+		{Href: "https://gone.example", StatusCode: 404, Broken: true},
+	}
+	require.NoError(t, s.URLs.SaveCrawlResult(context.Background(), &crawled))
+
+	rec := callAs(user.ID, "", http.MethodGet, "/api/urls/export?format=csv", nil, s.WorkspaceMiddleware(s.ExportURLs))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), ".csv")
+	records, err := csv.NewReader(bytes.NewReader(rec.Body.Bytes())).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, "https://crawled.example", records[1][1])
+	assert.Equal(t, "https://queued.example", records[2][1])
+
+	rec = callAs(user.ID, "", http.MethodGet, "/api/urls/export?format=csv&links=true&has_broken_links=true", nil, s.WorkspaceMiddleware(s.ExportURLs))
+	require.Equal(t, http.StatusOK, rec.Code)
+	records, err = csv.NewReader(bytes.NewReader(rec.Body.Bytes())).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, "https://crawled.example/about", records[1][14])
+	assert.Equal(t, "404", records[2][16])
+
+	rec = callAs(user.ID, "", http.MethodGet, "/api/urls/export?format=ndjson&status=queued", nil, s.WorkspaceMiddleware(s.ExportURLs))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], `"url":"https://queued.example"`)
+
+	rec = callAs(user.ID, "", http.MethodGet, "/api/urls/export?format=pdf", nil, s.WorkspaceMiddleware(s.ExportURLs))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// A new crawl replaces the previous links.
+	crawled.Links = []model.Link{{Href: "https://crawled.example/new", Internal: true, StatusCode: 200}}
+	require.NoError(t, s.URLs.SaveCrawlResult(context.Background(), &crawled))
+	var count int64
+	testDB.Model(&model.Link{}).Where("url_id = ?", crawled.ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
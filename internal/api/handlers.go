@@ -1,103 +1,376 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	"url-crawler-backend/internal/apperr"
+	"url-crawler-backend/internal/audit"
 	"url-crawler-backend/internal/crawler"
-	"url-crawler-backend/internal/db"
+	"url-crawler-backend/internal/logging"
 	"url-crawler-backend/internal/model"
+	"url-crawler-backend/internal/store"
+	"url-crawler-backend/internal/urlnorm"
 
 	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type AddURLRequest struct {
-	URL string `json:"url" validate:"required,url"`
+	URL   string   `json:"url" validate:"required,url,max=2048"`
+	Notes string   `json:"notes" validate:"max=10000"`
+	Tags  []string `json:"tags" validate:"dive,max=64"`
 }
 
-func AddURL(c echo.Context) error {
+type BulkCrawlRequest struct {
+	IDs  []uint   `json:"ids" validate:"required_without=Tags,omitempty,min=1"`
+	Tags []string `json:"tags" validate:"required_without=IDs,omitempty,min=1,dive,max=64"`
+}
+
+func (s *Server) AddURL(c echo.Context) error {
+	membership, err := requireRole(c, model.RoleEditor)
+	if err != nil {
+		return err
+	}
+
 	req := new(AddURLRequest)
+	if err := bindRequest(c, req); err != nil {
+		return err
+	}
 
-	if err := c.Bind(req); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	normalized, err := validateURL(req.URL)
+	if err != nil {
+		return apperr.New(http.StatusBadRequest, apperr.CodeInvalidURL, err.Error())
 	}
+	hash := urlnorm.Hash(normalized)
 
-	if req.URL == "" {
-		return echo.NewHTTPError(http.StatusBadRequest, "URL is required")
+	tagNames, err := normalizeTagNames(req.Tags)
+	if err != nil {
+		return apperr.BadRequest(err.Error())
 	}
 
-	parsed, err := url.ParseRequestURI(req.URL)
-	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
-		return echo.NewHTTPError(http.StatusBadRequest, "Please include http:// or https:// in your URL.")
+	ctx := c.Request().Context()
+	if existing, err := s.URLs.FindByHash(ctx, membership.WorkspaceID, hash); err == nil {
+		return duplicateURL(existing)
 	}
 
 	urlRecord := model.URL{
-		URL:    req.URL,
-		Status: "queued",
+		WorkspaceID:   membership.WorkspaceID,
+		URL:           req.URL,
+		NormalizedURL: normalized,
+		URLHash:       &hash,
+		Notes:         req.Notes,
+		Status:        "queued",
 	}
 
-	if err := db.DB.Create(&urlRecord).Error; err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save URL")
+	if err := s.URLs.Create(ctx, &urlRecord, tagNames); err != nil {
+		// Another request may have added the same URL since the lookup.
+		if existing, err := s.URLs.FindByHash(ctx, membership.WorkspaceID, hash); err == nil {
+			return duplicateURL(existing)
+		}
+		return apperr.Internal(err, "Failed to save URL")
 	}
 
+	s.recordAudit(c, audit.Entry{Action: "url.create", TargetType: "url", TargetIDs: []uint{urlRecord.ID}})
+
 	return c.JSON(http.StatusCreated, urlRecord)
 }
 
-func GetURLs(c echo.Context) error {
-	var urls []model.URL
+// validateURL applies the rules every URL must pass before it is stored
+// and returns its normalized form.
+func validateURL(raw string) (string, error) {
+	if raw == "" {
+		return "", errors.New("URL is required")
+	}
+
+	parsed, err := url.ParseRequestURI(raw)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return "", errors.New("Please include http:// or https:// in your URL.")
+	}
+
+	normalized, err := urlnorm.Normalize(raw)
+	if err != nil {
+		return "", fmt.Errorf("Invalid URL: %v", err)
+	}
+
+	return normalized, nil
+}
+
+// duplicateURL reports that the workspace already has the URL. The
+// existing record is returned in the error details.
+func duplicateURL(existing model.URL) error {
+	message := "URL already exists"
+	if existing.DeletedAt.Valid {
+		message = "URL already exists in the trash"
+	}
+	return apperr.WithDetails(http.StatusConflict, apperr.CodeDuplicateURL, message, map[string]interface{}{
+		"in_trash": existing.DeletedAt.Valid,
+		"url":      existing,
+	})
+}
+
+func (s *Server) GetURLs(c echo.Context) error {
+	membership, err := requireRole(c, model.RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	filter, err := urlFilter(c, membership.WorkspaceID)
+	if err != nil {
+		return err
+	}
 
-	if err := db.DB.Find(&urls).Error; err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch URLs")
+	urls, err := s.URLs.List(c.Request().Context(), filter)
+	if err != nil {
+		return apperr.Internal(err, "Failed to fetch URLs")
 	}
 
 	return c.JSON(http.StatusOK, urls)
 }
 
-func StartBulkCrawl(c echo.Context) error {
-	var req DeleteURLsRequest // reuse the struct with IDs []uint
-	if err := c.Bind(&req); err != nil || len(req.IDs) == 0 {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload: must provide non-empty 'ids' array")
+// urlFilter reads the request's filter parameters: status and tag
+// (comma-separated, matching any), q (matched against URL and page title),
+// has_broken_links and has_login_form.
+func urlFilter(c echo.Context, workspaceID uint) (store.URLFilter, error) {
+	filter := store.URLFilter{WorkspaceID: workspaceID}
+
+	if tag := c.QueryParam("tag"); tag != "" {
+		names, err := normalizeTagNames(strings.Split(tag, ","))
+		if err != nil {
+			return filter, apperr.BadRequest(err.Error())
+		}
+		filter.Tags = names
+	}
+
+	if status := c.QueryParam("status"); status != "" {
+		filter.Statuses = strings.Split(status, ",")
+	}
+	filter.Query = strings.TrimSpace(c.QueryParam("q"))
+
+	if raw := c.QueryParam("has_broken_links"); raw != "" {
+		broken, err := strconv.ParseBool(raw)
+		if err != nil {
+			return filter, apperr.BadRequest("'has_broken_links' must be true or false")
+		}
+		filter.HasBrokenLinks = &broken
+	}
+	if raw := c.QueryParam("has_login_form"); raw != "" {
+		loginForm, err := strconv.ParseBool(raw)
+		if err != nil {
+			return filter, apperr.BadRequest("'has_login_form' must be true or false")
+		}
+		filter.HasLoginForm = &loginForm
+	}
+
+	return filter, nil
+}
+
+// StartBulkCrawl crawls the listed URLs and every URL carrying one of the
+// listed tags.
+func (s *Server) StartBulkCrawl(c echo.Context) error {
+	membership, err := requireRole(c, model.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	var req BulkCrawlRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	ids := req.IDs
+	if len(req.Tags) > 0 {
+		names, err := normalizeTagNames(req.Tags)
+		if err != nil {
+			return apperr.BadRequest(err.Error())
+		}
+		taggedIDs, err := s.URLs.IDs(ctx, store.URLFilter{WorkspaceID: membership.WorkspaceID, Tags: names})
+		if err != nil {
+			return apperr.Internal(err, "Failed to fetch URLs")
+		}
+		ids = mergeIDs(ids, taggedIDs)
+	}
+
+	// Load every URL in one query; an empty ID filter would match them all.
+	found := map[uint]model.URL{}
+	if len(ids) > 0 {
+		urls, err := s.URLs.List(ctx, store.URLFilter{WorkspaceID: membership.WorkspaceID, IDs: ids})
+		if err != nil {
+			return apperr.Internal(err, "Failed to fetch URLs")
+		}
+		for _, u := range urls {
+			found[u.ID] = u
+		}
 	}
 
-	var notFound []uint
-	for _, id := range req.IDs {
-		var urlRecord model.URL
-		if err := db.DB.First(&urlRecord, id).Error; err != nil {
+	var notFound, started []uint
+	for _, id := range ids {
+		urlRecord, ok := found[id]
+		if !ok {
 			notFound = append(notFound, id)
 			continue
 		}
-		urlRecord.Status = "running"
-		db.DB.Save(&urlRecord)
-		go func(urlModel model.URL) {
-			err := crawler.CrawlURL(&urlModel)
-			if err != nil {
-				urlModel.Status = "error"
-			} else {
-				urlModel.Status = "done"
-			}
-			urlModel.UpdatedAt = time.Now()
-			db.DB.Save(&urlModel)
-		}(urlRecord)
+		s.startCrawl(ctx, urlRecord, membership.UserID)
+		started = append(started, id)
 	}
 
+	s.recordAudit(c, audit.Entry{Action: "url.crawl", TargetType: "url", TargetIDs: started})
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"message":   "Bulk crawl started",
+		"started":   started,
 		"not_found": notFound,
 	})
 }
 
+// mergeIDs appends the IDs in extra that are not already in ids.
+func mergeIDs(ids, extra []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		seen[id] = true
+	}
+	for _, id := range extra {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (s *Server) StartCrawl(c echo.Context) error {
+	membership, err := requireRole(c, model.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return apperr.BadRequest("Invalid URL id")
+	}
+
+	urlRecord, err := s.URLs.Get(c.Request().Context(), membership.WorkspaceID, uint(id))
+	if err != nil {
+		return apperr.NotFound("URL not found")
+	}
+
+	s.startCrawl(c.Request().Context(), urlRecord, membership.UserID)
+
+	s.recordAudit(c, audit.Entry{Action: "url.crawl", TargetType: "url", TargetIDs: []uint{urlRecord.ID}})
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Crawl started",
+	})
+}
+
+// startCrawl crawls the URL in the background on behalf of userID. If the
+// user already has CrawlQuota crawls running, the URL stays queued until
+// one finishes. The crawl outlives the request that started it but is
+// traced as part of it, and logged with its request ID and the URL ID.
+func (s *Server) startCrawl(ctx context.Context, urlRecord model.URL, userID uint) {
+	s.runCrawl(ctx, urlRecord, strconv.FormatUint(uint64(userID), 10))
+}
+
+// runCrawl is startCrawl with the crawl charged to the CrawlQuota key
+// quotaKey.
+func (s *Server) runCrawl(ctx context.Context, urlRecord model.URL, quotaKey string) {
+	ctx = logging.With(context.WithoutCancel(ctx), "url_id", urlRecord.ID)
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "crawl", trace.WithAttributes(
+		attribute.Int64("url.id", int64(urlRecord.ID)),
+	))
+	release, ok := s.CrawlQuota.TryAcquire(quotaKey)
+	if ok {
+		urlRecord.Status = "running"
+	} else {
+		urlRecord.Status = "queued"
+	}
+	if err := s.URLs.BeginCrawl(ctx, urlRecord.ID, urlRecord.Status); err != nil {
+		slog.ErrorContext(ctx, "Failed to update URL status", "status", urlRecord.Status, "error", err)
+	}
+
+	started := s.crawls.Go(ctx, func(ctx context.Context) {
+		defer span.End()
+		if release == nil {
+			slog.DebugContext(ctx, "Crawl waiting for its crawl quota", "quota", quotaKey)
+			s.Metrics.CrawlQueueDepth.Inc()
+			var err error
+			release, err = s.CrawlQuota.Acquire(ctx, quotaKey)
+			s.Metrics.CrawlQueueDepth.Dec()
+			if err != nil {
+				// The server is shutting down; the URL is still queued.
+				return
+			}
+			urlRecord.Status = "running"
+			if err := s.URLs.SetStatus(ctx, urlRecord.ID, urlRecord.Status); err != nil {
+				slog.ErrorContext(ctx, "Failed to update URL status", "status", urlRecord.Status, "error", err)
+			}
+		}
+		defer release()
+		s.Metrics.ActiveCrawls.Inc()
+		defer s.Metrics.ActiveCrawls.Dec()
+
+		err := crawler.Crawl(ctx, &urlRecord, crawler.Options{Metrics: s.Metrics})
+		if ctx.Err() != nil {
+			// Cut short by a shutdown, so the failure says nothing about
+			// the page: queue the URL again instead.
+			slog.InfoContext(ctx, "Crawl interrupted by shutdown; URL queued again")
+			if err := s.URLs.SetStatus(context.WithoutCancel(ctx), urlRecord.ID, "queued"); err != nil {
+				slog.ErrorContext(ctx, "Failed to update URL status", "status", "queued", "error", err)
+			}
+			return
+		}
+		if err != nil {
+			urlRecord.Status = "error"
+		} else {
+			urlRecord.Status = "done"
+		}
+		now := time.Now()
+		urlRecord.CrawledAt = &now
+		urlRecord.UpdatedAt = now
+		if err := s.URLs.SaveCrawlResult(ctx, &urlRecord); err != nil {
+			slog.ErrorContext(ctx, "Failed to save crawl result", "error", err)
+		}
+	})
+	if !started {
+		defer span.End()
+		// The server is stopping; leave the URL queued for the next start.
+		if release != nil {
+			release()
+		}
+		if err := s.URLs.SetStatus(ctx, urlRecord.ID, "queued"); err != nil {
+			slog.ErrorContext(ctx, "Failed to update URL status", "status", "queued", "error", err)
+		}
+	}
+}
+
 type DeleteURLsRequest struct {
-	IDs []uint `json:"ids"`
+	IDs []uint `json:"ids" validate:"required,min=1"`
 }
 
-func DeleteURLs(c echo.Context) error {
+func (s *Server) DeleteURLs(c echo.Context) error {
+	membership, err := requireRole(c, model.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
 	var req DeleteURLsRequest
-	if err := c.Bind(&req); err != nil || len(req.IDs) == 0 {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload: must provide non-empty 'ids' array")
+	if err := bindRequest(c, &req); err != nil {
+		return err
 	}
-	if err := db.DB.Delete(&model.URL{}, req.IDs).Error; err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete URLs")
+	if err := s.URLs.Delete(c.Request().Context(), membership.WorkspaceID, req.IDs); err != nil {
+		return apperr.Internal(err, "Failed to delete URLs")
 	}
+
+	s.recordAudit(c, audit.Entry{Action: "url.delete", TargetType: "url", TargetIDs: req.IDs})
 	return c.NoContent(http.StatusNoContent)
 }
@@ -7,16 +7,20 @@ import (
 	"net/http/httptest"
 	"testing"
 
-	"url-crawler-backend/internal/db"
 	"url-crawler-backend/internal/model"
+	"url-crawler-backend/internal/store"
 
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
+var testMembership = model.Membership{WorkspaceID: 1, UserID: 1, Role: model.RoleOwner}
+
 func TestAddURL(t *testing.T) {
+	t.Parallel()
 	e := echo.New()
 	testDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	if err != nil {
@@ -101,12 +105,11 @@ func TestAddURL(t *testing.T) {
 			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 			rec := httptest.NewRecorder()
 			c := e.NewContext(req, rec)
+			SetMembership(c, testMembership)
 
-			originalDB := db.DB
-			db.DB = testDB
-			defer func() { db.DB = originalDB }()
+			s := NewServer(testDB)
 
-			err := AddURL(c)
+			err := s.AddURL(c)
 
 			if tt.expectedError {
 				assert.Error(t, err)
@@ -121,7 +124,72 @@ func TestAddURL(t *testing.T) {
 	}
 }
 
+func TestAddURLDuplicates(t *testing.T) {
+	t.Parallel()
+	e := echo.New()
+	testDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	testDB.AutoMigrate(&model.URL{}, &model.AuditLog{})
+
+	s := NewServer(testDB)
+
+	add := func(url string) *httptest.ResponseRecorder {
+		jsonBody, _ := json.Marshal(map[string]string{"url": url})
+		req := httptest.NewRequest(http.MethodPost, "/api/urls", bytes.NewBuffer(jsonBody))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		SetMembership(c, testMembership)
+		if err := s.AddURL(c); err != nil {
+			ErrorHandler(err, c)
+		}
+		return rec
+	}
+
+	rec := add("https://Example.com/")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var created model.URL
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	assert.Equal(t, "https://example.com/", created.NormalizedURL)
+
+	for _, duplicate := range []string{"https://example.com", "https://example.com/?utm_source=x", "https://EXAMPLE.com:443/#top"} {
+		rec = add(duplicate)
+		assert.Equal(t, http.StatusConflict, rec.Code, duplicate)
+
+		var response struct {
+			Code    string `json:"code"`
+			Details struct {
+				InTrash bool      `json:"in_trash"`
+				URL     model.URL `json:"url"`
+			} `json:"details"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.Equal(t, "duplicate_url", response.Code)
+		assert.Equal(t, created.ID, response.Details.URL.ID)
+		assert.False(t, response.Details.InTrash)
+	}
+
+	testDB.Delete(&model.URL{}, created.ID)
+	rec = add("https://example.com")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"in_trash":true`)
+
+	otherWorkspace := testMembership
+	otherWorkspace.WorkspaceID = 2
+	jsonBody, _ := json.Marshal(map[string]string{"url": "https://example.com"})
+	req := httptest.NewRequest(http.MethodPost, "/api/urls", bytes.NewBuffer(jsonBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec = httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	SetMembership(c, otherWorkspace)
+	assert.NoError(t, s.AddURL(c))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+}
+
 func TestGetURLs(t *testing.T) {
+	t.Parallel()
 	e := echo.New()
 	testDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	if err != nil {
@@ -130,8 +198,9 @@ func TestGetURLs(t *testing.T) {
 	testDB.AutoMigrate(&model.URL{})
 
 	testURLs := []model.URL{
-		{URL: "https://example1.com", Status: "done"},
-		{URL: "https://example2.com", Status: "queued"},
+		{WorkspaceID: 1, URL: "https://example1.com", Status: "done"},
+		{WorkspaceID: 1, URL: "https://example2.com", Status: "queued"},
+		{WorkspaceID: 2, URL: "https://other-workspace.com", Status: "done"},
 	}
 
 	for _, url := range testURLs {
@@ -141,12 +210,11 @@ func TestGetURLs(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/api/urls", nil)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
+	SetMembership(c, testMembership)
 
-	originalDB := db.DB
-	db.DB = testDB
-	defer func() { db.DB = originalDB }()
+	s := NewServer(testDB)
 
-	err = GetURLs(c)
+	err = s.GetURLs(c)
 
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusOK, rec.Code)
@@ -158,6 +226,7 @@ func TestGetURLs(t *testing.T) {
 }
 
 func TestStartCrawl(t *testing.T) {
+	t.Parallel()
 	e := echo.New()
 	testDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	if err != nil {
@@ -165,7 +234,7 @@ func TestStartCrawl(t *testing.T) {
 	}
 	testDB.AutoMigrate(&model.URL{})
 
-	testURL := model.URL{URL: "https://example.com", Status: "queued"}
+	testURL := model.URL{WorkspaceID: 1, URL: "https://example.com", Status: "queued"}
 	testDB.Create(&testURL)
 
 	tests := []struct {
@@ -195,12 +264,11 @@ func TestStartCrawl(t *testing.T) {
 			c := e.NewContext(req, rec)
 			c.SetParamNames("id")
 			c.SetParamValues(tt.urlID)
+			SetMembership(c, testMembership)
 
-			originalDB := db.DB
-			db.DB = testDB
-			defer func() { db.DB = originalDB }()
+			s := NewServer(testDB)
 
-			err := StartCrawl(c)
+			err := s.StartCrawl(c)
 
 			if tt.expectedError {
 				assert.Error(t, err)
@@ -214,3 +282,57 @@ func TestStartCrawl(t *testing.T) {
 		})
 	}
 }
+
+// The URL handlers only need the stores, so they run against the
+// in-memory implementation; the database only holds the audit log.
+func TestURLHandlersWithMemoryStore(t *testing.T) {
+	t.Parallel()
+	e := echo.New()
+	testDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	testDB.AutoMigrate(&model.AuditLog{})
+
+	s := NewServer(testDB)
+	s.URLs = store.NewMemoryURLStore()
+
+	call := func(method, path string, body interface{}, handler echo.HandlerFunc) *httptest.ResponseRecorder {
+		jsonBody, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(jsonBody))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		SetMembership(c, testMembership)
+		if err := handler(c); err != nil {
+			ErrorHandler(err, c)
+		}
+		return rec
+	}
+
+	rec := call(http.MethodPost, "/api/urls", map[string]interface{}{"url": "https://example.com/docs", "tags": []string{"Docs"}}, s.AddURL)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var docs model.URL
+	json.Unmarshal(rec.Body.Bytes(), &docs)
+	call(http.MethodPost, "/api/urls", map[string]string{"url": "https://blog.example.com"}, s.AddURL)
+
+	var urls []model.URL
+	rec = call(http.MethodGet, "/api/urls?tag=docs", nil, s.GetURLs)
+	require.Equal(t, http.StatusOK, rec.Code)
+	json.Unmarshal(rec.Body.Bytes(), &urls)
+	require.Len(t, urls, 1)
+	assert.Equal(t, docs.ID, urls[0].ID)
+
+	rec = call(http.MethodDelete, "/api/urls", map[string][]uint{"ids": {docs.ID}}, s.DeleteURLs)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = call(http.MethodGet, "/api/urls", nil, s.GetURLs)
+	json.Unmarshal(rec.Body.Bytes(), &urls)
+	assert.Len(t, urls, 1)
+
+	rec = call(http.MethodPost, "/api/urls", map[string]string{"url": "https://example.com/docs"}, s.AddURL)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"in_trash":true`)
+
+	var logged int64
+	testDB.Model(&model.AuditLog{}).Count(&logged)
+	assert.EqualValues(t, 3, logged)
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"url-crawler-backend/internal/version"
+
+	"github.com/labstack/echo/v4"
+)
+
+// readyTimeout bounds the readiness checks, so a hung database fails the
+// probe instead of stalling it.
+const readyTimeout = 2 * time.Second
+
+type healthResponse struct {
+	Status string `json:"status"`
+}
+
+type readinessResponse struct {
+	Status string `json:"status"`
+	// Checks maps each check to "ok" or the reason it failed.
+	Checks map[string]string `json:"checks"`
+}
+
+// Healthz reports that the process is up and serving requests. It checks
+// nothing else, so a database outage does not get the server restarted.
+func Healthz(c echo.Context) error {
+	return c.JSON(http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// Readyz reports whether the server can take traffic: the database
+// answers, its schema is up to date and crawls are being accepted. It
+// responds 503 with the failing checks otherwise.
+func (s *Server) Readyz(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), readyTimeout)
+	defer cancel()
+
+	checks := map[string]error{
+		"database":   s.Health.Ping(ctx),
+		"migrations": s.checkMigrations(ctx),
+		"crawler":    nil,
+	}
+	if !s.crawls.Running() {
+		checks["crawler"] = errors.New("not accepting crawls")
+	}
+
+	res := readinessResponse{Status: "ready", Checks: make(map[string]string, len(checks))}
+	status := http.StatusOK
+	for name, err := range checks {
+		if err != nil {
+			res.Checks[name] = err.Error()
+			res.Status = "not ready"
+			status = http.StatusServiceUnavailable
+		} else {
+			res.Checks[name] = "ok"
+		}
+	}
+	return c.JSON(status, res)
+}
+
+// checkMigrations confirms every migration has been applied. Migrations
+// only move forward while the server runs, so once they have been it does
+// not ask the database again.
+func (s *Server) checkMigrations(ctx context.Context) error {
+	if s.migrated.Load() {
+		return nil
+	}
+	if err := s.Health.CheckMigrations(ctx); err != nil {
+		return err
+	}
+	s.migrated.Store(true)
+	return nil
+}
+
+// GetVersion reports the build of the running server.
+func GetVersion(c echo.Context) error {
+	return c.JSON(http.StatusOK, version.Get())
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"url-crawler-backend/internal/migrate"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	t.Parallel()
+	testDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, _ := testDB.DB()
+	sqlDB.SetMaxOpenConns(1)
+
+	s := NewServer(testDB)
+	e := echo.New()
+	s.RegisterRoutes(e)
+
+	// No token is needed for any of them.
+	get := func(path string) (int, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body), path)
+		return rec.Code, body
+	}
+
+	code, body := get("/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", body["status"])
+
+	code, body = get("/version")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "dev", body["version"])
+	assert.NotEmpty(t, body["go_version"])
+
+	code, body = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	checks := body["checks"].(map[string]interface{})
+	assert.Equal(t, "ok", checks["database"])
+	assert.Contains(t, checks["migrations"], "pending migrations")
+
+	_, err = migrate.Up(testDB)
+	require.NoError(t, err)
+	code, body = get("/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", body["status"])
+
+	s.crawls.Stop()
+	code, body = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not accepting crawls", body["checks"].(map[string]interface{})["crawler"])
+
+	sqlDB.Close()
+	code, body = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.NotEqual(t, "ok", body["checks"].(map[string]interface{})["database"])
+	// /healthz does not depend on the database.
+	code, _ = get("/healthz")
+	assert.Equal(t, http.StatusOK, code)
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"url-crawler-backend/internal/apperr"
+	"url-crawler-backend/internal/idempotency"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed marks a response replayed from an earlier
+	// request.
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	// idempotencyWait bounds how long a retry waits for the original
+	// request to finish.
+	idempotencyWait = 30 * time.Second
+
+	// maxIdempotentBody is the largest body read to fingerprint a request:
+	// an import file plus room for its multipart framing.
+	maxIdempotentBody = maxImportBytes + 1<<20
+)
+
+// Idempotency makes POST requests carrying an Idempotency-Key header safe
+// to retry. The first request with a key runs normally and its response is
+// stored; later requests with the same key and body get that response back
+// without running the handler. A retry that arrives while the first request
+// is still running waits for it. Server errors are not stored, so the
+// request can be retried.
+func (s *Server) Idempotency(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		key := req.Header.Get(HeaderIdempotencyKey)
+		if key == "" || req.Method != http.MethodPost {
+			return next(c)
+		}
+		if len(key) > idempotency.MaxKeyLength {
+			return apperr.BadRequest(HeaderIdempotencyKey + " must be at most " + strconv.Itoa(idempotency.MaxKeyLength) + " characters")
+		}
+
+		userID, err := currentUserID(c)
+		if err != nil {
+			return err
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Response(), req.Body, maxIdempotentBody))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return apperr.New(http.StatusRequestEntityTooLarge, apperr.CodePayloadTooLarge, fmt.Sprintf("Request bodies are limited to %d MB", maxIdempotentBody>>20))
+		}
+		if err != nil {
+			return apperr.BadRequest("Failed to read request body")
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := idempotency.Fingerprint(
+			[]byte(req.Method), []byte(req.URL.RequestURI()), []byte(req.Header.Get(HeaderWorkspaceID)), body,
+		)
+
+		ctx, cancel := context.WithTimeout(req.Context(), idempotencyWait)
+		record, err := s.IdempotencyKeys.Acquire(ctx, userID, key, fingerprint, s.IdempotencyTTL)
+		cancel()
+		switch {
+		case errors.Is(err, idempotency.ErrMismatch):
+			return apperr.New(http.StatusUnprocessableEntity, "idempotency_key_reused", "Idempotency-Key was already used for a different request")
+		case errors.Is(err, idempotency.ErrInProgress):
+			return apperr.New(http.StatusConflict, "idempotency_key_in_progress", "A request with this Idempotency-Key is still in progress")
+		case err != nil:
+			return apperr.Internal(err, "Failed to check idempotency key")
+		}
+
+		if record.StatusCode != 0 {
+			c.Response().Header().Set(HeaderIdempotentReplayed, "true")
+			return c.Blob(record.StatusCode, record.ContentType, record.Body)
+		}
+
+		res := c.Response()
+		recorder := &bodyRecorder{ResponseWriter: res.Writer}
+		res.Writer = recorder
+		// Error responses are written here so they are stored too.
+		if err := next(c); err != nil {
+			c.Error(err)
+		}
+		res.Writer = recorder.ResponseWriter
+
+		// The key is settled even if the client has gone away, so retries
+		// do not wait for it.
+		ctx = context.WithoutCancel(req.Context())
+		if res.Status >= http.StatusInternalServerError {
+			err = s.IdempotencyKeys.Release(ctx, record)
+		} else {
+			err = s.IdempotencyKeys.Complete(ctx, record, res.Status, res.Header().Get(echo.HeaderContentType), recorder.body.Bytes())
+		}
+		if err != nil {
+			slog.ErrorContext(c.Request().Context(), "Failed to store response for idempotency key", "key_id", record.ID, "error", err)
+		}
+		return nil
+	}
+}
+
+// bodyRecorder copies the response body as it is written.
+type bodyRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *bodyRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *bodyRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"url-crawler-backend/internal/model"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func postWithKey(s *Server, userID uint, key, path string, body interface{}, handler echo.HandlerFunc) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
+	jsonBody, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(jsonBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(HeaderIdempotencyKey, key)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user", &jwt.Token{Claims: jwt.MapClaims{"id": float64(userID)}})
+	if err := s.Idempotency(handler)(c); err != nil {
+		e.HTTPErrorHandler(err, c)
+	}
+	return rec
+}
+
+func TestIdempotency(t *testing.T) {
+	t.Parallel()
+	testDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, _ := testDB.DB()
+	sqlDB.SetMaxOpenConns(1)
+	testDB.AutoMigrate(&model.URL{}, &model.Tag{}, &model.User{}, &model.Workspace{}, &model.Membership{}, &model.AuditLog{}, &model.IdempotencyKey{})
+
+	s := NewServer(testDB)
+
+	alice := model.User{Username: "alice"}
+	bob := model.User{Username: "bob"}
+	testDB.Create(&alice)
+	testDB.Create(&bob)
+	addURL := s.WorkspaceMiddleware(s.AddURL)
+
+	first := postWithKey(s, alice.ID, "key-1", "/api/urls", map[string]string{"url": "https://example.com"}, addURL)
+	require.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(HeaderIdempotentReplayed))
+
+	replay := postWithKey(s, alice.ID, "key-1", "/api/urls", map[string]string{"url": "https://example.com"}, addURL)
+	assert.Equal(t, http.StatusCreated, replay.Code)
+	assert.Equal(t, "true", replay.Header().Get(HeaderIdempotentReplayed))
+	assert.Equal(t, first.Body.String(), replay.Body.String())
+	assert.Equal(t, echo.MIMEApplicationJSON, replay.Header().Get(echo.HeaderContentType))
+
+	rec := postWithKey(s, alice.ID, "key-1", "/api/urls", map[string]string{"url": "https://other.example"}, addURL)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"idempotency_key_reused"`)
+
+	// Keys are per user.
+	rec = postWithKey(s, bob.ID, "key-1", "/api/urls", map[string]string{"url": "https://example.com"}, addURL)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Empty(t, rec.Header().Get(HeaderIdempotentReplayed))
+
+	// Error responses are replayed too.
+	rec = postWithKey(s, alice.ID, "key-2", "/api/urls", map[string]string{"url": "not a url"}, addURL)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = postWithKey(s, alice.ID, "key-2", "/api/urls", map[string]string{"url": "not a url"}, addURL)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get(HeaderIdempotentReplayed))
+
+	// Concurrent retries run the handler once.
+	var wg sync.WaitGroup
+	codes := make([]int, 5)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = postWithKey(s, alice.ID, "key-3", "/api/urls", map[string]string{"url": "https://concurrent.example"}, addURL).Code
+		}(i)
+	}
+	wg.Wait()
+	for _, code := range codes {
+		assert.Equal(t, http.StatusCreated, code)
+	}
+
+	var count int64
+	testDB.Model(&model.URL{}).Where("url LIKE ?", "https://concurrent.example%").Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestIdempotencyLimitsBody(t *testing.T) {
+	t.Parallel()
+	called := false
+	handler := func(c echo.Context) error {
+		called = true
+		return c.NoContent(http.StatusNoContent)
+	}
+
+	body := map[string]string{"notes": strings.Repeat("x", maxIdempotentBody)}
+	rec := postWithKey(NewServer(nil), 1, "key-1", "/api/urls/1/notes", body, handler)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"payload_too_large"`)
+	assert.False(t, called)
+}
@@ -0,0 +1,345 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"url-crawler-backend/internal/apperr"
+	"url-crawler-backend/internal/audit"
+	"url-crawler-backend/internal/model"
+	"url-crawler-backend/internal/urlnorm"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	maxImportBytes = 5 << 20
+	maxImportRows  = 10000
+)
+
+const (
+	importCreated   = "created"
+	importDuplicate = "duplicate"
+	importInvalid   = "invalid"
+)
+
+type importRow struct {
+	Row    int      `json:"row"`
+	URL    string   `json:"url"`
+	Status string   `json:"status"`
+	ID     uint     `json:"id,omitempty"`
+	Error  string   `json:"error,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+	Notes  string   `json:"notes,omitempty"`
+
+	normalized string
+	hash       string
+}
+
+// ImportURLs adds every URL from an uploaded CSV or newline-delimited text
+// file to the active workspace, with the tags and notes from the CSV's
+// optional columns. Rows are validated like AddURL and reported
+// individually as created, duplicate or invalid. With crawl=true the
+// created URLs are crawled right away.
+func (s *Server) ImportURLs(c echo.Context) error {
+	membership, err := requireRole(c, model.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return apperr.BadRequest("Upload the file in a multipart field named 'file'")
+	}
+	if fileHeader.Size > maxImportBytes {
+		return apperr.New(http.StatusRequestEntityTooLarge, apperr.CodePayloadTooLarge, fmt.Sprintf("Import files are limited to %d MB", maxImportBytes>>20))
+	}
+
+	crawl := false
+	if raw := c.FormValue("crawl"); raw != "" {
+		if crawl, err = strconv.ParseBool(raw); err != nil {
+			return apperr.BadRequest("'crawl' must be true or false")
+		}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return apperr.BadRequest("Failed to read upload")
+	}
+	defer file.Close()
+
+	var rows []*importRow
+	if isCSVUpload(fileHeader.Filename, fileHeader.Header.Get(echo.HeaderContentType)) {
+		rows, err = parseCSVImport(file)
+	} else {
+		rows, err = parseTextImport(file)
+	}
+	if err != nil {
+		return apperr.BadRequest(err.Error())
+	}
+	if len(rows) > maxImportRows {
+		return apperr.BadRequest(fmt.Sprintf("Import files are limited to %d rows", maxImportRows))
+	}
+
+	created, err := s.importRows(c.Request().Context(), membership.WorkspaceID, rows)
+	if err != nil {
+		return apperr.Internal(err, "Failed to save URLs")
+	}
+
+	ids := make([]uint, len(created))
+	for i, u := range created {
+		ids[i] = u.ID
+	}
+	if len(ids) > 0 {
+		s.recordAudit(c, audit.Entry{Action: "url.import", TargetType: "url", TargetIDs: ids, Detail: fileHeader.Filename})
+	}
+	if crawl && len(created) > 0 {
+		for _, u := range created {
+			s.startCrawl(c.Request().Context(), u, membership.UserID)
+		}
+		s.recordAudit(c, audit.Entry{Action: "url.crawl", TargetType: "url", TargetIDs: ids})
+	}
+
+	summary := map[string]int{importCreated: 0, importDuplicate: 0, importInvalid: 0}
+	for _, row := range rows {
+		summary[row.Status]++
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"created":    summary[importCreated],
+		"duplicates": summary[importDuplicate],
+		"invalid":    summary[importInvalid],
+		"crawling":   crawl && len(created) > 0,
+		"rows":       rows,
+	})
+}
+
+// importRows validates rows, skips URLs already in the workspace (including
+// its trash) or earlier in the file, and inserts the rest in one
+// transaction. URLs are compared by their normalized form.
+func (s *Server) importRows(ctx context.Context, workspaceID uint, rows []*importRow) ([]model.URL, error) {
+	var hashes []string
+	for _, row := range rows {
+		normalized, err := validateURL(row.URL)
+		if err == nil {
+			err = validateImportRow(row)
+		}
+		if err == nil {
+			row.Tags, err = normalizeTagNames(row.Tags)
+		}
+		if err != nil {
+			row.Status = importInvalid
+			row.Error = err.Error()
+			continue
+		}
+		row.normalized = normalized
+		row.hash = urlnorm.Hash(normalized)
+		hashes = append(hashes, row.hash)
+	}
+
+	existing, err := s.URLs.FindByHashes(ctx, workspaceID, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []*importRow
+	seen := map[string]bool{}
+	for _, row := range rows {
+		if row.Status == importInvalid {
+			continue
+		}
+		if u, ok := existing[row.hash]; ok {
+			markImportDuplicate(row, u)
+			continue
+		}
+		if seen[row.hash] {
+			row.Status = importDuplicate
+			row.Error = "repeated earlier in the file"
+			continue
+		}
+		seen[row.hash] = true
+		pending = append(pending, row)
+	}
+
+	for len(pending) > 0 {
+		created, err := s.insertImportedRows(ctx, workspaceID, pending)
+		if err == nil {
+			for i, row := range pending {
+				row.Status = importCreated
+				row.ID = created[i].ID
+			}
+			return created, nil
+		}
+
+		// Another request may have added some of the URLs since the lookup.
+		// Report those as duplicates and insert the rest again.
+		pendingHashes := make([]string, len(pending))
+		for i, row := range pending {
+			pendingHashes[i] = row.hash
+		}
+		added, lookupErr := s.URLs.FindByHashes(ctx, workspaceID, pendingHashes)
+		if lookupErr != nil || len(added) == 0 {
+			return nil, err
+		}
+		remaining := pending[:0]
+		for _, row := range pending {
+			if u, ok := added[row.hash]; ok {
+				markImportDuplicate(row, u)
+				continue
+			}
+			remaining = append(remaining, row)
+		}
+		pending = remaining
+	}
+	return nil, nil
+}
+
+// validateImportRow applies the length limits of AddURLRequest to a row.
+func validateImportRow(row *importRow) error {
+	err := defaultValidator.validate.Struct(AddURLRequest{URL: row.URL, Notes: row.Notes, Tags: row.Tags})
+	var invalid validator.ValidationErrors
+	if errors.As(err, &invalid) {
+		return errors.New(fieldError(invalid[0]).Message)
+	}
+	return err
+}
+
+func markImportDuplicate(row *importRow, existing model.URL) {
+	row.Status = importDuplicate
+	row.ID = existing.ID
+	if existing.DeletedAt.Valid {
+		row.Error = "in the trash"
+	}
+}
+
+// insertImportedRows creates a URL with its tags for every row, all or
+// none.
+func (s *Server) insertImportedRows(ctx context.Context, workspaceID uint, rows []*importRow) ([]model.URL, error) {
+	created := make([]model.URL, len(rows))
+	tags := make([][]string, len(rows))
+	for i, row := range rows {
+		created[i] = model.URL{
+			WorkspaceID:   workspaceID,
+			URL:           row.URL,
+			NormalizedURL: row.normalized,
+			URLHash:       &row.hash,
+			Notes:         row.Notes,
+			Status:        "queued",
+		}
+		tags[i] = row.Tags
+	}
+	if err := s.URLs.CreateAll(ctx, created, tags); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func isCSVUpload(filename, contentType string) bool {
+	if strings.EqualFold(filepath.Ext(filename), ".csv") {
+		return true
+	}
+	return strings.HasPrefix(contentType, "text/csv") || strings.Contains(contentType, "ms-excel")
+}
+
+// parseCSVImport reads a CSV file. When the first row names a "url" column,
+// optional "tags" and "notes" columns are read too; otherwise the first
+// column of every row is the URL.
+func parseCSVImport(r io.Reader) ([]*importRow, error) {
+	reader := csv.NewReader(bufio.NewReader(r))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid CSV: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("The file is empty")
+	}
+
+	urlCol, tagsCol, notesCol := 0, -1, -1
+	first := 0
+	for i, name := range records[0] {
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\uFEFF"))) {
+		case "url", "urls":
+			urlCol, first = i, 1
+		case "tags", "tag":
+			tagsCol = i
+		case "notes", "note":
+			notesCol = i
+		}
+	}
+	if first == 0 {
+		tagsCol, notesCol = -1, -1
+	}
+
+	var rows []*importRow
+	for i, record := range records[first:] {
+		if isBlankRecord(record) {
+			continue
+		}
+		row := &importRow{Row: i + first + 1, URL: column(record, urlCol)}
+		if tags := column(record, tagsCol); tags != "" {
+			row.Tags = splitTags(tags)
+		}
+		row.Notes = column(record, notesCol)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseTextImport reads one URL per line, skipping blank lines and lines
+// starting with '#'.
+func parseTextImport(r io.Reader) ([]*importRow, error) {
+	var rows []*importRow
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\uFEFF"))
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		rows = append(rows, &importRow{Row: line, URL: text})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to read file: %v", err)
+	}
+	return rows, nil
+}
+
+func column(record []string, i int) string {
+	if i < 0 || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+func isBlankRecord(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func splitTags(raw string) []string {
+	var tags []string
+	for _, tag := range strings.FieldsFunc(raw, func(r rune) bool { return r == ';' || r == ',' || r == '|' }) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
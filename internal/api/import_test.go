@@ -0,0 +1,201 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"url-crawler-backend/internal/model"
+	"url-crawler-backend/internal/urlnorm"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type importResponse struct {
+	Created    int         `json:"created"`
+	Duplicates int         `json:"duplicates"`
+	Invalid    int         `json:"invalid"`
+	Rows       []importRow `json:"rows"`
+}
+
+func uploadAs(s *Server, userID uint, filename, content string, fields map[string]string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("file", filename)
+	part.Write([]byte(content))
+	for name, value := range fields {
+		writer.WriteField(name, value)
+	}
+	writer.Close()
+
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
+	req := httptest.NewRequest(http.MethodPost, "/api/urls/import", &body)
+	req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user", &jwt.Token{Claims: jwt.MapClaims{"id": float64(userID)}})
+
+	if err := s.WorkspaceMiddleware(s.ImportURLs)(c); err != nil {
+		e.HTTPErrorHandler(err, c)
+	}
+	return rec
+}
+
+func setupImport(t *testing.T) (*Server, *gorm.DB, model.User) {
+	testDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	testDB.AutoMigrate(&model.URL{}, &model.Tag{}, &model.User{}, &model.Workspace{}, &model.Membership{}, &model.AuditLog{})
+
+	s := NewServer(testDB)
+
+	user := model.User{Username: "alice"}
+	testDB.Create(&user)
+	return s, testDB, user
+}
+
+func TestImportURLsFromCSV(t *testing.T) {
+	t.Parallel()
+	s, testDB, user := setupImport(t)
+
+	rec := callAs(user.ID, "", http.MethodPost, "/api/urls", map[string]string{"url": "https://existing.example"}, s.WorkspaceMiddleware(s.AddURL))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	csv := "url,tags,notes\n" +
+		"https://a.example,seo;blog,first\n" +
+		"https://existing.example,,\n" +
+		"not a url,,\n" +
+		"https://a.example,,\n" +
+		",,\n" +
+		"https://b.example,,\n"
+	rec = uploadAs(s, user.ID, "urls.csv", csv, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var response importResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, 2, response.Created)
+	assert.Equal(t, 2, response.Duplicates)
+	assert.Equal(t, 1, response.Invalid)
+	require.Len(t, response.Rows, 5)
+
+	assert.Equal(t, 2, response.Rows[0].Row)
+	assert.Equal(t, importCreated, response.Rows[0].Status)
+	assert.Equal(t, []string{"seo", "blog"}, response.Rows[0].Tags)
+	assert.Equal(t, "first", response.Rows[0].Notes)
+	assert.Equal(t, importDuplicate, response.Rows[1].Status)
+	assert.NotZero(t, response.Rows[1].ID)
+	assert.Equal(t, importInvalid, response.Rows[2].Status)
+	assert.NotEmpty(t, response.Rows[2].Error)
+	assert.Equal(t, importDuplicate, response.Rows[3].Status)
+	assert.Equal(t, 7, response.Rows[4].Row)
+
+	var count int64
+	testDB.Model(&model.URL{}).Count(&count)
+	assert.Equal(t, int64(3), count)
+
+	var imported model.URL
+	require.NoError(t, testDB.Preload("Tags").First(&imported, response.Rows[0].ID).Error)
+	assert.Equal(t, "first", imported.Notes)
+	require.Len(t, imported.Tags, 2)
+	assert.ElementsMatch(t, []string{"seo", "blog"}, []string{imported.Tags[0].Name, imported.Tags[1].Name})
+
+	var logged model.AuditLog
+	require.NoError(t, testDB.Where("action = ?", "url.import").First(&logged).Error)
+	assert.Len(t, logged.TargetIDs, 2)
+}
+
+func TestImportURLsFromText(t *testing.T) {
+	t.Parallel()
+	s, _, user := setupImport(t)
+
+	text := "# exported list\nhttps://a.example\n\nhttps://b.example\nftp-less.example\nhttps://A.example/?utm_source=news\n"
+	rec := uploadAs(s, user.ID, "urls.txt", text, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var response importResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, 2, response.Created)
+	assert.Equal(t, 1, response.Invalid)
+	assert.Equal(t, 1, response.Duplicates, "duplicates are detected after normalization")
+	assert.Equal(t, 5, response.Rows[2].Row)
+
+	rec = uploadAs(s, user.ID, "urls.txt", text, map[string]string{"crawl": "maybe"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestImportURLsAppliesAddURLLimits(t *testing.T) {
+	t.Parallel()
+	s, _, user := setupImport(t)
+
+	csv := "url,tags,notes\n" +
+		"https://example.com/" + strings.Repeat("a", 2048) + ",,\n" +
+		"https://notes.example,," + strings.Repeat("n", 10001) + "\n" +
+		"https://ok.example,,short\n"
+	rec := uploadAs(s, user.ID, "urls.csv", csv, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var response importResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.Created)
+	assert.Equal(t, 2, response.Invalid)
+	assert.Equal(t, "url must have at most 2048 characters", response.Rows[0].Error)
+	assert.Equal(t, "notes must have at most 10000 characters", response.Rows[1].Error)
+}
+
+func TestImportURLsReportsConcurrentAddAsDuplicate(t *testing.T) {
+	t.Parallel()
+	s, testDB, user := setupImport(t)
+	membership, err := s.defaultMembership(context.Background(), user.ID)
+	require.NoError(t, err)
+
+	// Add one of the URLs right after the import has looked them up, as a
+	// concurrent AddURL would.
+	var raced model.URL
+	added := false
+	require.NoError(t, testDB.Callback().Query().After("gorm:query").Register("test:race", func(db *gorm.DB) {
+		if added || db.Statement.Table != "urls" {
+			return
+		}
+		added = true
+		raced = model.URL{WorkspaceID: membership.WorkspaceID, URL: "https://b.example", Status: "queued"}
+		normalized, _ := validateURL(raced.URL)
+		hash := urlnorm.Hash(normalized)
+		raced.NormalizedURL, raced.URLHash = normalized, &hash
+		require.NoError(t, db.Session(&gorm.Session{NewDB: true}).Create(&raced).Error)
+	}))
+
+	rec := uploadAs(s, user.ID, "urls.txt", "https://a.example\nhttps://b.example\n", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var response importResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.Created)
+	assert.Equal(t, 1, response.Duplicates)
+	assert.Equal(t, importDuplicate, response.Rows[1].Status)
+	assert.Equal(t, raced.ID, response.Rows[1].ID)
+
+	var count int64
+	testDB.Model(&model.URL{}).Count(&count)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestImportURLsRequiresEditor(t *testing.T) {
+	t.Parallel()
+	s, testDB, user := setupImport(t)
+	workspace := model.Workspace{Name: "Team"}
+	testDB.Create(&workspace)
+	testDB.Create(&model.Membership{WorkspaceID: workspace.ID, UserID: user.ID, Role: model.RoleViewer})
+
+	rec := uploadAs(s, user.ID, "urls.txt", "https://a.example\n", nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"log/slog"
+	"time"
+
+	"url-crawler-backend/internal/logging"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// RequestID gives every request an ID, taken from its X-Request-ID header
+// or generated, and returns it in the same response header. Everything
+// logged with the request context, including the crawls the request
+// starts, carries it as request_id.
+var RequestID = middleware.RequestIDWithConfig(middleware.RequestIDConfig{
+	RequestIDHandler: func(c echo.Context, id string) {
+		req := c.Request()
+		c.SetRequest(req.WithContext(logging.With(req.Context(), "request_id", id)))
+	},
+})
+
+// quietRoutes are polled by load balancers and Prometheus, so their
+// requests are only logged at debug level.
+var quietRoutes = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// RequestLog logs every request once it has been served. It must be
+// installed before RenderErrors to see the status of errors; their causes
+// are logged by ErrorHandler.
+func RequestLog(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+		err := next(c)
+
+		level := slog.LevelInfo
+		if quietRoutes[c.Path()] {
+			level = slog.LevelDebug
+		}
+		req, res := c.Request(), c.Response()
+		attrs := []any{
+			"method", req.Method,
+			"path", req.URL.Path,
+			"route", c.Path(),
+			"status", res.Status,
+			"bytes", res.Size,
+			"duration", time.Since(start),
+			"remote_ip", c.RealIP(),
+		}
+		slog.Log(req.Context(), level, "Request served", attrs...)
+		return err
+	}
+}
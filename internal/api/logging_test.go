@@ -0,0 +1,140 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"url-crawler-backend/internal/logging"
+	"url-crawler-backend/internal/model"
+	"url-crawler-backend/internal/store"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// logBuffer is safe to read while crawls left running by other tests log
+// to it.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// captureLogs sends the default logger's output to the returned buffer,
+// one JSON object per record, for the duration of the test. Tests that use
+// it replace the process-wide logger, so they do not call t.Parallel and
+// run before the parallel tests start.
+func captureLogs(t *testing.T) *logBuffer {
+	var buf logBuffer
+	logger, err := logging.New(&buf, logging.Config{Level: "debug", Format: logging.FormatJSON})
+	require.NoError(t, err)
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// logRecords returns the records logged with the given request ID.
+func logRecords(t *testing.T, buf *logBuffer, requestID string) []map[string]any {
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record), line)
+		if record["request_id"] == requestID {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+func TestRequestLog(t *testing.T) {
+	logs := captureLogs(t)
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
+	e.Use(RequestID)
+	e.Use(RequestLog)
+	e.Use(RenderErrors)
+	NewServer(nil).RegisterRoutes(e)
+	e.GET("/boom", func(c echo.Context) error {
+		return assert.AnError
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/boom", nil)
+	req.Header.Set(echo.HeaderXRequestID, "req-1")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "req-1", rec.Header().Get(echo.HeaderXRequestID))
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	generated := rec.Header().Get(echo.HeaderXRequestID)
+	require.NotEmpty(t, generated)
+
+	records := append(logRecords(t, logs, "req-1"), logRecords(t, logs, generated)...)
+	require.Len(t, records, 3)
+
+	// The cause is logged once, by the error handler; the request log only
+	// has the status.
+	assert.Equal(t, "ERROR", records[0]["level"])
+	assert.Equal(t, "Request failed", records[0]["msg"])
+	assert.Equal(t, assert.AnError.Error(), records[0]["error"])
+
+	assert.Equal(t, "INFO", records[1]["level"])
+	assert.Equal(t, "Request served", records[1]["msg"])
+	assert.Equal(t, "/boom", records[1]["route"])
+	assert.EqualValues(t, http.StatusInternalServerError, records[1]["status"])
+	assert.NotContains(t, records[1], "error")
+	assert.Contains(t, records[1], "duration")
+
+	// Health checks are only logged at debug level.
+	assert.Equal(t, "DEBUG", records[2]["level"])
+	assert.NotContains(t, records[2], "error")
+}
+
+func TestStartCrawlLogsRequestID(t *testing.T) {
+	logs := captureLogs(t)
+	s := NewServer(nil)
+	s.URLs = store.NewMemoryURLStore()
+	u := model.URL{WorkspaceID: 1, URL: "ftp://example.com"}
+	require.NoError(t, s.URLs.Create(context.Background(), &u, nil))
+
+	s.startCrawl(logging.With(context.Background(), "request_id", "req-1"), u, 7)
+	require.Eventually(t, func() bool {
+		saved, err := s.URLs.Get(context.Background(), 1, u.ID)
+		return err == nil && saved.Status == "error"
+	}, time.Second, 10*time.Millisecond)
+
+	records := logRecords(t, logs, "req-1")
+	var failed map[string]any
+	for _, record := range records {
+		if record["msg"] == "Crawl failed" {
+			failed = record
+		}
+	}
+	require.NotNil(t, failed, "no crawl record in %v", records)
+	assert.EqualValues(t, u.ID, failed["url_id"])
+	assert.Equal(t, "ftp://example.com", failed["url"])
+	assert.Equal(t, "invalid_url", failed["error_class"])
+	assert.Contains(t, failed["phases"], "fetch")
+}
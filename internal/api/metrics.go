@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MetricsMiddleware counts and times every request by its route, so paths
+// with IDs share one series. Requests matching no route are counted
+// together. It must be installed before RenderErrors to see the status of
+// errors.
+func (s *Server) MetricsMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+		err := next(c)
+
+		route := c.Path()
+		if c.Response().Status == http.StatusNotFound && (route == "" || route == "/*") {
+			route = "unmatched"
+		}
+		method := c.Request().Method
+		s.Metrics.HTTPRequests.WithLabelValues(method, route, strconv.Itoa(c.Response().Status)).Inc()
+		s.Metrics.HTTPDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// MetricsHandler serves the Prometheus metrics.
+func (s *Server) MetricsHandler(c echo.Context) error {
+	s.Metrics.Handler().ServeHTTP(c.Response(), c.Request())
+	return nil
+}
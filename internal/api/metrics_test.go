@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+	s := NewServer(nil)
+	e := echo.New()
+	e.Use(s.MetricsMiddleware, RenderErrors)
+	s.RegisterRoutes(e)
+
+	call := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+	requests := func(method, route, status string) float64 {
+		return testutil.ToFloat64(s.Metrics.HTTPRequests.WithLabelValues(method, route, status))
+	}
+
+	assert.Equal(t, http.StatusOK, call(http.MethodGet, "/healthz").Code)
+	// Rejected for lack of a token, but still counted under its route.
+	assert.Equal(t, http.StatusBadRequest, call(http.MethodPatch, "/api/urls/7").Code)
+	assert.Equal(t, http.StatusNotFound, call(http.MethodGet, "/no/such/page").Code)
+
+	assert.Equal(t, 1.0, requests("GET", "/healthz", "200"))
+	assert.Equal(t, 1.0, requests("PATCH", "/api/urls/:id", "400"))
+	assert.Equal(t, 1.0, requests("GET", "unmatched", "404"))
+
+	rec := call(http.MethodGet, "/metrics")
+	require.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, `url_crawler_http_request_duration_seconds_count{method="GET",route="/healthz"}`)
+	assert.NotContains(t, body, "/no/such/page")
+	assert.Contains(t, body, "url_crawler_crawl_queue_depth")
+	assert.Contains(t, body, "go_goroutines")
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"url-crawler-backend/internal/apperr"
+	"url-crawler-backend/internal/audit"
+	"url-crawler-backend/internal/model"
+	"url-crawler-backend/internal/sso"
+	"url-crawler-backend/internal/store"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	oidcStateCookie    = "oidc_state"
+	oidcNonceCookie    = "oidc_nonce"
+	oidcVerifierCookie = "oidc_verifier"
+	oidcCookiePath     = "/auth/oidc"
+	oidcFlowTTL        = 10 * time.Minute
+)
+
+func (s *Server) OIDCLogin(c echo.Context) error {
+	state, err := randomToken()
+	if err != nil {
+		return apperr.Internal(err, "Failed to start login")
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return apperr.Internal(err, "Failed to start login")
+	}
+	verifier := sso.GenerateVerifier()
+
+	maxAge := int(oidcFlowTTL.Seconds())
+	setOIDCCookie(c, oidcStateCookie, state, maxAge)
+	setOIDCCookie(c, oidcNonceCookie, nonce, maxAge)
+	setOIDCCookie(c, oidcVerifierCookie, verifier, maxAge)
+
+	return c.Redirect(http.StatusFound, s.SSO.AuthCodeURL(state, nonce, verifier))
+}
+
+func (s *Server) OIDCCallback(c echo.Context) error {
+	if errParam := c.QueryParam("error"); errParam != "" {
+		return apperr.Unauthorized("Login was rejected by the identity provider: " + errParam)
+	}
+
+	state, err := c.Cookie(oidcStateCookie)
+	if err != nil || state.Value == "" || state.Value != c.QueryParam("state") {
+		return apperr.BadRequest("Invalid login state")
+	}
+	nonce, err := c.Cookie(oidcNonceCookie)
+	if err != nil {
+		return apperr.BadRequest("Invalid login state")
+	}
+	verifier, err := c.Cookie(oidcVerifierCookie)
+	if err != nil {
+		return apperr.BadRequest("Invalid login state")
+	}
+
+	code := c.QueryParam("code")
+	if code == "" {
+		return apperr.BadRequest("Missing authorization code")
+	}
+
+	identity, err := s.SSO.Exchange(c.Request().Context(), code, nonce.Value, verifier.Value)
+	if err != nil {
+		s.recordAudit(c, audit.Entry{Action: "auth.oidc_login", Outcome: model.AuditFailure, Detail: err.Error()})
+		return apperr.Unauthorized("Single sign-on failed")
+	}
+
+	user, err := s.findOrProvisionUser(c.Request().Context(), identity)
+	if err != nil {
+		return apperr.Internal(err, "Failed to load user")
+	}
+
+	s.recordAudit(c, audit.Entry{Action: "auth.oidc_login", ActorID: user.ID, ActorUsername: user.Username})
+
+	for _, name := range []string{oidcStateCookie, oidcNonceCookie, oidcVerifierCookie} {
+		setOIDCCookie(c, name, "", -1)
+	}
+
+	// The identity provider only replaces the password; users with 2FA
+	// still finish the login at /login/2fa.
+	if user.TOTPEnabled {
+		challenge, err := s.issueChallengeToken(*user, "sso")
+		if err != nil {
+			return apperr.Internal(err, "Failed to sign token")
+		}
+		if s.OIDCSuccessRedirect != "" {
+			return c.Redirect(http.StatusFound, s.OIDCSuccessRedirect+"#two_factor_required=true&challenge_token="+url.QueryEscape(challenge))
+		}
+		return c.JSON(http.StatusOK, echo.Map{
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+		})
+	}
+
+	t, err := s.issueToken(*user, "sso")
+	if err != nil {
+		return apperr.Internal(err, "Failed to sign token")
+	}
+
+	if s.OIDCSuccessRedirect != "" {
+		return c.Redirect(http.StatusFound, s.OIDCSuccessRedirect+"#token="+url.QueryEscape(t))
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"token": t,
+	})
+}
+
+// findOrProvisionUser resolves the identity to a local user. Known subjects
+// map directly; otherwise a new user is created. Identities are never
+// linked to an existing user by username or email, since anyone who can
+// register that address with the provider would take over the account.
+func (s *Server) findOrProvisionUser(ctx context.Context, identity *sso.Identity) (*model.User, error) {
+	user, err := s.Identities.User(ctx, identity.Issuer, identity.Subject)
+	if err == nil {
+		return &user, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+
+	username, err := s.availableUsername(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+	user = model.User{Username: username}
+	err = s.Identities.Provision(ctx, &user, model.UserIdentity{
+		Issuer:  identity.Issuer,
+		Subject: identity.Subject,
+		Email:   identity.Email,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (s *Server) availableUsername(ctx context.Context, identity *sso.Identity) (string, error) {
+	candidate := identity.PreferredUsername
+	if candidate == "" {
+		candidate = identity.Email
+	}
+
+	sum := sha256.Sum256([]byte(identity.Issuer + "|" + identity.Subject))
+	suffix := hex.EncodeToString(sum[:])[:8]
+	if candidate == "" {
+		return "oidc-" + suffix, nil
+	}
+
+	_, err := s.Users.ByUsername(ctx, candidate)
+	if errors.Is(err, store.ErrNotFound) {
+		return candidate, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%s", candidate, suffix), nil
+}
+
+func setOIDCCookie(c echo.Context, name, value string, maxAge int) {
+	c.SetCookie(&http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     oidcCookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   c.Scheme() == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
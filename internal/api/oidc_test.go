@@ -0,0 +1,275 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"url-crawler-backend/internal/model"
+	"url-crawler-backend/internal/sso"
+	"url-crawler-backend/internal/totp"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// mockOIDCProvider implements just enough of an OpenID provider for the
+// authorization-code flow: discovery, JWKS and a PKCE-checking token endpoint.
+type mockOIDCProvider struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+	subject  string
+	claims   map[string]interface{}
+
+	mu     sync.Mutex
+	grants map[string]mockGrant
+}
+
+type mockGrant struct {
+	nonce     string
+	challenge string
+}
+
+func newMockOIDCProvider(t *testing.T, clientID string) *mockOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	p := &mockOIDCProvider{
+		key:      key,
+		clientID: clientID,
+		subject:  "subject-123",
+		claims:   map[string]interface{}{},
+		grants:   map[string]mockGrant{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                p.server.URL,
+			"authorization_endpoint":                p.server.URL + "/authorize",
+			"token_endpoint":                        p.server.URL + "/token",
+			"jwks_uri":                              p.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "mock",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", p.handleToken)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	return p
+}
+
+// authorize records the grant the browser would obtain from the login page.
+func (p *mockOIDCProvider) authorize(t *testing.T, authURL string) (code, state string) {
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	q := parsed.Query()
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+
+	code = "code-" + q.Get("state")[:8]
+	p.mu.Lock()
+	p.grants[code] = mockGrant{nonce: q.Get("nonce"), challenge: q.Get("code_challenge")}
+	p.mu.Unlock()
+
+	return code, q.Get("state")
+}
+
+func (p *mockOIDCProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	p.mu.Lock()
+	grant, ok := p.grants[r.PostForm.Get("code")]
+	delete(p.grants, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":   p.server.URL,
+		"sub":   p.subject,
+		"aud":   p.clientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": grant.nonce,
+	}
+	for k, v := range p.claims {
+		claims[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "mock"
+	idToken, _ := token.SignedString(p.key)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func setupOIDC(t *testing.T) (*Server, *gorm.DB, *mockOIDCProvider) {
+	testDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	testDB.AutoMigrate(&model.User{}, &model.UserIdentity{})
+
+	s := NewServer(testDB)
+
+	mock := newMockOIDCProvider(t, "crawler")
+	provider, err := sso.NewProvider(context.Background(), sso.Config{
+		IssuerURL:   mock.server.URL,
+		ClientID:    "crawler",
+		RedirectURL: "http://localhost:8080/auth/oidc/callback",
+		Scopes:      []string{"openid", "profile", "email"},
+	})
+	require.NoError(t, err)
+
+	s.SSO = provider
+
+	return s, testDB, mock
+}
+
+// runOIDCLogin drives one full browser round trip and returns the callback
+// response.
+func runOIDCLogin(t *testing.T, s *Server, mock *mockOIDCProvider, tamperState bool) (*httptest.ResponseRecorder, error) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil)
+	rec := httptest.NewRecorder()
+	require.NoError(t, s.OIDCLogin(e.NewContext(req, rec)))
+	require.Equal(t, http.StatusFound, rec.Code)
+
+	code, state := mock.authorize(t, rec.Header().Get(echo.HeaderLocation))
+	if tamperState {
+		state = "forged"
+	}
+
+	callback := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?code="+code+"&state="+url.QueryEscape(state), nil)
+	for _, cookie := range rec.Result().Cookies() {
+		callback.AddCookie(cookie)
+	}
+	callbackRec := httptest.NewRecorder()
+	err := s.OIDCCallback(e.NewContext(callback, callbackRec))
+
+	return callbackRec, err
+}
+
+func TestOIDCLoginProvisionsUser(t *testing.T) {
+	t.Parallel()
+	s, testDB, mock := setupOIDC(t)
+	mock.claims["preferred_username"] = "jane"
+
+	rec, err := runOIDCLogin(t, s, mock, false)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.NotEmpty(t, response["token"])
+
+	var user model.User
+	require.NoError(t, testDB.Where("username = ?", "jane").First(&user).Error)
+
+	// A second login with the same subject maps to the same user.
+	_, err = runOIDCLogin(t, s, mock, false)
+	require.NoError(t, err)
+
+	var users, identities int64
+	testDB.Model(&model.User{}).Count(&users)
+	testDB.Model(&model.UserIdentity{}).Count(&identities)
+	assert.Equal(t, int64(1), users)
+	assert.Equal(t, int64(1), identities)
+}
+
+func TestOIDCLoginDoesNotLinkByEmail(t *testing.T) {
+	t.Parallel()
+	s, testDB, mock := setupOIDC(t)
+	existing := model.User{Username: "jane@example.com", Password: "hash"}
+	testDB.Create(&existing)
+
+	mock.claims["email"] = "jane@example.com"
+	mock.claims["email_verified"] = true
+
+	_, err := runOIDCLogin(t, s, mock, false)
+	require.NoError(t, err)
+
+	var link model.UserIdentity
+	require.NoError(t, testDB.First(&link).Error)
+	assert.NotEqual(t, existing.ID, link.UserID)
+
+	var provisioned model.User
+	require.NoError(t, testDB.First(&provisioned, link.UserID).Error)
+	assert.NotEqual(t, existing.Username, provisioned.Username)
+	assert.True(t, strings.HasPrefix(provisioned.Username, "jane@example.com-"))
+}
+
+func TestOIDCLoginRequiresSecondFactor(t *testing.T) {
+	t.Parallel()
+	s, testDB, mock := setupOIDC(t)
+	mock.claims["preferred_username"] = "jane"
+
+	_, err := runOIDCLogin(t, s, mock, false)
+	require.NoError(t, err)
+
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	require.NoError(t, testDB.Model(&model.User{}).Where("username = ?", "jane").
+		Updates(map[string]interface{}{"totp_secret": secret, "totp_enabled": true}).Error)
+
+	rec, err := runOIDCLogin(t, s, mock, false)
+	require.NoError(t, err)
+	login := decodeBody(t, rec)
+	assert.Equal(t, true, login["two_factor_required"])
+	assert.NotContains(t, login, "token")
+
+	code, _ := totp.CodeAt(secret, totp.Step(time.Now()))
+	c, rec := postJSON(echo.New(), "/login/2fa", map[string]string{"challenge_token": login["challenge_token"].(string), "code": code}, 0)
+	require.NoError(t, s.LoginTwoFactor(c))
+
+	token, _, err := jwt.NewParser().ParseUnverified(decodeBody(t, rec)["token"].(string), jwt.MapClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"sso", "otp"}, token.Claims.(jwt.MapClaims)["amr"])
+}
+
+func TestOIDCCallbackRejectsStateMismatch(t *testing.T) {
+	t.Parallel()
+	s, _, mock := setupOIDC(t)
+
+	_, err := runOIDCLogin(t, s, mock, true)
+	require.Error(t, err)
+	he, ok := err.(*echo.HTTPError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusBadRequest, he.Code)
+}
@@ -0,0 +1,420 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"url-crawler-backend/internal/apperr"
+	"url-crawler-backend/internal/model"
+	"url-crawler-backend/internal/openapi"
+	"url-crawler-backend/internal/stats"
+	"url-crawler-backend/internal/version"
+
+	"github.com/labstack/echo/v4"
+)
+
+// endpointDoc describes a route for the OpenAPI document. Request and
+// Response are example values whose types define the schemas; a nil
+// Response documents an empty body.
+type endpointDoc struct {
+	Summary  string
+	Tag      string
+	Request  interface{}
+	Response interface{}
+	Status   int
+	Query    []openapi.Parameter
+	// Form marks a multipart upload; Request then describes its fields.
+	Form bool
+	// Raw is the content type of a non-JSON response.
+	Raw string
+	// Public routes need no bearer token.
+	Public bool
+	// Workspace routes take the X-Workspace-ID header.
+	Workspace bool
+}
+
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+type tokenResponse struct {
+	Token string `json:"token"`
+}
+
+func queryParam(name, typ, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: typ}}
+}
+
+var urlFilters = []openapi.Parameter{
+	queryParam("status", "string", "Only URLs with this status"),
+	queryParam("tag", "string", "Comma-separated tag names; URLs with any of them"),
+	queryParam("q", "string", "Substring of the URL or page title"),
+	queryParam("has_broken_links", "boolean", ""),
+	queryParam("has_login_form", "boolean", ""),
+}
+
+// endpointDocs is keyed by "METHOD path" as registered with Echo. Every
+// route in RegisterRoutes needs an entry; TestOpenAPICoversRoutes checks.
+var endpointDocs = map[string]endpointDoc{
+	"POST /login": {
+		Summary: "Log in with a username and password", Tag: "auth", Public: true,
+		Request: LoginRequest{},
+		Response: struct {
+			Token             string `json:"token,omitempty"`
+			TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+			ChallengeToken    string `json:"challenge_token,omitempty"`
+		}{},
+	},
+	"POST /login/2fa": {
+		Summary: "Complete a login with a TOTP or recovery code", Tag: "auth", Public: true,
+		Request: TwoFactorLoginRequest{}, Response: tokenResponse{},
+	},
+	"GET /.well-known/jwks.json": {
+		Summary: "Public keys for verifying tokens", Tag: "auth", Public: true,
+		Response: map[string]interface{}{},
+	},
+	"GET /auth/oidc/login": {
+		Summary: "Start single sign-on", Tag: "auth", Public: true, Status: http.StatusFound,
+	},
+	"GET /auth/oidc/callback": {
+		Summary: "Finish single sign-on", Tag: "auth", Public: true, Response: tokenResponse{},
+		Query: []openapi.Parameter{queryParam("code", "string", ""), queryParam("state", "string", "")},
+	},
+	"GET /openapi.json": {
+		Summary: "This document", Tag: "meta", Public: true, Response: map[string]interface{}{},
+	},
+	"GET /docs": {
+		Summary: "Interactive API documentation", Tag: "meta", Public: true, Raw: "text/html",
+	},
+	"GET /healthz": {
+		Summary: "Liveness check", Tag: "meta", Public: true, Response: healthResponse{},
+	},
+	"GET /readyz": {
+		Summary: "Readiness check; 503 while the database or crawler is unavailable", Tag: "meta", Public: true,
+		Response: readinessResponse{},
+	},
+	"GET /version": {
+		Summary: "Build information", Tag: "meta", Public: true, Response: version.Info{},
+	},
+	"GET /metrics": {
+		Summary: "Prometheus metrics", Tag: "meta", Public: true, Raw: "text/plain",
+	},
+
+	"POST /api/urls": {
+		Summary: "Add a URL", Tag: "urls", Workspace: true, Status: http.StatusCreated,
+		Request: AddURLRequest{}, Response: model.URL{},
+	},
+	"GET /api/urls": {
+		Summary: "List URLs", Tag: "urls", Workspace: true,
+		Response: []model.URL{}, Query: urlFilters,
+	},
+	"POST /api/urls/import": {
+		Summary: "Import URLs from a CSV or text file", Tag: "urls", Workspace: true, Form: true,
+		Request: struct {
+			File  string `json:"file" validate:"required"`
+			Crawl bool   `json:"crawl"`
+		}{},
+		Response: struct {
+			Created    int         `json:"created"`
+			Duplicates int         `json:"duplicates"`
+			Invalid    int         `json:"invalid"`
+			Crawling   bool        `json:"crawling"`
+			Rows       []importRow `json:"rows"`
+		}{},
+	},
+	"GET /api/urls/export": {
+		Summary: "Export URLs as CSV, NDJSON or XLSX", Tag: "urls", Workspace: true, Raw: "application/octet-stream",
+		Query: append([]openapi.Parameter{
+			queryParam("format", "string", "csv, ndjson or xlsx"),
+			queryParam("links", "boolean", "Include each URL's links"),
+		}, urlFilters...),
+	},
+	"POST /api/urls/crawl": {
+		Summary: "Crawl URLs by id or tag", Tag: "urls", Workspace: true,
+		Request: BulkCrawlRequest{},
+		Response: struct {
+			Message  string `json:"message"`
+			Started  []uint `json:"started"`
+			NotFound []uint `json:"not_found"`
+		}{},
+	},
+	"POST /api/urls/:id/start": {
+		Summary: "Crawl a URL", Tag: "urls", Workspace: true, Response: messageResponse{},
+	},
+	"PATCH /api/urls/:id": {
+		Summary: "Update a URL's notes and tags", Tag: "urls", Workspace: true,
+		Request: UpdateURLRequest{}, Response: model.URL{},
+	},
+	"POST /api/urls/tags": {
+		Summary: "Tag URLs", Tag: "tags", Workspace: true, Request: URLTagsRequest{},
+		Response: struct {
+			Tagged   []uint `json:"tagged"`
+			NotFound []uint `json:"not_found"`
+		}{},
+	},
+	"DELETE /api/urls/tags": {
+		Summary: "Untag URLs", Tag: "tags", Workspace: true, Request: URLTagsRequest{},
+		Response: struct {
+			Untagged []uint `json:"untagged"`
+			NotFound []uint `json:"not_found"`
+		}{},
+	},
+	"DELETE /api/urls": {
+		Summary: "Move URLs to the trash", Tag: "urls", Workspace: true, Status: http.StatusNoContent,
+		Request: DeleteURLsRequest{},
+	},
+	"GET /api/urls/trash": {
+		Summary: "List URLs in the trash", Tag: "urls", Workspace: true, Response: []model.URL{},
+	},
+	"POST /api/urls/restore": {
+		Summary: "Restore URLs from the trash", Tag: "urls", Workspace: true, Request: DeleteURLsRequest{},
+		Response: struct {
+			Restored []uint `json:"restored"`
+			NotFound []uint `json:"not_found"`
+		}{},
+	},
+
+	"GET /api/tags": {
+		Summary: "List tags", Tag: "tags", Workspace: true, Response: []model.Tag{},
+	},
+	"POST /api/tags": {
+		Summary: "Create a tag", Tag: "tags", Workspace: true, Status: http.StatusCreated,
+		Request: TagRequest{}, Response: model.Tag{},
+	},
+	"PATCH /api/tags/:id": {
+		Summary: "Rename a tag", Tag: "tags", Workspace: true, Request: TagRequest{}, Response: model.Tag{},
+	},
+	"DELETE /api/tags/:id": {
+		Summary: "Delete a tag", Tag: "tags", Workspace: true, Status: http.StatusNoContent,
+	},
+
+	"GET /api/workspaces": {
+		Summary: "List your workspaces", Tag: "workspaces", Response: []model.Membership{},
+	},
+	"POST /api/workspaces": {
+		Summary: "Create a workspace", Tag: "workspaces", Status: http.StatusCreated,
+		Request: CreateWorkspaceRequest{}, Response: model.Membership{},
+	},
+	"GET /api/workspaces/:id/members": {
+		Summary: "List members", Tag: "workspaces", Response: []model.Membership{},
+	},
+	"POST /api/workspaces/:id/members": {
+		Summary: "Add a member", Tag: "workspaces", Status: http.StatusCreated,
+		Request: AddMemberRequest{}, Response: model.Membership{},
+	},
+	"PATCH /api/workspaces/:id/members/:user_id": {
+		Summary: "Change a member's role", Tag: "workspaces",
+		Request: UpdateMemberRequest{}, Response: model.Membership{},
+	},
+	"DELETE /api/workspaces/:id/members/:user_id": {
+		Summary: "Remove a member", Tag: "workspaces", Status: http.StatusNoContent,
+	},
+
+	"GET /api/stats": {
+		Summary: "Dashboard statistics", Tag: "stats", Workspace: true, Response: stats.Stats{},
+		Query: []openapi.Parameter{
+			queryParam("days", "integer", "Days covered by crawls_per_day (default 30, max 365)"),
+			queryParam("top", "integer", "Number of top domains (default 10, max 100)"),
+		},
+	},
+
+	"GET /api/audit": {
+		Summary: "Search the audit log", Tag: "audit",
+		Response: struct {
+			Entries []model.AuditLog `json:"entries"`
+			Total   int64            `json:"total"`
+		}{},
+		Query: []openapi.Parameter{
+			queryParam("actor", "string", ""), queryParam("actor_id", "integer", ""),
+			queryParam("action", "string", ""), queryParam("outcome", "string", ""),
+			queryParam("workspace_id", "integer", ""), queryParam("target_type", "string", ""),
+			queryParam("target_id", "integer", ""),
+			queryParam("from", "string", "RFC 3339 time"), queryParam("to", "string", "RFC 3339 time"),
+			queryParam("limit", "integer", ""), queryParam("offset", "integer", ""),
+		},
+	},
+
+	"POST /api/2fa/enroll": {
+		Summary: "Start two-factor enrollment", Tag: "2fa",
+		Response: struct {
+			Secret          string `json:"secret"`
+			ProvisioningURI string `json:"provisioning_uri"`
+		}{},
+	},
+	"POST /api/2fa/confirm": {
+		Summary: "Confirm two-factor enrollment", Tag: "2fa", Request: TwoFactorConfirmRequest{},
+		Response: struct {
+			RecoveryCodes []string `json:"recovery_codes"`
+		}{},
+	},
+	"POST /api/2fa/recovery-codes": {
+		Summary: "Replace recovery codes", Tag: "2fa", Request: TwoFactorCodeRequest{},
+		Response: struct {
+			RecoveryCodes []string `json:"recovery_codes"`
+		}{},
+	},
+	"POST /api/2fa/disable": {
+		Summary: "Turn off two-factor authentication", Tag: "2fa", Request: TwoFactorCodeRequest{},
+		Status: http.StatusNoContent,
+	},
+}
+
+// BuildOpenAPI documents the given routes. Routes without an entry in
+// endpointDocs are listed with just their path and method.
+func BuildOpenAPI(routes []*echo.Route) *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:       "URL Crawler API",
+		Description: "Crawl URLs and report on their HTML structure and links.",
+		Version:     "1.0.0",
+	})
+	doc.Components.SecuritySchemes = map[string]openapi.SecurityScheme{
+		"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+	}
+	doc.Security = []openapi.SecurityRequirement{{"bearerAuth": {}}}
+	errorSchema := doc.SchemaOf(apperr.Error{})
+	doc.SchemaOf(FieldError{})
+
+	tags := map[string]bool{}
+	for _, route := range routes {
+		if !documentedMethod(route.Method) {
+			continue
+		}
+		info := endpointDocs[route.Method+" "+route.Path]
+		path, params := openAPIPath(route.Path)
+		op := &openapi.Operation{
+			Summary:     info.Summary,
+			OperationID: operationID(route.Name),
+			Parameters:  params,
+			Responses:   map[string]openapi.Response{},
+		}
+		if info.Tag != "" {
+			op.Tags = []string{info.Tag}
+			tags[info.Tag] = true
+		}
+		if info.Public {
+			op.Security = []openapi.SecurityRequirement{}
+		}
+		if info.Workspace {
+			op.Parameters = append(op.Parameters, openapi.Parameter{
+				Name: HeaderWorkspaceID, In: "header",
+				Description: "Workspace to act in; defaults to your personal workspace",
+				Schema:      &openapi.Schema{Type: "integer"},
+			})
+		}
+		if route.Method == http.MethodPost && !info.Public {
+			op.Parameters = append(op.Parameters, openapi.Parameter{
+				Name: HeaderIdempotencyKey, In: "header",
+				Description: "Makes the request safe to retry; replays return the original response",
+				Schema:      &openapi.Schema{Type: "string"},
+			})
+		}
+		op.Parameters = append(op.Parameters, info.Query...)
+
+		if info.Request != nil {
+			contentType := echo.MIMEApplicationJSON
+			schema := doc.SchemaOf(info.Request)
+			if info.Form {
+				contentType = echo.MIMEMultipartForm
+				schema.Properties["file"].Format = "binary"
+			}
+			op.RequestBody = &openapi.RequestBody{
+				Required: true,
+				Content:  map[string]openapi.MediaType{contentType: {Schema: schema}},
+			}
+		}
+
+		status := info.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		response := openapi.Response{Description: http.StatusText(status)}
+		switch {
+		case info.Response != nil:
+			response.Content = map[string]openapi.MediaType{echo.MIMEApplicationJSON: {Schema: doc.SchemaOf(info.Response)}}
+		case info.Raw != "":
+			response.Content = map[string]openapi.MediaType{info.Raw: {Schema: &openapi.Schema{Type: "string"}}}
+		}
+		op.Responses[strconv.Itoa(status)] = response
+		op.Responses["default"] = openapi.Response{
+			Description: "Error",
+			Content: map[string]openapi.MediaType{
+				echo.MIMEApplicationJSON: {Schema: errorSchema},
+			},
+		}
+
+		doc.AddOperation(route.Method, path, op)
+	}
+
+	for name := range tags {
+		doc.Tags = append(doc.Tags, openapi.Tag{Name: name})
+	}
+	sort.Slice(doc.Tags, func(i, j int) bool { return doc.Tags[i].Name < doc.Tags[j].Name })
+	return doc
+}
+
+// documentedMethod filters out Echo's internal routes, such as the
+// RouteNotFound handlers of groups.
+func documentedMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// openAPIPath converts Echo's ":param" segments to "{param}" and returns
+// the path parameters.
+func openAPIPath(path string) (string, []openapi.Parameter) {
+	var params []openapi.Parameter
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			segments[i] = "{" + name + "}"
+			params = append(params, openapi.Parameter{
+				Name: name, In: "path", Required: true,
+				Schema: &openapi.Schema{Type: "integer"},
+			})
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID derives an id from the handler name Echo records, such as
+// "url-crawler-backend/internal/api.AddURL".
+func operationID(handler string) string {
+	name := handler[strings.LastIndex(handler, ".")+1:]
+	return strings.TrimSuffix(name, "-fm")
+}
+
+// OpenAPIDocument serves the OpenAPI document for every registered route.
+// It is built on first request, after all routes have been added.
+func (s *Server) OpenAPIDocument(c echo.Context) error {
+	s.specOnce.Do(func() {
+		s.spec = BuildOpenAPI(c.Echo().Routes())
+	})
+	return c.JSON(http.StatusOK, s.spec)
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>URL Crawler API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5.17.14/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5.17.14/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
+
+// SwaggerUI serves an interactive viewer for the OpenAPI document.
+func SwaggerUI(c echo.Context) error {
+	return c.HTML(http.StatusOK, swaggerUIPage)
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"url-crawler-backend/internal/model"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	t.Parallel()
+	e := echo.New()
+	NewServer(nil).RegisterRoutes(e)
+
+	for _, route := range e.Routes() {
+		if !documentedMethod(route.Method) {
+			continue
+		}
+		_, ok := endpointDocs[route.Method+" "+route.Path]
+		assert.True(t, ok, "%s %s has no entry in endpointDocs", route.Method, route.Path)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var doc struct {
+		OpenAPI    string                                `json:"openapi"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+	assert.Contains(t, doc.Paths["/api/urls/{id}"], "patch")
+	assert.Contains(t, doc.Paths["/api/urls"], "post")
+	assert.JSONEq(t, `{"type": "object", "properties": {
+		"url": {"type": "string", "format": "uri", "maxLength": 2048},
+		"notes": {"type": "string", "maxLength": 10000},
+		"tags": {"type": "array", "items": {"type": "string", "maxLength": 64}}
+	}, "required": ["url"]}`, string(doc.Components.Schemas["AddURLRequest"]))
+	assert.Contains(t, doc.Components.Schemas, "URL")
+
+	req = httptest.NewRequest(http.MethodGet, "/docs", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "/openapi.json")
+}
+
+func TestValidationErrors(t *testing.T) {
+	t.Parallel()
+	testDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	testDB.AutoMigrate(&model.URL{}, &model.Tag{}, &model.User{}, &model.Workspace{}, &model.Membership{}, &model.AuditLog{})
+
+	s := NewServer(testDB)
+
+	user := model.User{Username: "alice"}
+	testDB.Create(&user)
+
+	rec := callAs(user.ID, "", http.MethodPost, "/api/urls", map[string]interface{}{"tags": []string{strings.Repeat("x", 65)}}, s.WorkspaceMiddleware(s.AddURL))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	var body struct {
+		Code    string       `json:"code"`
+		Message string       `json:"message"`
+		Details []FieldError `json:"details"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "validation_failed", body.Code)
+	assert.Equal(t, "Validation failed", body.Message)
+	assert.Equal(t, []FieldError{
+		{Field: "url", Rule: "required", Message: "url is required"},
+		{Field: "tags[0]", Rule: "max", Message: "tags[0] must have at most 64 characters"},
+	}, body.Details)
+
+	rec = callAs(user.ID, "", http.MethodPost, "/api/urls/crawl", map[string]interface{}{"ids": []uint{}}, s.WorkspaceMiddleware(s.StartBulkCrawl))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"field":"ids","rule":"min"`)
+
+	rec = callAs(user.ID, "", http.MethodPost, "/api/workspaces", map[string]string{}, s.CreateWorkspace)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"field":"name","rule":"required"`)
+}
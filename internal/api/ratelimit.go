@@ -0,0 +1,73 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"url-crawler-backend/internal/apperr"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRateLimitPolicy    = "RateLimit-Policy"
+)
+
+// RateLimit enforces RateLimits and reports the state of the caller's
+// bucket in RateLimit-* headers.
+func (s *Server) RateLimit(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		limit, bucket := s.RateLimits.For(c.Request().Method + " " + c.Path())
+		if limit.Unlimited() {
+			return next(c)
+		}
+
+		res := s.rateLimiter.Allow(bucket+"|"+rateLimitKey(c), limit)
+		h := c.Response().Header()
+		h.Set(HeaderRateLimitLimit, strconv.Itoa(res.Limit))
+		h.Set(HeaderRateLimitRemaining, strconv.Itoa(res.Remaining))
+		h.Set(HeaderRateLimitReset, strconv.Itoa(seconds(res.Reset)))
+		h.Set(HeaderRateLimitPolicy, fmt.Sprintf("%d;w=%d", limit.Requests, seconds(limit.Period)))
+
+		if !res.Allowed {
+			retry := seconds(res.RetryAfter)
+			h.Set(echo.HeaderRetryAfter, strconv.Itoa(retry))
+			return apperr.New(http.StatusTooManyRequests, "rate_limited", fmt.Sprintf("Too many requests, retry in %d seconds", retry))
+		}
+		return next(c)
+	}
+}
+
+// IPExtractor returns how the client IP is found for rate limits, audit
+// entries and logs. Without trusted proxies it is the peer address, so
+// clients cannot pick their own bucket with a forged header; otherwise
+// X-Forwarded-For is followed back through the trusted proxies only.
+func IPExtractor(trustedProxies []*net.IPNet) echo.IPExtractor {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+	opts := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, network := range trustedProxies {
+		opts = append(opts, echo.TrustIPRange(network))
+	}
+	return echo.ExtractIPFromXFFHeader(opts...)
+}
+
+func rateLimitKey(c echo.Context) string {
+	if userID, err := currentUserID(c); err == nil {
+		return "user:" + strconv.FormatUint(uint64(userID), 10)
+	}
+	return "ip:" + c.RealIP()
+}
+
+// seconds rounds d up to whole seconds.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
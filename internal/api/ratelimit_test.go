@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-crawler-backend/internal/model"
+	"url-crawler-backend/internal/ratelimit"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+	s := NewServer(nil)
+	s.RateLimits = ratelimit.Config{
+		Default: ratelimit.Limit{Requests: 100, Period: time.Minute},
+		Routes:  map[string]ratelimit.Limit{"POST /limited": {Requests: 2, Period: time.Minute}},
+	}
+
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
+	setUser := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if id := c.Request().Header.Get("X-Test-User"); id != "" {
+				c.Set("user", &jwt.Token{Claims: jwt.MapClaims{"id": float64(id[0] - '0')}})
+			}
+			return next(c)
+		}
+	}
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusNoContent) }
+	e.POST("/limited", ok, setUser, s.RateLimit)
+	e.GET("/other", ok, setUser, s.RateLimit)
+
+	call := func(method, path, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-Test-User", user)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := call(http.MethodPost, "/limited", "1")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get(HeaderRateLimitLimit))
+	assert.Equal(t, "1", rec.Header().Get(HeaderRateLimitRemaining))
+	assert.Equal(t, "2;w=60", rec.Header().Get(HeaderRateLimitPolicy))
+
+	call(http.MethodPost, "/limited", "1")
+	rec = call(http.MethodPost, "/limited", "1")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "30", rec.Header().Get(echo.HeaderRetryAfter))
+	assert.Contains(t, rec.Body.String(), `"code":"rate_limited"`)
+
+	// Other users and other routes have their own buckets.
+	assert.Equal(t, http.StatusNoContent, call(http.MethodPost, "/limited", "2").Code)
+	rec = call(http.MethodGet, "/other", "1")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "100", rec.Header().Get(HeaderRateLimitLimit))
+}
+
+func TestRateLimitClientIP(t *testing.T) {
+	t.Parallel()
+	_, proxies, err := net.ParseCIDR("192.0.2.0/24")
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		trusted []*net.IPNet
+		// limited is whether the second request, from a different
+		// forwarded address, shares the first one's bucket.
+		limited bool
+	}{
+		"direct":        {trusted: nil, limited: true},
+		"trusted proxy": {trusted: []*net.IPNet{proxies}, limited: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := NewServer(nil)
+			s.RateLimits = ratelimit.Config{Routes: map[string]ratelimit.Limit{"POST /login": {Requests: 1, Period: time.Minute}}}
+
+			e := echo.New()
+			e.HTTPErrorHandler = ErrorHandler
+			e.IPExtractor = IPExtractor(tc.trusted)
+			e.POST("/login", func(c echo.Context) error { return c.NoContent(http.StatusNoContent) }, s.RateLimit)
+
+			call := func(forwardedFor string) int {
+				req := httptest.NewRequest(http.MethodPost, "/login", nil)
+				req.RemoteAddr = "192.0.2.10:4000"
+				req.Header.Set(echo.HeaderXForwardedFor, forwardedFor)
+				req.Header.Set(echo.HeaderXRealIP, forwardedFor)
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, req)
+				return rec.Code
+			}
+
+			assert.Equal(t, http.StatusNoContent, call("203.0.113.1"))
+			if tc.limited {
+				assert.Equal(t, http.StatusTooManyRequests, call("203.0.113.2"))
+			} else {
+				assert.Equal(t, http.StatusNoContent, call("203.0.113.2"))
+				assert.Equal(t, http.StatusTooManyRequests, call("203.0.113.1"))
+			}
+		})
+	}
+}
+
+func TestCrawlQuota(t *testing.T) {
+	t.Parallel()
+	testDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, _ := testDB.DB()
+	sqlDB.SetMaxOpenConns(1)
+	testDB.AutoMigrate(&model.URL{}, &model.Link{})
+
+	s := NewServer(testDB)
+
+	s.CrawlQuota = ratelimit.NewConcurrency(1)
+
+	// Hold the user's only slot so the crawl has to wait.
+	release, ok := s.CrawlQuota.TryAcquire("7")
+	require.True(t, ok)
+
+	u := model.URL{WorkspaceID: 1, URL: "ftp://example.com", Status: "done"}
+	testDB.Create(&u)
+	s.startCrawl(context.Background(), u, 7)
+
+	testDB.First(&u, u.ID)
+	assert.Equal(t, "queued", u.Status)
+
+	release()
+	require.Eventually(t, func() bool {
+		testDB.First(&u, u.ID)
+		return u.Status == "error"
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "invalid_url", u.ErrorClass)
+}
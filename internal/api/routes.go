@@ -6,14 +6,64 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
-func RegisterRoutes(e *echo.Echo) {
-	e.POST("/login", Login)
+func (s *Server) RegisterRoutes(e *echo.Echo) {
+	if e.Validator == nil {
+		e.Validator = NewValidator()
+	}
+	e.HTTPErrorHandler = ErrorHandler
+
+	e.POST("/login", s.Login, s.RateLimit)
+	e.POST("/login/2fa", s.LoginTwoFactor, s.RateLimit)
+	e.GET("/.well-known/jwks.json", s.JWKS)
+	e.GET("/openapi.json", s.OpenAPIDocument)
+	e.GET("/docs", SwaggerUI)
+
+	e.GET("/healthz", Healthz)
+	e.GET("/readyz", s.Readyz)
+	e.GET("/version", GetVersion)
+	e.GET("/metrics", s.MetricsHandler)
+
+	if s.SSO != nil {
+		e.GET("/auth/oidc/login", s.OIDCLogin)
+		e.GET("/auth/oidc/callback", s.OIDCCallback)
+	}
 
 	api := e.Group("/api")
-	api.Use(middleware.JWTMiddleware())
+	api.Use(middleware.JWTMiddleware(s.Keys), s.RateLimit, s.Idempotency)
+
+	urls := api.Group("/urls", s.WorkspaceMiddleware)
+	urls.POST("", s.AddURL)
+	urls.GET("", s.GetURLs)
+	urls.POST("/import", s.ImportURLs)
+	urls.GET("/export", s.ExportURLs)
+	urls.POST("/crawl", s.StartBulkCrawl)
+	urls.POST("/:id/start", s.StartCrawl)
+	urls.PATCH("/:id", s.UpdateURL)
+	urls.POST("/tags", s.TagURLs)
+	urls.DELETE("/tags", s.UntagURLs)
+	urls.DELETE("", s.DeleteURLs, s.RequireTwoFactor)
+	urls.GET("/trash", s.GetTrash)
+	urls.POST("/restore", s.RestoreURLs, s.RequireTwoFactor)
+
+	tags := api.Group("/tags", s.WorkspaceMiddleware)
+	tags.GET("", s.GetTags)
+	tags.POST("", s.CreateTag)
+	tags.PATCH("/:id", s.RenameTag)
+	tags.DELETE("/:id", s.DeleteTag)
+
+	api.GET("/workspaces", s.GetWorkspaces)
+	api.POST("/workspaces", s.CreateWorkspace)
+	api.GET("/workspaces/:id/members", s.GetWorkspaceMembers)
+	api.POST("/workspaces/:id/members", s.AddWorkspaceMember, s.RequireTwoFactor)
+	api.PATCH("/workspaces/:id/members/:user_id", s.UpdateWorkspaceMember, s.RequireTwoFactor)
+	api.DELETE("/workspaces/:id/members/:user_id", s.RemoveWorkspaceMember, s.RequireTwoFactor)
+
+	api.GET("/stats", s.GetStats, s.WorkspaceMiddleware)
+
+	api.GET("/audit", s.GetAuditLog, s.RequireAdmin, s.RequireTwoFactor)
 
-	api.POST("/urls", AddURL)
-	api.GET("/urls", GetURLs)
-	api.POST("/urls/crawl", StartBulkCrawl)
-	api.DELETE("/urls", DeleteURLs)
+	api.POST("/2fa/enroll", s.EnrollTwoFactor)
+	api.POST("/2fa/confirm", s.ConfirmTwoFactor)
+	api.POST("/2fa/recovery-codes", s.RegenerateRecoveryCodes)
+	api.POST("/2fa/disable", s.DisableTwoFactor)
 }
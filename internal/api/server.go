@@ -0,0 +1,92 @@
+package api
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"url-crawler-backend/internal/config"
+	"url-crawler-backend/internal/idempotency"
+	"url-crawler-backend/internal/jwtkeys"
+	"url-crawler-backend/internal/metrics"
+	"url-crawler-backend/internal/openapi"
+	"url-crawler-backend/internal/ratelimit"
+	"url-crawler-backend/internal/sso"
+	"url-crawler-backend/internal/store"
+
+	"gorm.io/gorm"
+)
+
+// Server holds the dependencies of the HTTP handlers, which are its
+// methods. Handlers read and write data only through the stores.
+type Server struct {
+	URLs            store.URLStore
+	Users           store.UserStore
+	Workspaces      store.WorkspaceStore
+	Tags            store.TagStore
+	TwoFactor       store.TwoFactorStore
+	Identities      store.IdentityStore
+	Audit           store.AuditStore
+	Stats           store.StatsStore
+	IdempotencyKeys store.IdempotencyStore
+	Health          store.Health
+	// Keys sign and verify access and 2FA challenge tokens. NewServer sets
+	// an HS256 key with an empty secret; cmd/main replaces it with the
+	// configured keys.
+	Keys *jwtkeys.KeySet
+
+	// SSO is the configured OIDC provider. When nil the /auth/oidc routes
+	// are not registered.
+	SSO *sso.Provider
+	// OIDCSuccessRedirect, when set, receives the token in the URL fragment
+	// after an OIDC login instead of a JSON response.
+	OIDCSuccessRedirect string
+	// TOTPIssuer names the service in authenticator apps.
+	TOTPIssuer string
+	// IdempotencyTTL is how long responses to requests with an
+	// Idempotency-Key are kept for replay.
+	IdempotencyTTL time.Duration
+	// RateLimits are the request limits per user, or per client IP for
+	// requests without a token. Routes listed separately have their own
+	// bucket; all other routes share the default one.
+	RateLimits ratelimit.Config
+	// CrawlQuota caps the crawls each user has running at once. Crawls over
+	// the cap stay queued until one of the user's running crawls finishes.
+	CrawlQuota *ratelimit.Concurrency
+	// Metrics records the requests and crawls the server handles and is
+	// served on /metrics. NewServer sets an empty Registry; cmd/main
+	// replaces it with the one the database connection records into.
+	Metrics *metrics.Registry
+
+	rateLimiter *ratelimit.Limiter
+	crawls      *crawlPool
+	migrated    atomic.Bool
+	specOnce    sync.Once
+	spec        *openapi.Document
+}
+
+// NewServer returns a Server whose stores are backed by conn, with the
+// default settings.
+func NewServer(conn *gorm.DB) *Server {
+	defaults := config.Default()
+	return &Server{
+		URLs:            store.NewGormURLStore(conn),
+		Users:           store.NewGormUserStore(conn),
+		Workspaces:      store.NewGormWorkspaceStore(conn),
+		Tags:            store.NewGormTagStore(conn),
+		TwoFactor:       store.NewGormTwoFactorStore(conn),
+		Identities:      store.NewGormIdentityStore(conn),
+		Audit:           store.NewGormAuditStore(conn),
+		Stats:           store.NewGormStatsStore(conn),
+		IdempotencyKeys: store.NewGormIdempotencyStore(conn),
+		Health:          store.NewGormHealth(conn),
+		Keys:            jwtkeys.NewHMAC(nil),
+		TOTPIssuer:      defaults.TOTPIssuer,
+		IdempotencyTTL:  idempotency.DefaultTTL,
+		RateLimits:      defaults.RateLimits,
+		CrawlQuota:      ratelimit.NewConcurrency(defaults.CrawlConcurrency),
+		Metrics:         metrics.New(),
+		rateLimiter:     ratelimit.NewLimiter(),
+		crawls:          newCrawlPool(),
+	}
+}
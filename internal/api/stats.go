@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+
+	"url-crawler-backend/internal/apperr"
+	"url-crawler-backend/internal/model"
+	"url-crawler-backend/internal/stats"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultStatsDays = 30
+	maxStatsDays     = 365
+	defaultTopHosts  = 10
+	maxTopHosts      = 100
+)
+
+// GetStats returns aggregate statistics for the workspace's URLs. The
+// days and top query parameters size the crawls-per-day series and the
+// top domains list.
+func (s *Server) GetStats(c echo.Context) error {
+	membership, err := requireRole(c, model.RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	days, err := uintQueryParam(c, "days")
+	if err != nil || days > maxStatsDays {
+		return apperr.BadRequest("'days' must be between 1 and 365")
+	}
+	if days == 0 {
+		days = defaultStatsDays
+	}
+
+	top, err := uintQueryParam(c, "top")
+	if err != nil || top > maxTopHosts {
+		return apperr.BadRequest("'top' must be between 1 and 100")
+	}
+	if top == 0 {
+		top = defaultTopHosts
+	}
+
+	result, err := s.Stats.Compute(c.Request().Context(), membership.WorkspaceID, stats.Options{Days: int(days), TopDomains: int(top)})
+	if err != nil {
+		return apperr.Internal(err, "Failed to compute statistics")
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
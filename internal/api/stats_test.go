@@ -0,0 +1,43 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"url-crawler-backend/internal/model"
+	"url-crawler-backend/internal/stats"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestGetStats(t *testing.T) {
+	t.Parallel()
+	testDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	testDB.AutoMigrate(&model.URL{}, &model.User{}, &model.Workspace{}, &model.Membership{}, &model.AuditLog{})
+
+	s := NewServer(testDB)
+
+	user := model.User{Username: "alice"}
+	testDB.Create(&user)
+
+	rec := callAs(user.ID, "", http.MethodPost, "/api/urls", map[string]string{"url": "https://example.com/a"}, s.WorkspaceMiddleware(s.AddURL))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	testDB.Create(&model.URL{WorkspaceID: 999, URL: "https://elsewhere.example", Status: "done"})
+
+	rec = callAs(user.ID, "", http.MethodGet, "/api/stats?days=7&top=3", nil, s.WorkspaceMiddleware(s.GetStats))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response stats.Stats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, int64(1), response.Total)
+	assert.Equal(t, int64(1), response.ByStatus["queued"])
+	assert.Len(t, response.CrawlsPerDay, 7)
+	assert.Equal(t, []stats.DomainCount{{Domain: "example.com", Count: 1}}, response.TopDomains)
+
+	rec = callAs(user.ID, "", http.MethodGet, "/api/stats?days=1000", nil, s.WorkspaceMiddleware(s.GetStats))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
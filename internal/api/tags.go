@@ -0,0 +1,293 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"url-crawler-backend/internal/apperr"
+	"url-crawler-backend/internal/audit"
+	"url-crawler-backend/internal/model"
+	"url-crawler-backend/internal/store"
+
+	"github.com/labstack/echo/v4"
+)
+
+const maxTagLength = 64
+
+type TagRequest struct {
+	Name string `json:"name" validate:"required,max=64"`
+}
+
+type URLTagsRequest struct {
+	IDs  []uint   `json:"ids" validate:"required,min=1"`
+	Tags []string `json:"tags" validate:"required,min=1,dive,max=64"`
+}
+
+type UpdateURLRequest struct {
+	Notes *string   `json:"notes" validate:"required_without=Tags,omitempty,max=10000"`
+	Tags  *[]string `json:"tags" validate:"required_without=Notes,omitempty,dive,max=64"`
+}
+
+func (s *Server) GetTags(c echo.Context) error {
+	membership, err := requireRole(c, model.RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	tags, err := s.Tags.List(c.Request().Context(), membership.WorkspaceID)
+	if err != nil {
+		return apperr.Internal(err, "Failed to fetch tags")
+	}
+
+	return c.JSON(http.StatusOK, tags)
+}
+
+func (s *Server) CreateTag(c echo.Context) error {
+	membership, err := requireRole(c, model.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	var req TagRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+	name, err := normalizeTagName(req.Name)
+	if err != nil {
+		return apperr.BadRequest(err.Error())
+	}
+
+	tag := model.Tag{WorkspaceID: membership.WorkspaceID, Name: name}
+	if err := s.Tags.Create(c.Request().Context(), &tag); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			return apperr.Conflict("Tag already exists")
+		}
+		return apperr.Internal(err, "Failed to save tag")
+	}
+
+	s.recordAudit(c, audit.Entry{Action: "tag.create", TargetType: "tag", TargetIDs: []uint{tag.ID}, Detail: name})
+
+	return c.JSON(http.StatusCreated, tag)
+}
+
+func (s *Server) RenameTag(c echo.Context) error {
+	membership, err := requireRole(c, model.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	var req TagRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+	name, err := normalizeTagName(req.Name)
+	if err != nil {
+		return apperr.BadRequest(err.Error())
+	}
+
+	tag, err := s.tagFromPath(c, membership.WorkspaceID)
+	if err != nil {
+		return err
+	}
+	if name == tag.Name {
+		return c.JSON(http.StatusOK, tag)
+	}
+
+	old := tag.Name
+	if err := s.Tags.Rename(c.Request().Context(), &tag, name); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			return apperr.Conflict("Tag already exists")
+		}
+		return apperr.Internal(err, "Failed to rename tag")
+	}
+
+	s.recordAudit(c, audit.Entry{Action: "tag.rename", TargetType: "tag", TargetIDs: []uint{tag.ID}, Detail: old + " -> " + name})
+
+	return c.JSON(http.StatusOK, tag)
+}
+
+// DeleteTag removes the tag from every URL and deletes it.
+func (s *Server) DeleteTag(c echo.Context) error {
+	membership, err := requireRole(c, model.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	tag, err := s.tagFromPath(c, membership.WorkspaceID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Tags.Delete(c.Request().Context(), tag); err != nil {
+		return apperr.Internal(err, "Failed to delete tag")
+	}
+
+	s.recordAudit(c, audit.Entry{Action: "tag.delete", TargetType: "tag", TargetIDs: []uint{tag.ID}, Detail: tag.Name})
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// TagURLs adds tags to every listed URL, creating tags that do not exist
+// yet.
+func (s *Server) TagURLs(c echo.Context) error {
+	membership, err := requireRole(c, model.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	req, names, err := bindURLTags(c)
+	if err != nil {
+		return err
+	}
+
+	found, err := s.Tags.AddToURLs(c.Request().Context(), membership.WorkspaceID, req.IDs, names)
+	if err != nil {
+		return apperr.Internal(err, "Failed to tag URLs")
+	}
+
+	s.recordAudit(c, audit.Entry{Action: "url.tag", TargetType: "url", TargetIDs: found, Detail: strings.Join(names, ",")})
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"tagged":    found,
+		"not_found": missingIDs(req.IDs, found),
+	})
+}
+
+// UntagURLs removes tags from every listed URL. The tags themselves are
+// kept.
+func (s *Server) UntagURLs(c echo.Context) error {
+	membership, err := requireRole(c, model.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	req, names, err := bindURLTags(c)
+	if err != nil {
+		return err
+	}
+
+	found, err := s.Tags.RemoveFromURLs(c.Request().Context(), membership.WorkspaceID, req.IDs, names)
+	if err != nil {
+		return apperr.Internal(err, "Failed to untag URLs")
+	}
+
+	s.recordAudit(c, audit.Entry{Action: "url.untag", TargetType: "url", TargetIDs: found, Detail: strings.Join(names, ",")})
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"untagged":  found,
+		"not_found": missingIDs(req.IDs, found),
+	})
+}
+
+// UpdateURL changes a URL's notes and, when tags is given, replaces its
+// tags.
+func (s *Server) UpdateURL(c echo.Context) error {
+	membership, err := requireRole(c, model.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return apperr.BadRequest("Invalid URL id")
+	}
+
+	var req UpdateURLRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+	var tags *[]string
+	if req.Tags != nil {
+		names, err := normalizeTagNames(*req.Tags)
+		if err != nil {
+			return apperr.BadRequest(err.Error())
+		}
+		tags = &names
+	}
+
+	urlRecord, err := s.URLs.Update(c.Request().Context(), membership.WorkspaceID, uint(id), req.Notes, tags)
+	if errors.Is(err, store.ErrNotFound) {
+		return apperr.NotFound("URL not found")
+	}
+	if err != nil {
+		return apperr.Internal(err, "Failed to update URL")
+	}
+
+	s.recordAudit(c, audit.Entry{Action: "url.update", TargetType: "url", TargetIDs: []uint{urlRecord.ID}})
+
+	return c.JSON(http.StatusOK, urlRecord)
+}
+
+func bindURLTags(c echo.Context) (URLTagsRequest, []string, error) {
+	var req URLTagsRequest
+	if err := bindRequest(c, &req); err != nil {
+		return req, nil, err
+	}
+	names, err := normalizeTagNames(req.Tags)
+	if err != nil {
+		return req, nil, apperr.BadRequest(err.Error())
+	}
+	return req, names, nil
+}
+
+func (s *Server) tagFromPath(c echo.Context, workspaceID uint) (model.Tag, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return model.Tag{}, apperr.BadRequest("Invalid tag id")
+	}
+	tag, err := s.Tags.Get(c.Request().Context(), workspaceID, uint(id))
+	if err != nil {
+		return tag, apperr.NotFound("Tag not found")
+	}
+	return tag, nil
+}
+
+// missingIDs returns the IDs in ids that are not in found, in order.
+func missingIDs(ids, found []uint) []uint {
+	present := make(map[uint]bool, len(found))
+	for _, id := range found {
+		present[id] = true
+	}
+	var missing []uint
+	for _, id := range ids {
+		if !present[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+// normalizeTagName trims and lower-cases a tag name so "Client-A" and
+// "client-a " are the same tag. Commas are reserved as the separator in
+// tag filters.
+func normalizeTagName(raw string) (string, error) {
+	name := strings.ToLower(strings.TrimSpace(raw))
+	switch {
+	case name == "":
+		return "", errors.New("Tag name is required")
+	case len(name) > maxTagLength:
+		return "", fmt.Errorf("Tag names are limited to %d characters", maxTagLength)
+	case strings.Contains(name, ","):
+		return "", errors.New("Tag names cannot contain commas")
+	}
+	return name, nil
+}
+
+func normalizeTagNames(raw []string) ([]string, error) {
+	names := make([]string, 0, len(raw))
+	seen := map[string]bool{}
+	for _, r := range raw {
+		name, err := normalizeTagName(r)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
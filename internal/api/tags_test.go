@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"url-crawler-backend/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestTagsAndNotes(t *testing.T) {
+	t.Parallel()
+	testDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	testDB.AutoMigrate(&model.URL{}, &model.Tag{}, &model.User{}, &model.Workspace{}, &model.Membership{}, &model.AuditLog{})
+
+	s := NewServer(testDB)
+
+	user := model.User{Username: "alice"}
+	testDB.Create(&user)
+
+	rec := callAs(user.ID, "", http.MethodPost, "/api/urls", map[string]interface{}{"url": "https://a.example", "tags": []string{"Client-A"}, "notes": "homepage"}, s.WorkspaceMiddleware(s.AddURL))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var a model.URL
+	json.Unmarshal(rec.Body.Bytes(), &a)
+	require.Len(t, a.Tags, 1)
+	assert.Equal(t, "client-a", a.Tags[0].Name)
+	assert.Equal(t, "homepage", a.Notes)
+
+	rec = callAs(user.ID, "", http.MethodPost, "/api/urls", map[string]string{"url": "https://b.example"}, s.WorkspaceMiddleware(s.AddURL))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var b model.URL
+	json.Unmarshal(rec.Body.Bytes(), &b)
+
+	rec = callAs(user.ID, "", http.MethodPost, "/api/tags", map[string]string{"name": "client-a"}, s.WorkspaceMiddleware(s.CreateTag))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	rec = callAs(user.ID, "", http.MethodPost, "/api/tags", map[string]string{"name": "a,b"}, s.WorkspaceMiddleware(s.CreateTag))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = callAs(user.ID, "", http.MethodPost, "/api/urls/tags", map[string]interface{}{"ids": []uint{a.ID, b.ID, 999}, "tags": []string{"campaign", "client-a"}}, s.WorkspaceMiddleware(s.TagURLs))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"tagged": [`+strconv.Itoa(int(a.ID))+`, `+strconv.Itoa(int(b.ID))+`], "not_found": [999]}`, rec.Body.String())
+
+	var links int64
+	testDB.Table("url_tags").Count(&links)
+	assert.Equal(t, int64(4), links, "tagging twice does not duplicate links")
+
+	rec = callAs(user.ID, "", http.MethodGet, "/api/tags", nil, s.WorkspaceMiddleware(s.GetTags))
+	var tags []model.Tag
+	json.Unmarshal(rec.Body.Bytes(), &tags)
+	require.Len(t, tags, 2)
+	assert.Equal(t, "campaign", tags[0].Name)
+
+	rec = callAs(user.ID, "", http.MethodDelete, "/api/urls/tags", map[string]interface{}{"ids": []uint{b.ID}, "tags": []string{"client-a"}}, s.WorkspaceMiddleware(s.UntagURLs))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = callAs(user.ID, "", http.MethodGet, "/api/urls?tag=client-a", nil, s.WorkspaceMiddleware(s.GetURLs))
+	var urls []model.URL
+	json.Unmarshal(rec.Body.Bytes(), &urls)
+	require.Len(t, urls, 1)
+	assert.Equal(t, a.ID, urls[0].ID)
+	assert.Len(t, urls[0].Tags, 2)
+
+	rec = callAs(user.ID, "", http.MethodGet, "/api/urls?tag=client-a,campaign", nil, s.WorkspaceMiddleware(s.GetURLs))
+	json.Unmarshal(rec.Body.Bytes(), &urls)
+	assert.Len(t, urls, 2)
+
+	rec = callAs(user.ID, "", http.MethodPost, "/api/urls/crawl", map[string][]string{"tags": {"client-a"}}, s.WorkspaceMiddleware(s.StartBulkCrawl))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var crawl struct {
+		Started []uint `json:"started"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &crawl)
+	assert.Equal(t, []uint{a.ID}, crawl.Started)
+
+	// Listed IDs come first, then the tagged URLs not already listed.
+	rec = callAs(user.ID, "", http.MethodPost, "/api/urls/crawl", map[string]interface{}{"ids": []uint{b.ID, 999}, "tags": []string{"client-a"}}, s.WorkspaceMiddleware(s.StartBulkCrawl))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"message": "Bulk crawl started", "started": [`+strconv.Itoa(int(b.ID))+`, `+strconv.Itoa(int(a.ID))+`], "not_found": [999]}`, rec.Body.String())
+
+	rec = callAs(user.ID, "", http.MethodPatch, "/api/urls/"+strconv.Itoa(int(b.ID)), map[string]interface{}{"notes": "checked", "tags": []string{"new"}}, s.WorkspaceMiddleware(s.UpdateURL), "id", strconv.Itoa(int(b.ID)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	json.Unmarshal(rec.Body.Bytes(), &b)
+	assert.Equal(t, "checked", b.Notes)
+	require.Len(t, b.Tags, 1)
+	assert.Equal(t, "new", b.Tags[0].Name)
+
+	campaign := strconv.Itoa(int(tags[0].ID))
+	rec = callAs(user.ID, "", http.MethodPatch, "/api/tags/"+campaign, map[string]string{"name": "new"}, s.WorkspaceMiddleware(s.RenameTag), "id", campaign)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	rec = callAs(user.ID, "", http.MethodPatch, "/api/tags/"+campaign, map[string]string{"name": "Spring Sale"}, s.WorkspaceMiddleware(s.RenameTag), "id", campaign)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"name":"spring sale"`)
+
+	rec = callAs(user.ID, "", http.MethodDelete, "/api/tags/"+campaign, nil, s.WorkspaceMiddleware(s.DeleteTag), "id", campaign)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	testDB.Table("url_tags").Count(&links)
+	assert.Equal(t, int64(2), links)
+}
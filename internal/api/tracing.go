@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HeaderTraceparent carries the W3C trace context of the caller's span.
+const HeaderTraceparent = "traceparent"
+
+// tracerName names the spans' instrumentation scope. The tracer is looked
+// up for each span so it follows the provider installed at the time.
+const tracerName = "url-crawler-backend/internal/api"
+
+// Tracing starts a server span for every request, continuing the trace in
+// an incoming traceparent header. Handlers get the span through the
+// request context, so the queries and crawls they start join the trace.
+// It must be installed before RenderErrors to see the status of errors,
+// which ErrorHandler records on the span.
+func Tracing(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		ctx, span := otel.Tracer(tracerName).Start(ctx, req.Method, trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+		c.SetRequest(req.WithContext(ctx))
+
+		err := next(c)
+
+		route := c.Path()
+		if route != "" && route != "/*" {
+			span.SetName(req.Method + " " + route)
+			span.SetAttributes(attribute.String("http.route", route))
+		}
+		status := c.Response().Status
+		span.SetAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("url.path", req.URL.Path),
+			attribute.Int("http.response.status_code", status),
+		)
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		return err
+	}
+}
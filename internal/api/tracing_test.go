@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-crawler-backend/internal/model"
+	"url-crawler-backend/internal/store"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// recordSpans records the spans of every tracer for the duration of the
+// test. Tests that use it replace the process-wide tracer provider, so they
+// do not call t.Parallel and run before the parallel tests start.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+	return recorder
+}
+
+func TestTracing(t *testing.T) {
+	recorder := recordSpans(t)
+	e := echo.New()
+	e.Use(Tracing, RenderErrors)
+	NewServer(nil).RegisterRoutes(e)
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set(HeaderTraceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/no/such/page", nil))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	health := spans[0]
+	assert.Equal(t, "GET /healthz", health.Name())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", health.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", health.Parent().SpanID().String())
+	// Unmatched paths are not used as span names.
+	assert.Equal(t, "GET", spans[1].Name())
+	assert.False(t, spans[1].Parent().IsValid())
+	assert.Contains(t, spans[1].Attributes(), attribute.Int("http.response.status_code", http.StatusNotFound))
+	// The error handler records the error on the span.
+	require.Len(t, spans[1].Events(), 1)
+	assert.Equal(t, "exception", spans[1].Events()[0].Name)
+}
+
+func TestStartCrawlContinuesTrace(t *testing.T) {
+	recorder := recordSpans(t)
+	s := NewServer(nil)
+	s.URLs = store.NewMemoryURLStore()
+	u := model.URL{WorkspaceID: 1, URL: "ftp://example.com"}
+	require.NoError(t, s.URLs.Create(context.Background(), &u, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx, request := otel.Tracer("test").Start(ctx, "request")
+	s.startCrawl(ctx, u, 7)
+	// The crawl carries on after the request is over.
+	request.End()
+	cancel()
+
+	byName := map[string]sdktrace.ReadOnlySpan{}
+	require.Eventually(t, func() bool {
+		// Crawls left running by other tests may end spans too.
+		for _, span := range recorder.Ended() {
+			if span.SpanContext().TraceID() == request.SpanContext().TraceID() {
+				byName[span.Name()] = span
+			}
+		}
+		return byName["crawl"] != nil
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, request.SpanContext().SpanID(), byName["crawl"].Parent().SpanID())
+	require.Contains(t, byName, "crawler.CrawlURL")
+	assert.Equal(t, byName["crawl"].SpanContext().SpanID(), byName["crawler.CrawlURL"].Parent().SpanID())
+
+	saved, err := s.URLs.Get(context.Background(), 1, u.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "error", saved.Status)
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+
+	"url-crawler-backend/internal/apperr"
+	"url-crawler-backend/internal/audit"
+	"url-crawler-backend/internal/model"
+
+	"github.com/labstack/echo/v4"
+)
+
+func (s *Server) GetTrash(c echo.Context) error {
+	membership, err := requireRole(c, model.RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	urls, err := s.URLs.Trash(c.Request().Context(), membership.WorkspaceID)
+	if err != nil {
+		return apperr.Internal(err, "Failed to fetch trash")
+	}
+
+	return c.JSON(http.StatusOK, urls)
+}
+
+func (s *Server) RestoreURLs(c echo.Context) error {
+	membership, err := requireRole(c, model.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	var req DeleteURLsRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	trashed, err := s.URLs.Restore(c.Request().Context(), membership.WorkspaceID, req.IDs)
+	if err != nil {
+		return apperr.Internal(err, "Failed to restore URLs")
+	}
+	if len(trashed) > 0 {
+		s.recordAudit(c, audit.Entry{Action: "url.restore", TargetType: "url", TargetIDs: trashed})
+	}
+
+	restored := make(map[uint]bool, len(trashed))
+	for _, id := range trashed {
+		restored[id] = true
+	}
+	notFound := []uint{}
+	for _, id := range req.IDs {
+		if !restored[id] {
+			notFound = append(notFound, id)
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"restored":  trashed,
+		"not_found": notFound,
+	})
+}
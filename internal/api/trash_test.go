@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"url-crawler-backend/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestDeleteMovesURLsToTrashAndRestore(t *testing.T) {
+	t.Parallel()
+	testDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	testDB.AutoMigrate(&model.URL{}, &model.User{}, &model.Workspace{}, &model.Membership{}, &model.AuditLog{})
+
+	s := NewServer(testDB)
+
+	user := model.User{Username: "alice"}
+	testDB.Create(&user)
+	workspace := model.Workspace{Name: "Team"}
+	testDB.Create(&workspace)
+	testDB.Create(&model.Membership{WorkspaceID: workspace.ID, UserID: user.ID, Role: model.RoleAdmin})
+	ws := "1"
+
+	kept := model.URL{WorkspaceID: workspace.ID, URL: "https://kept.example", Status: "done"}
+	trashed := model.URL{WorkspaceID: workspace.ID, URL: "https://trashed.example", Status: "done"}
+	testDB.Create(&kept)
+	testDB.Create(&trashed)
+
+	rec := callAs(user.ID, ws, http.MethodDelete, "/api/urls", map[string][]uint{"ids": {trashed.ID}}, s.WorkspaceMiddleware(s.DeleteURLs))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	var urls []model.URL
+	rec = callAs(user.ID, ws, http.MethodGet, "/api/urls", nil, s.WorkspaceMiddleware(s.GetURLs))
+	json.Unmarshal(rec.Body.Bytes(), &urls)
+	require.Len(t, urls, 1)
+	assert.Equal(t, kept.ID, urls[0].ID)
+
+	rec = callAs(user.ID, ws, http.MethodGet, "/api/urls/trash", nil, s.WorkspaceMiddleware(s.GetTrash))
+	require.Equal(t, http.StatusOK, rec.Code)
+	json.Unmarshal(rec.Body.Bytes(), &urls)
+	require.Len(t, urls, 1)
+	assert.Equal(t, trashed.ID, urls[0].ID)
+	assert.True(t, urls[0].DeletedAt.Valid)
+
+	rec = callAs(user.ID, ws, http.MethodPost, "/api/urls/restore", map[string][]uint{"ids": {trashed.ID, kept.ID}}, s.WorkspaceMiddleware(s.RestoreURLs))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var restore struct {
+		Restored []uint `json:"restored"`
+		NotFound []uint `json:"not_found"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &restore)
+	assert.Equal(t, []uint{trashed.ID}, restore.Restored)
+	assert.Equal(t, []uint{kept.ID}, restore.NotFound)
+
+	rec = callAs(user.ID, ws, http.MethodGet, "/api/urls", nil, s.WorkspaceMiddleware(s.GetURLs))
+	json.Unmarshal(rec.Body.Bytes(), &urls)
+	assert.Len(t, urls, 2)
+}
@@ -0,0 +1,329 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"url-crawler-backend/internal/apperr"
+	"url-crawler-backend/internal/audit"
+	"url-crawler-backend/internal/model"
+	"url-crawler-backend/internal/totp"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	challengeTokenType = "2fa_challenge"
+	challengeTokenTTL  = 5 * time.Minute
+	recoveryCodeCount  = 10
+
+	// After maxTwoFactorFailures wrong codes in a row, every 2FA check of
+	// the user is refused for twoFactorLockout. Logging in again does not
+	// lift the lockout.
+	maxTwoFactorFailures = 5
+	twoFactorLockout     = 15 * time.Minute
+)
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode   string `json:"recovery_code" validate:"required_without=Code"`
+}
+
+type TwoFactorConfirmRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code         string `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code" validate:"required_without=Code"`
+}
+
+// LoginTwoFactor completes a login started by Login or OIDCCallback for a
+// user with 2FA enabled, exchanging the challenge token and a TOTP or
+// recovery code for an access token.
+func (s *Server) LoginTwoFactor(c echo.Context) error {
+	var req TwoFactorLoginRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	token, err := jwt.Parse(req.ChallengeToken, s.Keys.Keyfunc)
+	if err != nil {
+		return apperr.Unauthorized("Invalid or expired challenge")
+	}
+	claims, _ := token.Claims.(jwt.MapClaims)
+	id, _ := claims["id"].(float64)
+	factor, _ := claims["factor"].(string)
+	if claims["typ"] != challengeTokenType || id <= 0 || (factor != "pwd" && factor != "sso") {
+		return apperr.Unauthorized("Invalid or expired challenge")
+	}
+
+	user, err := s.Users.Get(c.Request().Context(), uint(id))
+	if err != nil || !user.TOTPEnabled {
+		return apperr.Unauthorized("Invalid or expired challenge")
+	}
+
+	if err := s.checkSecondFactor(c.Request().Context(), &user, req.Code, req.RecoveryCode); err != nil {
+		s.recordAudit(c, audit.Entry{Action: "auth.login_2fa", Outcome: model.AuditFailure, ActorID: user.ID, ActorUsername: user.Username})
+		return err
+	}
+
+	s.recordAudit(c, audit.Entry{Action: "auth.login_2fa", ActorID: user.ID, ActorUsername: user.Username})
+
+	t, err := s.issueToken(user, factor, "otp")
+	if err != nil {
+		return apperr.Internal(err, "Failed to sign token")
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"token": t,
+	})
+}
+
+// EnrollTwoFactor generates a new pending TOTP secret. 2FA is not enforced
+// until the secret is confirmed with ConfirmTwoFactor.
+func (s *Server) EnrollTwoFactor(c echo.Context) error {
+	user, err := s.loadCurrentUser(c)
+	if err != nil {
+		return err
+	}
+	if user.TOTPEnabled {
+		return apperr.Conflict("Two-factor authentication is already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return apperr.Internal(err, "Failed to generate secret")
+	}
+
+	user.TOTPSecret = secret
+	if err := s.TwoFactor.SetSecret(c.Request().Context(), user.ID, secret); err != nil {
+		return apperr.Internal(err, "Failed to save secret")
+	}
+
+	s.recordAudit(c, audit.Entry{Action: "2fa.enroll", TargetType: "user", TargetIDs: []uint{user.ID}})
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"secret":           secret,
+		"provisioning_uri": totp.ProvisioningURI(s.TOTPIssuer, user.Username, secret),
+	})
+}
+
+// ConfirmTwoFactor enables 2FA once the user proves their authenticator
+// produces valid codes, and returns a fresh set of recovery codes.
+func (s *Server) ConfirmTwoFactor(c echo.Context) error {
+	var req TwoFactorConfirmRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	user, err := s.loadCurrentUser(c)
+	if err != nil {
+		return err
+	}
+	if user.TOTPEnabled {
+		return apperr.Conflict("Two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return apperr.BadRequest("Start enrollment first")
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, req.Code, time.Now())
+	if !ok {
+		return apperr.Unauthorized("Invalid code")
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err == nil {
+		err = s.TwoFactor.Enable(c.Request().Context(), user.ID, step, hashes)
+	}
+	if err != nil {
+		return apperr.Internal(err, "Failed to enable two-factor authentication")
+	}
+
+	s.recordAudit(c, audit.Entry{Action: "2fa.confirm", TargetType: "user", TargetIDs: []uint{user.ID}})
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"recovery_codes": codes,
+	})
+}
+
+// RegenerateRecoveryCodes invalidates all previous recovery codes.
+func (s *Server) RegenerateRecoveryCodes(c echo.Context) error {
+	var req TwoFactorCodeRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	user, err := s.loadCurrentUser(c)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return apperr.BadRequest("Two-factor authentication is not enabled")
+	}
+	if err := s.checkSecondFactor(c.Request().Context(), user, req.Code, req.RecoveryCode); err != nil {
+		return err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err == nil {
+		err = s.TwoFactor.ReplaceRecoveryCodes(c.Request().Context(), user.ID, hashes)
+	}
+	if err != nil {
+		return apperr.Internal(err, "Failed to generate recovery codes")
+	}
+
+	s.recordAudit(c, audit.Entry{Action: "2fa.recovery_codes", TargetType: "user", TargetIDs: []uint{user.ID}})
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"recovery_codes": codes,
+	})
+}
+
+func (s *Server) DisableTwoFactor(c echo.Context) error {
+	var req TwoFactorCodeRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	user, err := s.loadCurrentUser(c)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return apperr.BadRequest("Two-factor authentication is not enabled")
+	}
+	if err := s.checkSecondFactor(c.Request().Context(), user, req.Code, req.RecoveryCode); err != nil {
+		return err
+	}
+
+	if err := s.TwoFactor.Disable(c.Request().Context(), user.ID); err != nil {
+		return apperr.Internal(err, "Failed to disable two-factor authentication")
+	}
+
+	s.recordAudit(c, audit.Entry{Action: "2fa.disable", TargetType: "user", TargetIDs: []uint{user.ID}})
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// checkSecondFactor verifies a code with verifySecondFactor, counting the
+// attempt against the user's limit of wrong codes.
+func (s *Server) checkSecondFactor(ctx context.Context, user *model.User, code, recoveryCode string) error {
+	now := time.Now()
+
+	// The attempt is counted before the code is checked, so concurrent
+	// guesses cannot get past the limit either.
+	reserved, err := s.TwoFactor.ReserveAttempt(ctx, user.ID, maxTwoFactorFailures, now)
+	if err != nil {
+		return apperr.Internal(err, "Failed to record attempt")
+	}
+	if !reserved {
+		if err := s.TwoFactor.Lock(ctx, user.ID, maxTwoFactorFailures, now.Add(twoFactorLockout)); err != nil {
+			return apperr.Internal(err, "Failed to record attempt")
+		}
+		return apperr.New(http.StatusTooManyRequests, apperr.CodeTwoFactorLocked, "Too many wrong codes, try again later")
+	}
+
+	// Once the last attempt is used up, the lockout starts and the count
+	// starts from zero again for when it ends.
+	if err := s.verifySecondFactor(ctx, user, code, recoveryCode); err != nil {
+		if lockErr := s.TwoFactor.Lock(ctx, user.ID, maxTwoFactorFailures, now.Add(twoFactorLockout)); lockErr != nil {
+			return apperr.Internal(lockErr, "Failed to record attempt")
+		}
+		return err
+	}
+
+	if err := s.TwoFactor.ResetAttempts(ctx, user.ID); err != nil {
+		return apperr.Internal(err, "Failed to record attempt")
+	}
+	return nil
+}
+
+// RequireTwoFactor allows only users who have enabled 2FA. It guards the
+// admin-only and destructive routes, so a password alone is not enough to
+// delete data or change who has access to it.
+func (s *Server) RequireTwoFactor(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user, err := s.loadCurrentUser(c)
+		if err != nil {
+			return err
+		}
+		if !user.TOTPEnabled {
+			return apperr.New(http.StatusForbidden, apperr.CodeTwoFactorRequired, "Enable two-factor authentication first")
+		}
+		return next(c)
+	}
+}
+
+// verifySecondFactor accepts either a TOTP code newer than the last one
+// used, or an unused recovery code, and records its use.
+func (s *Server) verifySecondFactor(ctx context.Context, user *model.User, code, recoveryCode string) error {
+	if code != "" {
+		step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+		if !ok || step <= user.TOTPLastStep {
+			return apperr.Unauthorized("Invalid code")
+		}
+		used, err := s.TwoFactor.UseStep(ctx, user.ID, step)
+		if err != nil || !used {
+			return apperr.Unauthorized("Invalid code")
+		}
+		user.TOTPLastStep = step
+		return nil
+	}
+
+	if recoveryCode != "" {
+		used, err := s.TwoFactor.UseRecoveryCode(ctx, user.ID, hashRecoveryCode(recoveryCode))
+		if err != nil || !used {
+			return apperr.Unauthorized("Invalid recovery code")
+		}
+		return nil
+	}
+
+	return apperr.BadRequest("Provide 'code' or 'recovery_code'")
+}
+
+// newRecoveryCodes generates a set of recovery codes and the hashes to
+// store for them.
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	codes = make([]string, 0, recoveryCodeCount)
+	hashes = make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+		code := raw[:8] + "-" + raw[8:16]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Server) loadCurrentUser(c echo.Context) (*model.User, error) {
+	id, err := currentUserID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.Users.Get(c.Request().Context(), id)
+	if err != nil {
+		return nil, apperr.Unauthorized("Unknown user")
+	}
+	return &user, nil
+}
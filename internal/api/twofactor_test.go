@@ -0,0 +1,257 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-crawler-backend/internal/jwtkeys"
+	"url-crawler-backend/internal/middleware"
+	"url-crawler-backend/internal/model"
+	"url-crawler-backend/internal/totp"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func postJSON(e *echo.Echo, path string, body interface{}, userID uint) (echo.Context, *httptest.ResponseRecorder) {
+	jsonBody, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(jsonBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	if userID != 0 {
+		c.Set("user", &jwt.Token{Claims: jwt.MapClaims{"id": float64(userID)}})
+	}
+	return c, rec
+}
+
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return body
+}
+
+func TestTwoFactorEnrollmentAndLogin(t *testing.T) {
+	t.Parallel()
+	e := echo.New()
+	testDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	testDB.AutoMigrate(&model.User{}, &model.RecoveryCode{})
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("testpassword"), bcrypt.DefaultCost)
+	user := model.User{Username: "admin", Password: string(hashedPassword)}
+	testDB.Create(&user)
+
+	s := NewServer(testDB)
+
+	// Enroll and confirm.
+	c, rec := postJSON(e, "/api/2fa/enroll", nil, user.ID)
+	require.NoError(t, s.EnrollTwoFactor(c))
+	enrollment := decodeBody(t, rec)
+	secret := enrollment["secret"].(string)
+	assert.Contains(t, enrollment["provisioning_uri"], "otpauth://totp/")
+
+	now := time.Now()
+	code, _ := totp.CodeAt(secret, totp.Step(now))
+	c, rec = postJSON(e, "/api/2fa/confirm", map[string]string{"code": code}, user.ID)
+	require.NoError(t, s.ConfirmTwoFactor(c))
+	recoveryCodes := decodeBody(t, rec)["recovery_codes"].([]interface{})
+	assert.Len(t, recoveryCodes, recoveryCodeCount)
+
+	// Password login now yields a challenge instead of a token.
+	c, rec = postJSON(e, "/login", map[string]string{"username": "admin", "password": "testpassword"}, 0)
+	require.NoError(t, s.Login(c))
+	login := decodeBody(t, rec)
+	assert.Equal(t, true, login["two_factor_required"])
+	assert.NotContains(t, login, "token")
+	challenge := login["challenge_token"].(string)
+
+	// The challenge token is not accepted as an access token.
+	assert.Equal(t, http.StatusUnauthorized, accessStatus(s.Keys, challenge))
+
+	// Reusing the confirmation code is rejected as a replay.
+	c, _ = postJSON(e, "/login/2fa", map[string]string{"challenge_token": challenge, "code": code}, 0)
+	err = s.LoginTwoFactor(c)
+	he, ok := err.(*echo.HTTPError)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusUnauthorized, he.Code)
+
+	next, _ := totp.CodeAt(secret, totp.Step(now)+1)
+	c, rec = postJSON(e, "/login/2fa", map[string]string{"challenge_token": challenge, "code": next}, 0)
+	require.NoError(t, s.LoginTwoFactor(c))
+	accessToken := decodeBody(t, rec)["token"].(string)
+	assert.Equal(t, http.StatusOK, accessStatus(s.Keys, accessToken))
+
+	// Recovery codes work exactly once.
+	recovery := recoveryCodes[0].(string)
+	c, rec = postJSON(e, "/login/2fa", map[string]string{"challenge_token": challenge, "recovery_code": recovery}, 0)
+	require.NoError(t, s.LoginTwoFactor(c))
+	assert.NotEmpty(t, decodeBody(t, rec)["token"])
+
+	c, _ = postJSON(e, "/login/2fa", map[string]string{"challenge_token": challenge, "recovery_code": recovery}, 0)
+	assert.Error(t, s.LoginTwoFactor(c))
+}
+
+func TestLoginTwoFactorLimitsFailures(t *testing.T) {
+	t.Parallel()
+	e := echo.New()
+	testDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	testDB.AutoMigrate(&model.User{}, &model.RecoveryCode{})
+
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("testpassword"), bcrypt.DefaultCost)
+	user := model.User{Username: "admin", Password: string(hashedPassword), TOTPSecret: secret, TOTPEnabled: true}
+	testDB.Create(&user)
+
+	s := NewServer(testDB)
+	login := func() string {
+		c, rec := postJSON(e, "/login", map[string]string{"username": "admin", "password": "testpassword"}, 0)
+		require.NoError(t, s.Login(c))
+		return decodeBody(t, rec)["challenge_token"].(string)
+	}
+	challenge := login()
+
+	for i := 0; i < maxTwoFactorFailures-1; i++ {
+		c, _ := postJSON(e, "/login/2fa", map[string]string{"challenge_token": challenge, "recovery_code": "wrong"}, 0)
+		require.Error(t, s.LoginTwoFactor(c))
+	}
+
+	// Logging in with the password again does not reset the count, so the
+	// next wrong code locks the user out.
+	challenge = login()
+	c, _ := postJSON(e, "/login/2fa", map[string]string{"challenge_token": challenge, "recovery_code": "wrong"}, 0)
+	require.Error(t, s.LoginTwoFactor(c))
+
+	// While locked out even the right code is refused.
+	code, _ := totp.CodeAt(secret, totp.Step(time.Now()))
+	c, _ = postJSON(e, "/login/2fa", map[string]string{"challenge_token": login(), "code": code}, 0)
+	err = s.LoginTwoFactor(c)
+	he, ok := err.(*echo.HTTPError)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusTooManyRequests, he.Code)
+
+	// Once the lockout is over the right code is accepted.
+	require.NoError(t, testDB.Model(&user).Update("two_factor_locked_until", time.Now().Add(-time.Second)).Error)
+	c, rec := postJSON(e, "/login/2fa", map[string]string{"challenge_token": login(), "code": code}, 0)
+	require.NoError(t, s.LoginTwoFactor(c))
+	assert.NotEmpty(t, decodeBody(t, rec)["token"])
+}
+
+func TestDisableTwoFactorLimitsFailures(t *testing.T) {
+	t.Parallel()
+	e := echo.New()
+	testDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	testDB.AutoMigrate(&model.User{}, &model.RecoveryCode{})
+
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	user := model.User{Username: "admin", Password: "hash", TOTPSecret: secret, TOTPEnabled: true}
+	testDB.Create(&user)
+
+	s := NewServer(testDB)
+	for i := 0; i < maxTwoFactorFailures; i++ {
+		c, _ := postJSON(e, "/api/2fa/disable", map[string]string{"recovery_code": "wrong"}, user.ID)
+		require.Error(t, s.DisableTwoFactor(c))
+	}
+
+	code, _ := totp.CodeAt(secret, totp.Step(time.Now()))
+	c, _ := postJSON(e, "/api/2fa/recovery-codes", map[string]string{"code": code}, user.ID)
+	err = s.RegenerateRecoveryCodes(c)
+	he, ok := err.(*echo.HTTPError)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusTooManyRequests, he.Code)
+
+	var stored model.User
+	require.NoError(t, testDB.First(&stored, user.ID).Error)
+	assert.True(t, stored.TOTPEnabled)
+}
+
+func TestRequireTwoFactor(t *testing.T) {
+	t.Parallel()
+	e := echo.New()
+	testDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	testDB.AutoMigrate(&model.User{})
+
+	without := model.User{Username: "plain", Password: "hash"}
+	with := model.User{Username: "secure", Password: "hash", TOTPEnabled: true}
+	testDB.Create(&without)
+	testDB.Create(&with)
+
+	s := NewServer(testDB)
+	handler := s.RequireTwoFactor(func(c echo.Context) error { return c.NoContent(http.StatusNoContent) })
+
+	c, _ := postJSON(e, "/api/urls", nil, without.ID)
+	err = handler(c)
+	he, ok := err.(*echo.HTTPError)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusForbidden, he.Code)
+
+	c, rec := postJSON(e, "/api/urls", nil, with.ID)
+	require.NoError(t, handler(c))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestTwoFactorRequiresCode(t *testing.T) {
+	t.Parallel()
+	e := echo.New()
+	s := NewServer(nil)
+
+	for path, handler := range map[string]echo.HandlerFunc{
+		"/login/2fa":              s.LoginTwoFactor,
+		"/api/2fa/confirm":        s.ConfirmTwoFactor,
+		"/api/2fa/recovery-codes": s.RegenerateRecoveryCodes,
+		"/api/2fa/disable":        s.DisableTwoFactor,
+	} {
+		c, _ := postJSON(e, path, map[string]string{"challenge_token": "token"}, 1)
+		err := handler(c)
+		he, ok := err.(*echo.HTTPError)
+		require.True(t, ok, path)
+		assert.Equal(t, http.StatusBadRequest, he.Code, path)
+	}
+
+	// Enrollment can only be confirmed with a code from the authenticator.
+	c, _ := postJSON(e, "/api/2fa/confirm", map[string]string{"recovery_code": "abcd-efgh"}, 1)
+	err := s.ConfirmTwoFactor(c)
+	he, ok := err.(*echo.HTTPError)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusBadRequest, he.Code)
+}
+
+func TestLoginTwoFactorRejectsAccessToken(t *testing.T) {
+	t.Parallel()
+	e := echo.New()
+	s := NewServer(nil)
+	accessToken, err := s.issueToken(model.User{ID: 1, Username: "admin"}, "pwd")
+	require.NoError(t, err)
+
+	c, _ := postJSON(e, "/login/2fa", map[string]string{"challenge_token": accessToken, "code": "123456"}, 0)
+	err = s.LoginTwoFactor(c)
+	he, ok := err.(*echo.HTTPError)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusUnauthorized, he.Code)
+}
+
+// accessStatus runs token through JWTMiddleware and returns the status.
+func accessStatus(keys *jwtkeys.KeySet, token string) int {
+	e := echo.New()
+	e.GET("/api/urls", func(c echo.Context) error { return c.NoContent(http.StatusOK) }, middleware.JWTMiddleware(keys))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/urls", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec.Code
+}
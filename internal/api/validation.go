@@ -0,0 +1,124 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"url-crawler-backend/internal/apperr"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// FieldError describes one request field that failed validation.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// RequestValidator checks request structs against their validate tags and
+// reports failures as a validation_failed error whose details list every
+// invalid field:
+//
+//	{"code": "validation_failed", "message": "Validation failed", "details": [{"field": "url", "rule": "required", "message": "url is required"}]}
+type RequestValidator struct {
+	validate *validator.Validate
+}
+
+func NewValidator() *RequestValidator {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	return &RequestValidator{validate: v}
+}
+
+func (rv *RequestValidator) Validate(i interface{}) error {
+	err := rv.validate.Struct(i)
+	if err == nil {
+		return nil
+	}
+
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		return apperr.BadRequest("Invalid request")
+	}
+
+	fields := make([]FieldError, len(invalid))
+	for i, fe := range invalid {
+		fields[i] = fieldError(fe)
+	}
+	return apperr.WithDetails(http.StatusBadRequest, apperr.CodeValidation, "Validation failed", fields)
+}
+
+var defaultValidator = NewValidator()
+
+// bindRequest decodes the request body into req and validates it with the
+// Echo instance's validator.
+func bindRequest(c echo.Context, req interface{}) error {
+	if err := c.Bind(req); err != nil {
+		return apperr.BadRequest("Invalid request payload")
+	}
+
+	v := c.Echo().Validator
+	if v == nil {
+		v = defaultValidator
+	}
+	return v.Validate(req)
+}
+
+func fieldError(fe validator.FieldError) FieldError {
+	// Drop the struct name from "AddURLRequest.tags[0]".
+	_, field, _ := strings.Cut(fe.Namespace(), ".")
+	if field == "" {
+		field = fe.Field()
+	}
+
+	var message string
+	switch fe.Tag() {
+	case "required":
+		message = "is required"
+	case "required_without":
+		message = fmt.Sprintf("is required when %s is not provided", strings.ToLower(fe.Param()))
+	case "url", "http_url":
+		message = "must be a valid URL"
+	case "oneof":
+		message = "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "min", "max", "len":
+		message = lengthMessage(fe)
+	default:
+		message = fmt.Sprintf("failed the '%s' check", fe.Tag())
+	}
+
+	return FieldError{Field: field, Rule: fe.Tag(), Message: field + " " + message}
+}
+
+func lengthMessage(fe validator.FieldError) string {
+	unit := "characters"
+	switch fe.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		unit = "items"
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64, reflect.Float64:
+		unit = ""
+	}
+
+	bound := map[string]string{"min": "at least", "max": "at most", "len": "exactly"}[fe.Tag()]
+	if unit == "" {
+		return fmt.Sprintf("must be %s %s", bound, fe.Param())
+	}
+	if fe.Param() == "1" {
+		unit = strings.TrimSuffix(unit, "s")
+	}
+	return fmt.Sprintf("must have %s %s %s", bound, fe.Param(), unit)
+}
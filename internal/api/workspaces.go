@@ -0,0 +1,340 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"url-crawler-backend/internal/apperr"
+	"url-crawler-backend/internal/audit"
+	"url-crawler-backend/internal/model"
+	"url-crawler-backend/internal/store"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HeaderWorkspaceID selects the active workspace for /api/urls requests.
+const HeaderWorkspaceID = "X-Workspace-ID"
+
+const membershipContextKey = "membership"
+
+type CreateWorkspaceRequest struct {
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+type AddMemberRequest struct {
+	Username string `json:"username" validate:"required"`
+	Role     string `json:"role" validate:"omitempty,oneof=viewer editor admin owner"`
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role" validate:"required,oneof=viewer editor admin owner"`
+}
+
+// WorkspaceMiddleware resolves the active workspace from the X-Workspace-ID
+// header, or the user's first workspace when the header is absent, and
+// stores the caller's membership in the context.
+func (s *Server) WorkspaceMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userID, err := currentUserID(c)
+		if err != nil {
+			return err
+		}
+
+		var membership *model.Membership
+		if raw := c.Request().Header.Get(HeaderWorkspaceID); raw != "" {
+			id, err := strconv.ParseUint(raw, 10, 64)
+			if err != nil {
+				return apperr.BadRequest("Invalid " + HeaderWorkspaceID + " header")
+			}
+			membership, err = s.findMembership(c.Request().Context(), uint(id), userID)
+			if err != nil {
+				return err
+			}
+		} else {
+			membership, err = s.defaultMembership(c.Request().Context(), userID)
+			if err != nil {
+				return apperr.Internal(err, "Failed to load workspace")
+			}
+		}
+
+		SetMembership(c, *membership)
+		c.Response().Header().Set(HeaderWorkspaceID, strconv.FormatUint(uint64(membership.WorkspaceID), 10))
+		return next(c)
+	}
+}
+
+// SetMembership makes m the caller's membership in the active workspace.
+func SetMembership(c echo.Context, m model.Membership) {
+	c.Set(membershipContextKey, m)
+}
+
+func currentMembership(c echo.Context) (model.Membership, error) {
+	m, ok := c.Get(membershipContextKey).(model.Membership)
+	if !ok {
+		return model.Membership{}, apperr.Forbidden("No active workspace")
+	}
+	return m, nil
+}
+
+// requireRole returns the caller's membership in the active workspace if
+// their role grants at least min.
+func requireRole(c echo.Context, min string) (model.Membership, error) {
+	m, err := currentMembership(c)
+	if err != nil {
+		return m, err
+	}
+	if !model.RoleAtLeast(m.Role, min) {
+		return m, apperr.New(http.StatusForbidden, apperr.CodeInsufficientRole, fmt.Sprintf("Requires the %s role in this workspace", min))
+	}
+	return m, nil
+}
+
+func (s *Server) findMembership(ctx context.Context, workspaceID, userID uint) (*model.Membership, error) {
+	m, err := s.Workspaces.Membership(ctx, workspaceID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, apperr.Forbidden("Not a member of this workspace")
+	}
+	if err != nil {
+		return nil, apperr.Internal(err, "Failed to load workspace")
+	}
+	return &m, nil
+}
+
+// defaultMembership returns the user's oldest membership, creating a
+// personal workspace for users who have none yet.
+func (s *Server) defaultMembership(ctx context.Context, userID uint) (*model.Membership, error) {
+	m, err := s.Workspaces.FirstMembership(ctx, userID)
+	if err == nil {
+		return &m, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+
+	user, err := s.Users.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	workspace := model.Workspace{Name: user.Username + "'s workspace", Personal: true, PersonalUserID: &userID}
+	created, err := s.Workspaces.Create(ctx, &workspace, userID)
+	if err != nil {
+		// A concurrent request may have created the personal workspace
+		// since the lookup.
+		if m, findErr := s.Workspaces.FirstMembership(ctx, userID); findErr == nil {
+			return &m, nil
+		}
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (s *Server) GetWorkspaces(c echo.Context) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	memberships, err := s.Workspaces.Memberships(c.Request().Context(), userID)
+	if err != nil {
+		return apperr.Internal(err, "Failed to fetch workspaces")
+	}
+
+	return c.JSON(http.StatusOK, memberships)
+}
+
+func (s *Server) CreateWorkspace(c echo.Context) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var req CreateWorkspaceRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	membership, err := s.Workspaces.Create(c.Request().Context(), &model.Workspace{Name: req.Name}, userID)
+	if err != nil {
+		return apperr.Internal(err, "Failed to create workspace")
+	}
+
+	s.recordAudit(c, audit.Entry{Action: "workspace.create", WorkspaceID: membership.WorkspaceID, TargetType: "workspace", TargetIDs: []uint{membership.WorkspaceID}})
+
+	return c.JSON(http.StatusCreated, membership)
+}
+
+func (s *Server) GetWorkspaceMembers(c echo.Context) error {
+	workspaceID, _, err := s.workspaceFromPath(c, model.RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	members, err := s.Workspaces.Members(c.Request().Context(), workspaceID)
+	if err != nil {
+		return apperr.Internal(err, "Failed to fetch members")
+	}
+
+	return c.JSON(http.StatusOK, members)
+}
+
+func (s *Server) AddWorkspaceMember(c echo.Context) error {
+	workspaceID, caller, err := s.workspaceFromPath(c, model.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	var req AddMemberRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+	if req.Role == "" {
+		req.Role = model.RoleViewer
+	}
+	if err := checkAssignableRole(caller, req.Role); err != nil {
+		return err
+	}
+
+	user, err := s.Users.ByUsername(c.Request().Context(), req.Username)
+	if err != nil {
+		return apperr.NotFound("User not found")
+	}
+
+	membership := model.Membership{WorkspaceID: workspaceID, UserID: user.ID, Role: req.Role}
+	if err := s.Workspaces.AddMember(c.Request().Context(), &membership); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			return apperr.Conflict("User is already a member")
+		}
+		return apperr.Internal(err, "Failed to add member")
+	}
+
+	membership.User = &user
+
+	s.recordAudit(c, audit.Entry{Action: "workspace.member_add", WorkspaceID: workspaceID, TargetType: "user", TargetIDs: []uint{user.ID}, Detail: "role=" + req.Role})
+
+	return c.JSON(http.StatusCreated, membership)
+}
+
+func (s *Server) UpdateWorkspaceMember(c echo.Context) error {
+	workspaceID, caller, err := s.workspaceFromPath(c, model.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	var req UpdateMemberRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+	if err := checkAssignableRole(caller, req.Role); err != nil {
+		return err
+	}
+
+	target, err := s.memberFromPath(c, workspaceID)
+	if err != nil {
+		return err
+	}
+	if target.Role == model.RoleOwner && caller.Role != model.RoleOwner {
+		return apperr.Forbidden("Only owners can change an owner's role")
+	}
+	if target.Role == model.RoleOwner && req.Role != model.RoleOwner {
+		if err := s.ensureAnotherOwner(c.Request().Context(), workspaceID, target.UserID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.Workspaces.SetRole(c.Request().Context(), &target, req.Role); err != nil {
+		return apperr.Internal(err, "Failed to update member")
+	}
+
+	s.recordAudit(c, audit.Entry{Action: "workspace.member_update", WorkspaceID: workspaceID, TargetType: "user", TargetIDs: []uint{target.UserID}, Detail: "role=" + req.Role})
+
+	return c.JSON(http.StatusOK, target)
+}
+
+func (s *Server) RemoveWorkspaceMember(c echo.Context) error {
+	workspaceID, caller, err := s.workspaceFromPath(c, model.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	target, err := s.memberFromPath(c, workspaceID)
+	if err != nil {
+		return err
+	}
+	if target.Role == model.RoleOwner {
+		if caller.Role != model.RoleOwner {
+			return apperr.Forbidden("Only owners can remove an owner")
+		}
+		if err := s.ensureAnotherOwner(c.Request().Context(), workspaceID, target.UserID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.Workspaces.RemoveMember(c.Request().Context(), target); err != nil {
+		return apperr.Internal(err, "Failed to remove member")
+	}
+
+	s.recordAudit(c, audit.Entry{Action: "workspace.member_remove", WorkspaceID: workspaceID, TargetType: "user", TargetIDs: []uint{target.UserID}})
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// workspaceFromPath checks that the caller holds at least min in the
+// workspace named by the :id path parameter.
+func (s *Server) workspaceFromPath(c echo.Context, min string) (uint, *model.Membership, error) {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return 0, nil, apperr.BadRequest("Invalid workspace id")
+	}
+
+	membership, err := s.findMembership(c.Request().Context(), uint(id), userID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !model.RoleAtLeast(membership.Role, min) {
+		return 0, nil, apperr.New(http.StatusForbidden, apperr.CodeInsufficientRole, fmt.Sprintf("Requires the %s role in this workspace", min))
+	}
+
+	return uint(id), membership, nil
+}
+
+func (s *Server) memberFromPath(c echo.Context, workspaceID uint) (model.Membership, error) {
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
+	if err != nil {
+		return model.Membership{}, apperr.BadRequest("Invalid user id")
+	}
+	target, err := s.Workspaces.Member(c.Request().Context(), workspaceID, uint(userID))
+	if err != nil {
+		return target, apperr.NotFound("Member not found")
+	}
+	return target, nil
+}
+
+func checkAssignableRole(caller *model.Membership, role string) error {
+	if !model.ValidRole(role) {
+		return apperr.BadRequest("Role must be one of viewer, editor, admin, owner")
+	}
+	if role == model.RoleOwner && caller.Role != model.RoleOwner {
+		return apperr.Forbidden("Only owners can appoint owners")
+	}
+	return nil
+}
+
+func (s *Server) ensureAnotherOwner(ctx context.Context, workspaceID, userID uint) error {
+	owners, err := s.Workspaces.OtherOwners(ctx, workspaceID, userID)
+	if err != nil {
+		return apperr.Internal(err, "Failed to load members")
+	}
+	if owners == 0 {
+		return apperr.Conflict("A workspace must keep at least one owner")
+	}
+	return nil
+}
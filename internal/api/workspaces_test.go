@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"url-crawler-backend/internal/model"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupWorkspaces(t *testing.T) (*Server, *gorm.DB, []model.User) {
+	testDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	testDB.AutoMigrate(&model.URL{}, &model.User{}, &model.Workspace{}, &model.Membership{})
+
+	users := []model.User{{Username: "alice"}, {Username: "bob"}}
+	for i := range users {
+		testDB.Create(&users[i])
+	}
+
+	return NewServer(testDB), testDB, users
+}
+
+// callAs runs handler as the given user with optional path params given as
+// name, value pairs.
+func callAs(userID uint, workspace string, method, path string, body interface{}, handler echo.HandlerFunc, params ...string) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
+	jsonBody, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, path, bytes.NewBuffer(jsonBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if workspace != "" {
+		req.Header.Set(HeaderWorkspaceID, workspace)
+	}
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user", &jwt.Token{Claims: jwt.MapClaims{"id": float64(userID)}})
+	var names, values []string
+	for i := 0; i+1 < len(params); i += 2 {
+		names = append(names, params[i])
+		values = append(values, params[i+1])
+	}
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+
+	if err := handler(c); err != nil {
+		e.HTTPErrorHandler(err, c)
+	}
+	return rec
+}
+
+func TestWorkspaceMiddlewareCreatesPersonalWorkspace(t *testing.T) {
+	t.Parallel()
+	s, testDB, users := setupWorkspaces(t)
+
+	rec := callAs(users[0].ID, "", http.MethodPost, "/api/urls", map[string]string{"url": "https://example.com"}, s.WorkspaceMiddleware(s.AddURL))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	workspaceID := rec.Header().Get(HeaderWorkspaceID)
+	assert.NotEmpty(t, workspaceID)
+
+	var membership model.Membership
+	require.NoError(t, testDB.Preload("Workspace").Where("user_id = ?", users[0].ID).First(&membership).Error)
+	assert.Equal(t, model.RoleOwner, membership.Role)
+	assert.True(t, membership.Workspace.Personal)
+
+	// Bob cannot see or select Alice's workspace.
+	rec = callAs(users[1].ID, workspaceID, http.MethodGet, "/api/urls", nil, s.WorkspaceMiddleware(s.GetURLs))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = callAs(users[1].ID, "", http.MethodGet, "/api/urls", nil, s.WorkspaceMiddleware(s.GetURLs))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var urls []model.URL
+	json.Unmarshal(rec.Body.Bytes(), &urls)
+	assert.Empty(t, urls)
+}
+
+func TestDefaultMembershipCreatesOnePersonalWorkspace(t *testing.T) {
+	t.Parallel()
+	s, testDB, users := setupWorkspaces(t)
+
+	first, err := s.defaultMembership(context.Background(), users[0].ID)
+	require.NoError(t, err)
+	second, err := s.defaultMembership(context.Background(), users[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, first.WorkspaceID, second.WorkspaceID)
+
+	// A second personal workspace for the same user is refused by the
+	// database, which is what a concurrent first request would run into.
+	duplicate := model.Workspace{Name: "again", Personal: true, PersonalUserID: &users[0].ID}
+	assert.Error(t, testDB.Create(&duplicate).Error)
+
+	var count int64
+	testDB.Model(&model.Workspace{}).Where("personal = ?", true).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestWorkspaceRolesAndMembers(t *testing.T) {
+	t.Parallel()
+	s, _, users := setupWorkspaces(t)
+	alice, bob := users[0], users[1]
+
+	rec := callAs(alice.ID, "", http.MethodPost, "/api/workspaces", map[string]string{"name": "Client A"}, s.CreateWorkspace)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var created model.Membership
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	ws := strconv.FormatUint(uint64(created.WorkspaceID), 10)
+
+	rec = callAs(alice.ID, ws, http.MethodPost, "/api/urls", map[string]string{"url": "https://client-a.com"}, s.WorkspaceMiddleware(s.AddURL))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = callAs(alice.ID, "", http.MethodPost, "/api/workspaces/members", map[string]string{"username": "bob", "role": "viewer"}, s.AddWorkspaceMember, "id", ws)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	// Viewers read but cannot add or delete.
+	rec = callAs(bob.ID, ws, http.MethodGet, "/api/urls", nil, s.WorkspaceMiddleware(s.GetURLs))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var urls []model.URL
+	json.Unmarshal(rec.Body.Bytes(), &urls)
+	assert.Len(t, urls, 1)
+
+	rec = callAs(bob.ID, ws, http.MethodPost, "/api/urls", map[string]string{"url": "https://b.com"}, s.WorkspaceMiddleware(s.AddURL))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = callAs(bob.ID, ws, http.MethodDelete, "/api/urls", map[string][]uint{"ids": {urls[0].ID}}, s.WorkspaceMiddleware(s.DeleteURLs))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// Admins cannot appoint owners, and the last owner cannot be demoted.
+	rec = callAs(alice.ID, "", http.MethodPatch, "/api/workspaces/members", map[string]string{"role": "admin"}, s.UpdateWorkspaceMember, "id", ws, "user_id", strconv.Itoa(int(bob.ID)))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = callAs(bob.ID, "", http.MethodPatch, "/api/workspaces/members", map[string]string{"role": "owner"}, s.UpdateWorkspaceMember, "id", ws, "user_id", strconv.Itoa(int(bob.ID)))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = callAs(alice.ID, "", http.MethodPatch, "/api/workspaces/members", map[string]string{"role": "editor"}, s.UpdateWorkspaceMember, "id", ws, "user_id", strconv.Itoa(int(alice.ID)))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = callAs(bob.ID, ws, http.MethodDelete, "/api/urls", map[string][]uint{"ids": {urls[0].ID}}, s.WorkspaceMiddleware(s.DeleteURLs))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
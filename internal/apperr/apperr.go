@@ -0,0 +1,142 @@
+// Package apperr defines the body of every API error response. Handlers
+// return errors built here, or plain echo.HTTPErrors, and the API's error
+// handler renders both as an Error:
+//
+//	{"code": "not_found", "message": "URL not found", "request_id": "..."}
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Machine-readable error codes. Clients should branch on these rather than
+// on messages, which may change.
+const (
+	CodeBadRequest        = "bad_request"
+	CodeValidation        = "validation_failed"
+	CodeInvalidURL        = "invalid_url"
+	CodeUnauthorized      = "unauthorized"
+	CodeForbidden         = "forbidden"
+	CodeInsufficientRole  = "insufficient_role"
+	CodeTwoFactorRequired = "two_factor_required"
+	CodeTwoFactorLocked   = "two_factor_locked"
+	CodeNotFound          = "not_found"
+	CodeConflict          = "conflict"
+	CodeDuplicateURL      = "duplicate_url"
+	CodePayloadTooLarge   = "payload_too_large"
+	CodeInternal          = "internal_error"
+)
+
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Details carries code-specific data, such as the invalid fields of a
+	// validation_failed error.
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// New returns an HTTP error whose body is an Error. It is an
+// echo.HTTPError so it passes through Echo middleware like any other.
+func New(status int, code, message string) *echo.HTTPError {
+	return echo.NewHTTPError(status, &Error{Code: code, Message: message})
+}
+
+// WithDetails is New with code-specific details.
+func WithDetails(status int, code, message string, details interface{}) *echo.HTTPError {
+	return echo.NewHTTPError(status, &Error{Code: code, Message: message, Details: details})
+}
+
+func BadRequest(message string) *echo.HTTPError {
+	return New(http.StatusBadRequest, CodeBadRequest, message)
+}
+
+func Unauthorized(message string) *echo.HTTPError {
+	return New(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *echo.HTTPError {
+	return New(http.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(message string) *echo.HTTPError {
+	return New(http.StatusNotFound, CodeNotFound, message)
+}
+
+func Conflict(message string) *echo.HTTPError {
+	return New(http.StatusConflict, CodeConflict, message)
+}
+
+// Internal reports a server-side failure. The cause is logged by the error
+// handler but never sent to the client.
+func Internal(cause error, message string) *echo.HTTPError {
+	return New(http.StatusInternalServerError, CodeInternal, message).SetInternal(cause)
+}
+
+// From returns the status and body for an error returned by a handler.
+// HTTP errors without an Error body get a code derived from their status;
+// any other error is an internal error.
+func From(err error) (int, *Error) {
+	var he *echo.HTTPError
+	if !errors.As(err, &he) {
+		return http.StatusInternalServerError, &Error{Code: CodeInternal, Message: http.StatusText(http.StatusInternalServerError)}
+	}
+
+	body := &Error{Code: CodeForStatus(he.Code)}
+	switch m := he.Message.(type) {
+	case *Error:
+		copied := *m
+		body = &copied
+	case string:
+		body.Message = m
+	case echo.Map:
+		body.Message = fmt.Sprint(m["message"])
+		body.Details = m["details"]
+	case error:
+		body.Message = m.Error()
+	default:
+		body.Message = fmt.Sprint(m)
+	}
+	if body.Message == "" {
+		body.Message = http.StatusText(he.Code)
+	}
+	return he.Code, body
+}
+
+// CodeForStatus is the code used for HTTP errors that do not set one.
+func CodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return "method_not_allowed"
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusUnsupportedMediaType:
+		return "unsupported_media_type"
+	case http.StatusTooManyRequests:
+		return "rate_limited"
+	case http.StatusServiceUnavailable:
+		return "unavailable"
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
@@ -0,0 +1,34 @@
+package apperr
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrom(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		body   Error
+	}{
+		{"typed", NotFound("URL not found"), http.StatusNotFound, Error{Code: CodeNotFound, Message: "URL not found"}},
+		{"details", WithDetails(http.StatusConflict, CodeDuplicateURL, "URL already exists", 7), http.StatusConflict, Error{Code: CodeDuplicateURL, Message: "URL already exists", Details: 7}},
+		{"plain", echo.NewHTTPError(http.StatusForbidden, "No"), http.StatusForbidden, Error{Code: CodeForbidden, Message: "No"}},
+		{"echo", echo.ErrMethodNotAllowed, http.StatusMethodNotAllowed, Error{Code: "method_not_allowed", Message: "Method Not Allowed"}},
+		{"internal", Internal(errors.New("disk full"), "Failed to save URL"), http.StatusInternalServerError, Error{Code: CodeInternal, Message: "Failed to save URL"}},
+		{"untyped", errors.New("boom"), http.StatusInternalServerError, Error{Code: CodeInternal, Message: "Internal Server Error"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := From(tt.err)
+			assert.Equal(t, tt.status, status)
+			assert.Equal(t, tt.body, *body)
+		})
+	}
+}
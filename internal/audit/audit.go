@@ -0,0 +1,109 @@
+package audit
+
+import (
+	"strconv"
+	"time"
+
+	"url-crawler-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type Entry struct {
+	ActorID       uint
+	ActorUsername string
+	Action        string
+	Outcome       string
+	WorkspaceID   uint
+	TargetType    string
+	TargetIDs     []uint
+	Detail        string
+	IP            string
+}
+
+// Record appends an entry to the audit log.
+func Record(conn *gorm.DB, e Entry) error {
+	outcome := e.Outcome
+	if outcome == "" {
+		outcome = model.AuditSuccess
+	}
+
+	row := model.AuditLog{
+		ActorUsername: e.ActorUsername,
+		Action:        e.Action,
+		Outcome:       outcome,
+		TargetType:    e.TargetType,
+		TargetIDs:     model.IDList(e.TargetIDs),
+		Detail:        e.Detail,
+		IP:            e.IP,
+	}
+	if e.ActorID != 0 {
+		id := e.ActorID
+		row.ActorID = &id
+	}
+	if e.WorkspaceID != 0 {
+		id := e.WorkspaceID
+		row.WorkspaceID = &id
+	}
+
+	return conn.Create(&row).Error
+}
+
+type Filter struct {
+	ActorID     uint
+	Actor       string
+	Action      string
+	Outcome     string
+	WorkspaceID uint
+	TargetType  string
+	TargetID    uint
+	From        time.Time
+	To          time.Time
+	Limit       int
+	Offset      int
+}
+
+// Query returns matching entries, newest first, and the total match count.
+func Query(conn *gorm.DB, f Filter) ([]model.AuditLog, int64, error) {
+	q := conn.Model(&model.AuditLog{})
+	if f.ActorID != 0 {
+		q = q.Where("actor_id = ?", f.ActorID)
+	}
+	if f.Actor != "" {
+		q = q.Where("actor_username = ?", f.Actor)
+	}
+	if f.Action != "" {
+		q = q.Where("action = ?", f.Action)
+	}
+	if f.Outcome != "" {
+		q = q.Where("outcome = ?", f.Outcome)
+	}
+	if f.WorkspaceID != 0 {
+		q = q.Where("workspace_id = ?", f.WorkspaceID)
+	}
+	if f.TargetType != "" {
+		q = q.Where("target_type = ?", f.TargetType)
+	}
+	if f.TargetID != 0 {
+		q = q.Where("target_ids LIKE ?", "%,"+uintString(f.TargetID)+",%")
+	}
+	if !f.From.IsZero() {
+		q = q.Where("created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		q = q.Where("created_at < ?", f.To)
+	}
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var entries []model.AuditLog
+	err := q.Order("created_at DESC, id DESC").Limit(f.Limit).Offset(f.Offset).Find(&entries).Error
+	return entries, total, err
+}
+
+func uintString(v uint) string {
+	return strconv.FormatUint(uint64(v), 10)
+}
@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"url-crawler-backend/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupDB(t *testing.T) *gorm.DB {
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, conn.AutoMigrate(&model.AuditLog{}))
+	return conn
+}
+
+func TestRecordAndQuery(t *testing.T) {
+	conn := setupDB(t)
+
+	require.NoError(t, Record(conn, Entry{ActorID: 1, ActorUsername: "alice", Action: "url.create", WorkspaceID: 7, TargetType: "url", TargetIDs: []uint{12}}))
+	require.NoError(t, Record(conn, Entry{ActorID: 1, ActorUsername: "alice", Action: "url.delete", WorkspaceID: 7, TargetType: "url", TargetIDs: []uint{1, 12, 120}}))
+	require.NoError(t, Record(conn, Entry{ActorUsername: "mallory", Action: "auth.login", Outcome: model.AuditFailure, IP: "10.0.0.9"}))
+
+	entries, total, err := Query(conn, Filter{TargetID: 12, Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, "url.delete", entries[0].Action, "newest first")
+	assert.Equal(t, model.IDList{1, 12, 120}, entries[0].TargetIDs)
+
+	entries, total, err = Query(conn, Filter{Action: "auth.login", Outcome: model.AuditFailure, Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Nil(t, entries[0].ActorID)
+	assert.Equal(t, "10.0.0.9", entries[0].IP)
+
+	_, total, err = Query(conn, Filter{From: time.Now().Add(time.Hour), Limit: 10})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+}
+
+func TestAuditLogIsAppendOnly(t *testing.T) {
+	conn := setupDB(t)
+	require.NoError(t, Record(conn, Entry{Action: "url.create"}))
+
+	var entry model.AuditLog
+	require.NoError(t, conn.First(&entry).Error)
+
+	assert.ErrorIs(t, conn.Model(&entry).Update("action", "url.delete").Error, model.ErrAuditLogImmutable)
+	assert.ErrorIs(t, conn.Delete(&entry).Error, model.ErrAuditLogImmutable)
+
+	var count int64
+	conn.Model(&model.AuditLog{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
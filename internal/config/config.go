@@ -0,0 +1,621 @@
+// Package config loads the server configuration. Values come from, in
+// increasing order of precedence: built-in defaults, an optional YAML file,
+// a .env file and the process environment. Load validates the result so
+// that a misconfigured server fails at startup rather than on first use.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"url-crawler-backend/internal/idempotency"
+	"url-crawler-backend/internal/logging"
+	"url-crawler-backend/internal/ratelimit"
+	"url-crawler-backend/internal/sso"
+	"url-crawler-backend/internal/tracing"
+	"url-crawler-backend/internal/trash"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	// Addr is the address the HTTP server listens on.
+	Addr        string
+	CORSOrigins []string
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For header
+	// gives the client IP. Without any, the peer address is used.
+	TrustedProxies []*net.IPNet
+	// ShutdownTimeout bounds how long the server waits on SIGTERM for
+	// requests and crawls to finish before cutting the crawls short.
+	ShutdownTimeout time.Duration
+
+	Database Database
+	JWT      JWT
+
+	TOTPIssuer string
+	// TrashRetention is how long URLs stay in the trash; zero keeps them
+	// forever.
+	TrashRetention time.Duration
+	IdempotencyTTL time.Duration
+	RateLimits     ratelimit.Config
+	// CrawlConcurrency is the number of crawls each user may run at once;
+	// zero means no cap.
+	CrawlConcurrency int
+
+	// OIDC configures single sign-on; it is disabled without an issuer.
+	OIDC sso.Config
+	// OIDCSuccessRedirect, when set, receives the token after an OIDC login
+	// in the URL fragment instead of a JSON response.
+	OIDCSuccessRedirect string
+
+	Log     logging.Config
+	Tracing tracing.Config
+}
+
+// Database drivers.
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+type Database struct {
+	// Driver is mysql, postgres or sqlite.
+	Driver string
+	// DSN is passed to the driver as is and takes the place of the
+	// connection fields below. For sqlite it is the database file.
+	DSN string
+
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Name     string
+
+	// MigrateOnStart applies pending migrations when the server starts.
+	// Turn it off to run cmd/migrate as a separate deployment step.
+	MigrateOnStart bool
+}
+
+type JWT struct {
+	// Secret signs HS256 tokens when no KeysFile is given. Alongside a
+	// KeysFile it only verifies tokens issued before the switch.
+	Secret string
+	// KeysFile names a manifest of asymmetric signing keys.
+	KeysFile string
+}
+
+// Default returns the configuration used for anything not set elsewhere.
+func Default() Config {
+	return Config{
+		Addr:            ":8080",
+		ShutdownTimeout: 25 * time.Second,
+		CORSOrigins: []string{
+			"http://localhost:3000",
+			"http://localhost:5173",
+			"http://127.0.0.1:3000",
+			"http://127.0.0.1:5173",
+		},
+		Database:         Database{Driver: DriverMySQL, Host: "localhost", MigrateOnStart: true},
+		TOTPIssuer:       "URL Crawler",
+		TrashRetention:   trash.DefaultRetentionDays * 24 * time.Hour,
+		IdempotencyTTL:   idempotency.DefaultTTL,
+		CrawlConcurrency: 5,
+		RateLimits: ratelimit.Config{
+			Default: ratelimit.Limit{Requests: 300, Period: time.Minute},
+			Routes: map[string]ratelimit.Limit{
+				"POST /login":              {Requests: 10, Period: time.Minute},
+				"POST /login/2fa":          {Requests: 10, Period: time.Minute},
+				"POST /api/urls/crawl":     {Requests: 10, Period: time.Minute},
+				"POST /api/urls/:id/start": {Requests: 60, Period: time.Minute},
+				"POST /api/urls/import":    {Requests: 10, Period: time.Minute},
+			},
+		},
+		OIDC:    sso.Config{Scopes: []string{oidc.ScopeOpenID, "profile", "email"}},
+		Log:     logging.Config{Level: "info", Format: logging.FormatText},
+		Tracing: tracing.Config{Exporter: tracing.ExporterNone, SampleRatio: 1},
+	}
+}
+
+// Option changes what Load requires of the configuration.
+type Option func(*options)
+
+type options struct {
+	withoutJWT bool
+}
+
+// WithoutJWT lets Load accept a configuration without JWT settings, for
+// commands such as cmd/migrate and cmd/seed that never sign or verify
+// tokens.
+func WithoutJWT() Option {
+	return func(o *options) { o.withoutJWT = true }
+}
+
+// Load builds the configuration. The YAML file is read from path, or from
+// CONFIG_FILE when path is empty; without either only defaults and the
+// environment are used. A missing .env file is not an error.
+func Load(path string, opts ...Option) (*Config, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read .env: %w", err)
+	}
+
+	cfg := Default()
+
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+	cfg.Database.applyDefaults()
+	if err := cfg.validate(o); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// file mirrors the YAML layout. Durations and limits are strings so they
+// can be written as "24h" and "10/m".
+type file struct {
+	Server struct {
+		Addr            string   `yaml:"addr"`
+		CORSOrigins     []string `yaml:"cors_origins"`
+		TrustedProxies  []string `yaml:"trusted_proxies"`
+		ShutdownTimeout string   `yaml:"shutdown_timeout"`
+	} `yaml:"server"`
+	Database struct {
+		Driver   string `yaml:"driver"`
+		DSN      string `yaml:"dsn"`
+		Host     string `yaml:"host"`
+		Port     int    `yaml:"port"`
+		User     string `yaml:"user"`
+		Password string `yaml:"password"`
+		Name     string `yaml:"name"`
+
+		MigrateOnStart *bool `yaml:"migrate_on_start"`
+	} `yaml:"database"`
+	JWT struct {
+		Secret   string `yaml:"secret"`
+		KeysFile string `yaml:"keys_file"`
+	} `yaml:"jwt"`
+	TOTP struct {
+		Issuer string `yaml:"issuer"`
+	} `yaml:"totp"`
+	Trash struct {
+		RetentionDays *int `yaml:"retention_days"`
+	} `yaml:"trash"`
+	Idempotency struct {
+		TTL string `yaml:"ttl"`
+	} `yaml:"idempotency"`
+	RateLimit struct {
+		Default string            `yaml:"default"`
+		Routes  map[string]string `yaml:"routes"`
+	} `yaml:"rate_limit"`
+	Crawl struct {
+		ConcurrencyPerUser *int `yaml:"concurrency_per_user"`
+	} `yaml:"crawl"`
+	OIDC struct {
+		IssuerURL       string   `yaml:"issuer_url"`
+		ClientID        string   `yaml:"client_id"`
+		ClientSecret    string   `yaml:"client_secret"`
+		RedirectURL     string   `yaml:"redirect_url"`
+		Scopes          []string `yaml:"scopes"`
+		SuccessRedirect string   `yaml:"success_redirect"`
+	} `yaml:"oidc"`
+	Log struct {
+		Level  string `yaml:"level"`
+		Format string `yaml:"format"`
+	} `yaml:"log"`
+	Tracing struct {
+		Exporter    string   `yaml:"exporter"`
+		Endpoint    string   `yaml:"endpoint"`
+		SampleRatio *float64 `yaml:"sample_ratio"`
+	} `yaml:"tracing"`
+}
+
+func (c *Config) loadFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	var f file
+	decoder := yaml.NewDecoder(bytes.NewReader(raw))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	setString(&c.Addr, f.Server.Addr)
+	if len(f.Server.CORSOrigins) > 0 {
+		c.CORSOrigins = f.Server.CORSOrigins
+	}
+	if len(f.Server.TrustedProxies) > 0 {
+		if c.TrustedProxies, err = parseNetworks(f.Server.TrustedProxies); err != nil {
+			return fmt.Errorf("server.trusted_proxies: %w", err)
+		}
+	}
+	if f.Server.ShutdownTimeout != "" {
+		if c.ShutdownTimeout, err = time.ParseDuration(f.Server.ShutdownTimeout); err != nil {
+			return fmt.Errorf("server.shutdown_timeout: %w", err)
+		}
+	}
+
+	setString(&c.Database.Driver, f.Database.Driver)
+	setString(&c.Database.DSN, f.Database.DSN)
+	setString(&c.Database.Host, f.Database.Host)
+	if f.Database.Port != 0 {
+		c.Database.Port = f.Database.Port
+	}
+	setString(&c.Database.User, f.Database.User)
+	setString(&c.Database.Password, f.Database.Password)
+	setString(&c.Database.Name, f.Database.Name)
+	if f.Database.MigrateOnStart != nil {
+		c.Database.MigrateOnStart = *f.Database.MigrateOnStart
+	}
+
+	setString(&c.JWT.Secret, f.JWT.Secret)
+	setString(&c.JWT.KeysFile, f.JWT.KeysFile)
+	setString(&c.TOTPIssuer, f.TOTP.Issuer)
+
+	if f.Trash.RetentionDays != nil {
+		if *f.Trash.RetentionDays < 0 {
+			return errors.New("trash.retention_days must not be negative")
+		}
+		c.TrashRetention = time.Duration(*f.Trash.RetentionDays) * 24 * time.Hour
+	}
+	if f.Idempotency.TTL != "" {
+		if c.IdempotencyTTL, err = time.ParseDuration(f.Idempotency.TTL); err != nil {
+			return fmt.Errorf("idempotency.ttl: %w", err)
+		}
+	}
+
+	if f.RateLimit.Default != "" {
+		if c.RateLimits.Default, err = ratelimit.ParseLimit(f.RateLimit.Default); err != nil {
+			return fmt.Errorf("rate_limit.default: %w", err)
+		}
+	}
+	for route, raw := range f.RateLimit.Routes {
+		l, err := ratelimit.ParseLimit(raw)
+		if err != nil {
+			return fmt.Errorf("rate_limit.routes: %w", err)
+		}
+		c.RateLimits.Routes[ratelimit.NormalizeRoute(route)] = l
+	}
+	if f.Crawl.ConcurrencyPerUser != nil {
+		c.CrawlConcurrency = *f.Crawl.ConcurrencyPerUser
+	}
+
+	setString(&c.OIDC.IssuerURL, f.OIDC.IssuerURL)
+	setString(&c.OIDC.ClientID, f.OIDC.ClientID)
+	setString(&c.OIDC.ClientSecret, f.OIDC.ClientSecret)
+	setString(&c.OIDC.RedirectURL, f.OIDC.RedirectURL)
+	if len(f.OIDC.Scopes) > 0 {
+		c.OIDC.Scopes = f.OIDC.Scopes
+	}
+	setString(&c.OIDCSuccessRedirect, f.OIDC.SuccessRedirect)
+
+	setString(&c.Log.Level, f.Log.Level)
+	setString(&c.Log.Format, f.Log.Format)
+
+	setString(&c.Tracing.Exporter, f.Tracing.Exporter)
+	setString(&c.Tracing.Endpoint, f.Tracing.Endpoint)
+	if f.Tracing.SampleRatio != nil {
+		c.Tracing.SampleRatio = *f.Tracing.SampleRatio
+	}
+	return nil
+}
+
+func (c *Config) loadEnv() error {
+	envString(&c.Addr, "ADDR")
+	if raw, ok := os.LookupEnv("CORS_ORIGINS"); ok && raw != "" {
+		c.CORSOrigins = splitList(raw)
+	}
+	if raw := os.Getenv("TRUSTED_PROXIES"); raw != "" {
+		networks, err := parseNetworks(splitList(raw))
+		if err != nil {
+			return fmt.Errorf("TRUSTED_PROXIES: %w", err)
+		}
+		c.TrustedProxies = networks
+	}
+	if err := envDuration(&c.ShutdownTimeout, "SHUTDOWN_TIMEOUT_SECONDS", time.Second); err != nil {
+		return err
+	}
+
+	envString(&c.Database.Driver, "DB_DRIVER")
+	envString(&c.Database.DSN, "DB_DSN")
+	envString(&c.Database.Host, "DB_HOST")
+	envString(&c.Database.User, "DB_USER")
+	envString(&c.Database.Password, "DB_PASS")
+	envString(&c.Database.Name, "DB_NAME")
+	if err := envInt(&c.Database.Port, "DB_PORT"); err != nil {
+		return err
+	}
+	if err := envBool(&c.Database.MigrateOnStart, "DB_MIGRATE_ON_START"); err != nil {
+		return err
+	}
+
+	envString(&c.JWT.Secret, "JWT_SECRET")
+	envString(&c.JWT.KeysFile, "JWT_KEYS_FILE")
+	envString(&c.TOTPIssuer, "TOTP_ISSUER")
+
+	if err := envDuration(&c.TrashRetention, "TRASH_RETENTION_DAYS", 24*time.Hour); err != nil {
+		return err
+	}
+	if err := envDuration(&c.IdempotencyTTL, "IDEMPOTENCY_TTL_HOURS", time.Hour); err != nil {
+		return err
+	}
+
+	if raw := os.Getenv("RATE_LIMIT_DEFAULT"); raw != "" {
+		l, err := ratelimit.ParseLimit(raw)
+		if err != nil {
+			return fmt.Errorf("RATE_LIMIT_DEFAULT: %w", err)
+		}
+		c.RateLimits.Default = l
+	}
+	if raw := os.Getenv("RATE_LIMIT_ROUTES"); raw != "" {
+		routes, err := ratelimit.ParseRoutes(raw)
+		if err != nil {
+			return fmt.Errorf("RATE_LIMIT_ROUTES: %w", err)
+		}
+		for route, l := range routes {
+			c.RateLimits.Routes[route] = l
+		}
+	}
+	if err := envInt(&c.CrawlConcurrency, "CRAWL_CONCURRENCY_PER_USER"); err != nil {
+		return err
+	}
+
+	envString(&c.OIDC.IssuerURL, "OIDC_ISSUER_URL")
+	envString(&c.OIDC.ClientID, "OIDC_CLIENT_ID")
+	envString(&c.OIDC.ClientSecret, "OIDC_CLIENT_SECRET")
+	envString(&c.OIDC.RedirectURL, "OIDC_REDIRECT_URL")
+	if raw := os.Getenv("OIDC_SCOPES"); raw != "" {
+		c.OIDC.Scopes = splitList(raw)
+	}
+	envString(&c.OIDCSuccessRedirect, "OIDC_SUCCESS_REDIRECT")
+
+	envString(&c.Log.Level, "LOG_LEVEL")
+	envString(&c.Log.Format, "LOG_FORMAT")
+
+	envString(&c.Tracing.Exporter, "TRACING_EXPORTER")
+	envString(&c.Tracing.Endpoint, "OTEL_EXPORTER_OTLP_ENDPOINT")
+	if err := envFloat(&c.Tracing.SampleRatio, "TRACING_SAMPLE_RATIO"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Validate reports every problem with the configuration at once.
+func (c *Config) Validate() error {
+	return c.validate(options{})
+}
+
+func (c *Config) validate(o options) error {
+	var errs []error
+	if c.Addr == "" {
+		errs = append(errs, errors.New("ADDR must not be empty"))
+	}
+	for _, origin := range c.CORSOrigins {
+		if err := validateOrigin(origin); err != nil {
+			errs = append(errs, fmt.Errorf("CORS_ORIGINS: %w", err))
+		}
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("SHUTDOWN_TIMEOUT_SECONDS must be positive"))
+	}
+
+	errs = append(errs, c.Database.validate()...)
+
+	if !o.withoutJWT && strings.TrimSpace(c.JWT.Secret) == "" && c.JWT.KeysFile == "" {
+		errs = append(errs, errors.New("JWT_SECRET must be set (or JWT_KEYS_FILE for asymmetric keys)"))
+	}
+
+	if c.TrashRetention < 0 {
+		errs = append(errs, errors.New("TRASH_RETENTION_DAYS must not be negative"))
+	}
+	if c.IdempotencyTTL <= 0 {
+		errs = append(errs, errors.New("IDEMPOTENCY_TTL_HOURS must be positive"))
+	}
+	if c.CrawlConcurrency < 0 {
+		errs = append(errs, errors.New("CRAWL_CONCURRENCY_PER_USER must not be negative"))
+	}
+
+	if c.OIDC.Enabled() {
+		if c.OIDC.ClientID == "" || c.OIDC.RedirectURL == "" {
+			errs = append(errs, errors.New("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required with OIDC_ISSUER_URL"))
+		}
+	}
+
+	if err := c.Log.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Tracing.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// applyDefaults fills in the port, or the file for sqlite, that the
+// driver's users would expect.
+func (d *Database) applyDefaults() {
+	d.Driver = strings.ToLower(d.Driver)
+	switch d.Driver {
+	case DriverMySQL:
+		if d.Port == 0 {
+			d.Port = 3306
+		}
+	case DriverPostgres:
+		if d.Port == 0 {
+			d.Port = 5432
+		}
+	case DriverSQLite:
+		if d.DSN == "" {
+			d.DSN = "url-crawler.db"
+		}
+	}
+}
+
+func (d Database) validate() []error {
+	switch d.Driver {
+	case DriverMySQL, DriverPostgres:
+	case DriverSQLite:
+		return nil
+	default:
+		return []error{fmt.Errorf("DB_DRIVER must be mysql, postgres or sqlite, got %q", d.Driver)}
+	}
+	if d.DSN != "" {
+		return nil
+	}
+
+	var errs []error
+	if d.Host == "" {
+		errs = append(errs, errors.New("DB_HOST must be set"))
+	}
+	if d.Name == "" {
+		errs = append(errs, errors.New("DB_NAME must be set"))
+	}
+	if d.Port <= 0 || d.Port > 65535 {
+		errs = append(errs, fmt.Errorf("DB_PORT must be between 1 and 65535, got %d", d.Port))
+	}
+	return errs
+}
+
+// validateOrigin accepts scheme://host[:port], as browsers send in the
+// Origin header. Hosts made only of digits and dots must be IPv4
+// addresses, which catches typos such as "12700.13000".
+func validateOrigin(origin string) error {
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an origin like https://example.com", origin)
+	}
+	if u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return fmt.Errorf("%q must not have a path, query or credentials", origin)
+	}
+
+	host := u.Hostname()
+	if strings.Trim(host, "0123456789.") == "" && net.ParseIP(host) == nil {
+		return fmt.Errorf("%q has an invalid IP address", origin)
+	}
+	if port := u.Port(); port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+			return fmt.Errorf("%q has an invalid port", origin)
+		}
+	}
+	return nil
+}
+
+// parseNetworks reads CIDR ranges; a bare IP address stands for itself.
+func parseNetworks(raw []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(raw))
+	for _, s := range raw {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an IP address or CIDR range", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR range", s)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func setString(dst *string, value string) {
+	if value != "" {
+		*dst = value
+	}
+}
+
+func envString(dst *string, name string) {
+	if value := os.Getenv(name); value != "" {
+		*dst = value
+	}
+}
+
+func envInt(dst *int, name string) error {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return fmt.Errorf("%s must be an integer, got %q", name, raw)
+	}
+	*dst = n
+	return nil
+}
+
+func envFloat(dst *float64, name string) error {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return nil
+	}
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return fmt.Errorf("%s must be a number, got %q", name, raw)
+	}
+	*dst = f
+	return nil
+}
+
+func envBool(dst *bool, name string) error {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		return fmt.Errorf("%s must be true or false, got %q", name, raw)
+	}
+	*dst = b
+	return nil
+}
+
+// envDuration reads a whole number of units, such as days.
+func envDuration(dst *time.Duration, name string, unit time.Duration) error {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return fmt.Errorf("%s must be a non-negative integer, got %q", name, raw)
+	}
+	*dst = time.Duration(n) * unit
+	return nil
+}
+
+func splitList(raw string) []string {
+	return strings.Fields(strings.ReplaceAll(raw, ",", " "))
+}
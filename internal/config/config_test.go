@@ -0,0 +1,192 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"url-crawler-backend/internal/logging"
+	"url-crawler-backend/internal/ratelimit"
+	"url-crawler-backend/internal/tracing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clearEnv unsets every variable Load reads for the duration of the test.
+func clearEnv(t *testing.T) {
+	for _, name := range []string{
+		"CONFIG_FILE", "ADDR", "CORS_ORIGINS", "TRUSTED_PROXIES", "SHUTDOWN_TIMEOUT_SECONDS", "DB_DRIVER", "DB_DSN", "DB_HOST", "DB_PORT", "DB_USER", "DB_PASS", "DB_NAME", "DB_MIGRATE_ON_START",
+		"JWT_SECRET", "JWT_KEYS_FILE", "TOTP_ISSUER", "TRASH_RETENTION_DAYS", "IDEMPOTENCY_TTL_HOURS",
+		"RATE_LIMIT_DEFAULT", "RATE_LIMIT_ROUTES", "CRAWL_CONCURRENCY_PER_USER",
+		"OIDC_ISSUER_URL", "OIDC_CLIENT_ID", "OIDC_CLIENT_SECRET", "OIDC_REDIRECT_URL", "OIDC_SCOPES", "OIDC_SUCCESS_REDIRECT",
+		"LOG_LEVEL", "LOG_FORMAT", "TRACING_EXPORTER", "OTEL_EXPORTER_OTLP_ENDPOINT", "TRACING_SAMPLE_RATIO",
+	} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+}
+
+func TestLoadFromEnv(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("JWT_SECRET", "secret")
+	t.Setenv("DB_NAME", "crawler")
+	t.Setenv("DB_PORT", "3307")
+	t.Setenv("TRASH_RETENTION_DAYS", "7")
+	t.Setenv("RATE_LIMIT_ROUTES", "POST /api/urls/crawl=5/m")
+	t.Setenv("CORS_ORIGINS", "https://crawler.example.com, http://127.0.0.1:5173")
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.0.2.1")
+
+	cfg, err := Load("")
+	require.NoError(t, err)
+	assert.Equal(t, ":8080", cfg.Addr)
+	assert.Equal(t, 25*time.Second, cfg.ShutdownTimeout)
+	assert.Equal(t, Database{Driver: DriverMySQL, Host: "localhost", Port: 3307, Name: "crawler", MigrateOnStart: true}, cfg.Database)
+	assert.Equal(t, 7*24*time.Hour, cfg.TrashRetention)
+	assert.Equal(t, 24*time.Hour, cfg.IdempotencyTTL)
+	assert.Equal(t, ratelimit.Limit{Requests: 5, Period: time.Minute}, cfg.RateLimits.Routes["POST /api/urls/crawl"])
+	assert.Equal(t, 10, cfg.RateLimits.Routes["POST /login"].Requests)
+	assert.Equal(t, []string{"https://crawler.example.com", "http://127.0.0.1:5173"}, cfg.CORSOrigins)
+	require.Len(t, cfg.TrustedProxies, 2)
+	assert.Equal(t, "10.0.0.0/8", cfg.TrustedProxies[0].String())
+	assert.Equal(t, "192.0.2.1/32", cfg.TrustedProxies[1].String())
+	assert.Equal(t, "URL Crawler", cfg.TOTPIssuer)
+	assert.Equal(t, logging.Config{Level: "debug", Format: logging.FormatText}, cfg.Log)
+	assert.Equal(t, tracing.Config{Exporter: tracing.ExporterNone, SampleRatio: 1}, cfg.Tracing)
+}
+
+func TestLoadFile(t *testing.T) {
+	clearEnv(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+server:
+  addr: ":9000"
+  shutdown_timeout: 1m
+database:
+  host: db
+  name: crawler
+jwt:
+  secret: from-file
+trash:
+  retention_days: 0
+idempotency:
+  ttl: 2h
+rate_limit:
+  default: 100/m
+  routes:
+    "GET /api/stats": "0"
+crawl:
+  concurrency_per_user: 2
+log:
+  format: json
+tracing:
+  exporter: otlp
+  endpoint: http://collector:4318
+  sample_ratio: 0.25
+`), 0o600))
+
+	// The environment wins over the file.
+	t.Setenv("DB_HOST", "db.internal")
+
+	cfg, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, ":9000", cfg.Addr)
+	assert.Equal(t, time.Minute, cfg.ShutdownTimeout)
+	assert.Equal(t, "db.internal", cfg.Database.Host)
+	assert.Equal(t, "from-file", cfg.JWT.Secret)
+	assert.Zero(t, cfg.TrashRetention)
+	assert.Equal(t, 2*time.Hour, cfg.IdempotencyTTL)
+	assert.Equal(t, 100, cfg.RateLimits.Default.Requests)
+	assert.True(t, cfg.RateLimits.Routes["GET /api/stats"].Unlimited())
+	assert.Equal(t, 2, cfg.CrawlConcurrency)
+	assert.Equal(t, logging.Config{Level: "info", Format: logging.FormatJSON}, cfg.Log)
+	assert.Equal(t, tracing.Config{Exporter: tracing.ExporterOTLP, Endpoint: "http://collector:4318", SampleRatio: 0.25}, cfg.Tracing)
+
+	require.NoError(t, os.WriteFile(path, []byte("server:\n  adr: \":9000\"\n"), 0o600))
+	_, err = Load(path)
+	assert.ErrorContains(t, err, "adr")
+}
+
+func TestLoadFailsFast(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DB_NAME", "crawler")
+
+	_, err := Load("")
+	assert.ErrorContains(t, err, "JWT_SECRET")
+
+	// Commands that do not handle tokens run without JWT settings.
+	_, err = Load("", WithoutJWT())
+	assert.NoError(t, err)
+
+	t.Setenv("JWT_SECRET", "secret")
+	for name, value := range map[string]string{
+		"SHUTDOWN_TIMEOUT_SECONDS":   "0",
+		"TRUSTED_PROXIES":            "10.0.0.0/33",
+		"TRASH_RETENTION_DAYS":       "-1",
+		"IDEMPOTENCY_TTL_HOURS":      "soon",
+		"CRAWL_CONCURRENCY_PER_USER": "many",
+		"RATE_LIMIT_DEFAULT":         "60",
+		"DB_PORT":                    "70000",
+		"DB_MIGRATE_ON_START":        "sometimes",
+		"LOG_LEVEL":                  "verbose",
+		"LOG_FORMAT":                 "xml",
+		"TRACING_EXPORTER":           "jaeger",
+		"TRACING_SAMPLE_RATIO":       "2",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			_, err := Load("")
+			assert.ErrorContains(t, err, name)
+		})
+	}
+}
+
+func TestLoadDatabaseDrivers(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("JWT_SECRET", "secret")
+
+	t.Setenv("DB_DRIVER", "sqlite")
+	cfg, err := Load("")
+	require.NoError(t, err)
+	assert.Equal(t, "url-crawler.db", cfg.Database.DSN)
+
+	t.Setenv("DB_DRIVER", "Postgres")
+	t.Setenv("DB_NAME", "crawler")
+	cfg, err = Load("")
+	require.NoError(t, err)
+	assert.Equal(t, DriverPostgres, cfg.Database.Driver)
+	assert.Equal(t, 5432, cfg.Database.Port)
+
+	t.Setenv("DB_NAME", "")
+	_, err = Load("")
+	assert.ErrorContains(t, err, "DB_NAME")
+	t.Setenv("DB_DSN", "postgres://crawler@db/crawler")
+	_, err = Load("")
+	assert.NoError(t, err)
+
+	t.Setenv("DB_DRIVER", "oracle")
+	_, err = Load("")
+	assert.ErrorContains(t, err, "DB_DRIVER")
+}
+
+func TestValidateOrigins(t *testing.T) {
+	for _, origin := range []string{
+		"http://localhost:3000",
+		"https://crawler.example.com",
+		"http://127.0.0.1:5173",
+		"http://[::1]:3000",
+	} {
+		assert.NoError(t, validateOrigin(origin), origin)
+	}
+	for _, origin := range []string{
+		"http://12700.13000",
+		"http://1270.1",
+		"localhost:3000",
+		"http://localhost:3000/",
+		"http://localhost:99999",
+	} {
+		assert.Error(t, validateOrigin(origin), origin)
+	}
+}
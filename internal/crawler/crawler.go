@@ -1,36 +1,168 @@
 package crawler
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"url-crawler-backend/internal/logging"
+	"url-crawler-backend/internal/metrics"
 	"url-crawler-backend/internal/model"
+
+	"github.com/PuerkitoBio/goquery"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func CrawlURL(u *model.URL) error {
-	bodyStr, err := fetchHTML(u.URL)
+// tracerName is the instrumentation scope of the crawl spans.
+const tracerName = "url-crawler-backend/internal/crawler"
+
+// client sends the page and link check requests. Each request gets a span
+// and carries the trace context to the server.
+var client = &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
+
+// Options change how a page is crawled. The zero value crawls it fully.
+type Options struct {
+	// SkipLinkCheck leaves the links unchecked, so none is counted as
+	// broken.
+	SkipLinkCheck bool
+	// Metrics, when set, records the crawl, its fetch and its link checks.
+	Metrics *metrics.Registry
+}
+
+// CrawlURL fetches and analyses the page, filling in u. On failure u is
+// marked "error" with the failure class and reason, and a *CrawlError is
+// returned. Each phase of the crawl gets its own span under ctx and is
+// logged with its duration.
+func CrawlURL(ctx context.Context, u *model.URL) error {
+	return Crawl(ctx, u, Options{})
+}
+
+// Crawl is CrawlURL with options.
+func Crawl(ctx context.Context, u *model.URL, opts Options) error {
+	start := time.Now()
+	ctx = logging.With(ctx, "url", u.URL)
+	var p phases
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "crawler.CrawlURL", trace.WithAttributes(
+		attribute.Int64("url.id", int64(u.ID)),
+		attribute.String("url.full", u.URL),
+	))
+	defer func() {
+		outcome := u.Status
+		if outcome != "done" {
+			outcome = u.ErrorClass
+			span.SetStatus(codes.Error, u.ErrorMessage)
+		}
+		span.SetAttributes(attribute.String("crawl.outcome", outcome))
+		span.End()
+		elapsed := time.Since(start)
+		if opts.Metrics != nil {
+			opts.Metrics.CrawlDuration.WithLabelValues(outcome).Observe(elapsed.Seconds())
+		}
+
+		args := []any{"status", u.Status, "duration", elapsed, slog.Group("phases", p.timings...)}
+		switch {
+		case u.Status == "done":
+			slog.InfoContext(ctx, "Crawl finished", append(args, "links", len(u.Links), "broken_links", u.BrokenLinks)...)
+		case u.ErrorClass == ErrorCanceled:
+			slog.InfoContext(ctx, "Crawl canceled", args...)
+		default:
+			slog.WarnContext(ctx, "Crawl failed", append(args, "error_class", u.ErrorClass, "error", u.ErrorMessage)...)
+		}
+	}()
+
+	u.ErrorClass = ""
+	u.ErrorMessage = ""
+
+	var bodyStr string
+	err := p.run(ctx, "fetch", func(ctx context.Context) (err error) {
+		bodyStr, err = fetchHTML(ctx, u.URL, opts.Metrics)
+		return err
+	})
 	if err != nil {
-		u.Status = "error"
-		return fmt.Errorf("fetch error: %w", err)
+		return fail(u, err)
 	}
 
-	u.HTMLVersion = detectHTMLVersion(bodyStr)
-
-	doc, err := parseDocument(bodyStr)
+	var doc *goquery.Document
+	err = p.run(ctx, "parse", func(context.Context) (err error) {
+		u.HTMLVersion = detectHTMLVersion(bodyStr)
+		doc, err = parseDocument(bodyStr)
+		return err
+	})
 	if err != nil {
-		u.Status = "error"
-		return fmt.Errorf("parse error: %w", err)
+		return fail(u, &CrawlError{Class: ErrorParse, Err: err})
 	}
 
-	u.PageTitle = extractTitle(doc)
-	u.Headings = extractHeadingSummary(doc)
+	p.run(ctx, "extract", func(context.Context) error {
+		u.PageTitle = extractTitle(doc)
+		u.Headings = extractHeadingSummary(doc)
+		u.Links = collectLinks(doc, u.URL)
+		u.HasLoginForm = detectLoginForm(doc)
+		return nil
+	})
 
-	baseURL := u.URL
-	internal, external, broken := analyzeLinks(doc, baseURL)
+	if !opts.SkipLinkCheck {
+		err = p.run(ctx, "check links", func(ctx context.Context) error {
+			trace.SpanFromContext(ctx).SetAttributes(attribute.Int("links.count", len(u.Links)))
+			return checkLinks(ctx, u.Links, opts.Metrics)
+		})
+		if err != nil {
+			// Links left unchecked would count as neither working nor
+			// broken, so the crawl fails as a whole.
+			return fail(u, &CrawlError{Class: classifyFetchError(err), Err: fmt.Errorf("check links: %w", err)})
+		}
+	}
+	internal, external, broken := countLinks(u.Links)
 	u.InternalLinks = internal
 	u.ExternalLinks = external
 	u.BrokenLinks = broken
 
-	u.HasLoginForm = detectLoginForm(doc)
-
 	u.Status = "done"
 	return nil
 }
+
+// phases runs the steps of a crawl and keeps their durations for the
+// summary logged at the end.
+type phases struct {
+	timings []any
+}
+
+// run runs one step of a crawl in its own span.
+func (p *phases) run(ctx context.Context, name string, run func(ctx context.Context) error) error {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, name)
+	defer span.End()
+	start := time.Now()
+	err := run(ctx)
+	elapsed := time.Since(start)
+	p.timings = append(p.timings, slog.Duration(strings.ReplaceAll(name, " ", "_"), elapsed))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		slog.DebugContext(ctx, "Crawl phase failed", "phase", name, "duration", elapsed, "error", err)
+		return err
+	}
+	slog.DebugContext(ctx, "Crawl phase finished", "phase", name, "duration", elapsed)
+	return nil
+}
+
+func fail(u *model.URL, err error) error {
+	var crawlErr *CrawlError
+	if !errors.As(err, &crawlErr) {
+		crawlErr = &CrawlError{Class: ErrorUnknown, Err: err}
+	}
+
+	u.Status = "error"
+	u.ErrorClass = crawlErr.Class
+	u.ErrorMessage = crawlErr.Err.Error()
+	if len(u.ErrorMessage) > maxErrorMessage {
+		u.ErrorMessage = u.ErrorMessage[:maxErrorMessage]
+	}
+	return crawlErr
+}
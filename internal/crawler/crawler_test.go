@@ -1,9 +1,26 @@
 package crawler
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
+	"url-crawler-backend/internal/metrics"
+	"url-crawler-backend/internal/model"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 func TestDetectHTMLVersion(t *testing.T) {
@@ -181,3 +198,151 @@ func TestAnalyzeLinks(t *testing.T) {
 	assert.GreaterOrEqual(t, broken, 0)
 	assert.LessOrEqual(t, broken, 1)
 }
+
+func TestCrawlURLRecordsFailure(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	closed := server.URL
+	server.Close()
+
+	tests := []struct {
+		url   string
+		class string
+	}{
+		{"ftp://example.com/file", ErrorInvalidURL},
+		{closed, ErrorConnection},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			u := &model.URL{URL: tt.url}
+			err := CrawlURL(context.Background(), u)
+
+			var crawlErr *CrawlError
+			require.ErrorAs(t, err, &crawlErr)
+			assert.Equal(t, tt.class, crawlErr.Class)
+			assert.Equal(t, "error", u.Status)
+			assert.Equal(t, tt.class, u.ErrorClass)
+			assert.NotEmpty(t, u.ErrorMessage)
+		})
+	}
+}
+
+func TestCrawlURLRecordsMetrics(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`<!doctype html><title>Home</title><a href="/about">About</a><a href="/gone">Gone</a>`))
+	})
+	mux.HandleFunc("/about", func(w http.ResponseWriter, r *http.Request) {})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	m := metrics.New()
+	require.NoError(t, Crawl(context.Background(), &model.URL{URL: server.URL}, Options{Metrics: m}))
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.LinkChecks.WithLabelValues("2xx")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.LinkChecks.WithLabelValues("4xx")))
+	assert.Equal(t, uint64(1), sampleCount(t, m.CrawlDuration.WithLabelValues("done")))
+	assert.Equal(t, uint64(1), sampleCount(t, m.FetchBytes))
+}
+
+func TestCrawlSkipLinkCheck(t *testing.T) {
+	var heads int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			heads++
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`<!doctype html><title>Home</title><a href="/gone">Gone</a><a href="https://example.com">Out</a>`))
+	}))
+	defer server.Close()
+
+	u := model.URL{URL: server.URL}
+	require.NoError(t, Crawl(context.Background(), &u, Options{SkipLinkCheck: true}))
+	assert.Equal(t, "done", u.Status)
+	assert.Equal(t, "Home", u.PageTitle)
+	assert.Equal(t, 1, u.InternalLinks)
+	assert.Equal(t, 1, u.ExternalLinks)
+	assert.Zero(t, u.BrokenLinks)
+	assert.Zero(t, heads)
+}
+
+func TestCrawlFailsWhenLinkCheckTimesOut(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte(`<!doctype html><title>Home</title><a href="/slow">Slow</a><a href="/next">Next</a>`))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	u := model.URL{URL: server.URL}
+	err := Crawl(ctx, &u, Options{})
+
+	var crawlErr *CrawlError
+	require.ErrorAs(t, err, &crawlErr)
+	assert.Equal(t, ErrorTimeout, crawlErr.Class)
+	assert.Equal(t, "error", u.Status)
+	assert.Equal(t, ErrorTimeout, u.ErrorClass)
+	// Links the crawl ran out of time for are not reported as broken.
+	assert.Zero(t, u.BrokenLinks)
+	for _, link := range u.Links {
+		assert.False(t, link.Broken, link.Href)
+	}
+}
+
+func sampleCount(t *testing.T, h prometheus.Observer) uint64 {
+	var m dto.Metric
+	require.NoError(t, h.(prometheus.Metric).Write(&m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestCrawlURLTracesPhases(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	var traceparents []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+		mu.Unlock()
+		w.Write([]byte(`<html><a href="/a">A</a><a href="/b">B</a></html>`))
+	}))
+	defer server.Close()
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	require.NoError(t, CrawlURL(ctx, &model.URL{URL: server.URL}))
+	parent.End()
+
+	byName := map[string]sdktrace.ReadOnlySpan{}
+	children := map[string]int{}
+	for _, span := range recorder.Ended() {
+		byName[span.Name()] = span
+		children[span.Parent().SpanID().String()]++
+	}
+	require.Contains(t, byName, "crawler.CrawlURL")
+	crawl := byName["crawler.CrawlURL"]
+	assert.Equal(t, parent.SpanContext().TraceID(), crawl.SpanContext().TraceID())
+	for _, name := range []string{"fetch", "parse", "extract", "check links"} {
+		require.Contains(t, byName, name)
+		assert.Equal(t, crawl.SpanContext().SpanID(), byName[name].Parent().SpanID(), name)
+	}
+	// One client span for the page, one for each link check.
+	assert.Equal(t, 1, children[byName["fetch"].SpanContext().SpanID().String()])
+	assert.Equal(t, 2, children[byName["check links"].SpanContext().SpanID().String()])
+
+	require.Len(t, traceparents, 3)
+	for _, header := range traceparents {
+		assert.Contains(t, header, parent.SpanContext().TraceID().String())
+	}
+}
@@ -0,0 +1,68 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+)
+
+// Classes of crawl failure, stored on the URL as ErrorClass.
+const (
+	ErrorDNS        = "dns"
+	ErrorTimeout    = "timeout"
+	ErrorConnection = "connection"
+	ErrorTLS        = "tls"
+	ErrorInvalidURL = "invalid_url"
+	ErrorRead       = "read"
+	ErrorParse      = "parse"
+	// ErrorCanceled means the crawl was cut short, as when the server
+	// shuts down, and says nothing about the page.
+	ErrorCanceled = "canceled"
+	ErrorUnknown  = "unknown"
+)
+
+// maxErrorMessage bounds the failure reason stored on a URL.
+const maxErrorMessage = 1000
+
+// CrawlError is returned by CrawlURL when a page cannot be crawled.
+type CrawlError struct {
+	Class string
+	Err   error
+}
+
+func (e *CrawlError) Error() string {
+	return e.Class + ": " + e.Err.Error()
+}
+
+func (e *CrawlError) Unwrap() error {
+	return e.Err
+}
+
+// classifyFetchError maps an error from the HTTP client to a failure
+// class.
+func classifyFetchError(err error) string {
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var recordErr tls.RecordHeaderError
+	var netErr net.Error
+	var opErr *net.OpError
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorCanceled
+	case errors.As(err, &dnsErr):
+		return ErrorDNS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+	case errors.As(err, &certErr), errors.As(err, &unknownAuthority), errors.As(err, &hostnameErr), errors.As(err, &recordErr):
+		return ErrorTLS
+	case errors.As(err, &opErr):
+		return ErrorConnection
+	default:
+		return ErrorUnknown
+	}
+}
@@ -1,20 +1,40 @@
 package crawler
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+
+	"url-crawler-backend/internal/metrics"
 )
 
-func fetchHTML(url string) (string, error) {
-	resp, err := http.Get(url)
+func fetchHTML(ctx context.Context, rawURL string, m *metrics.Registry) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", &CrawlError{Class: ErrorInvalidURL, Err: err}
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", &CrawlError{Class: ErrorInvalidURL, Err: fmt.Errorf("unsupported scheme %q", parsed.Scheme)}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", &CrawlError{Class: ErrorInvalidURL, Err: err}
+	}
+	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return "", &CrawlError{Class: classifyFetchError(err), Err: err}
 	}
 	defer resp.Body.Close()
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return "", &CrawlError{Class: ErrorRead, Err: err}
+	}
+	if m != nil {
+		m.FetchBytes.Observe(float64(len(bodyBytes)))
 	}
 	return string(bodyBytes), nil
 }
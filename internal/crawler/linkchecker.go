@@ -1,16 +1,25 @@
 package crawler
 
 import (
+	"context"
 	"net/http"
 	"net/url"
 
+	"url-crawler-backend/internal/metrics"
+	"url-crawler-backend/internal/model"
+
 	"github.com/PuerkitoBio/goquery"
 )
 
 func analyzeLinks(doc *goquery.Document, baseURL string) (int, int, int) {
-	internalLinks := 0
-	externalLinks := 0
-	brokenLinks := 0
+	links := collectLinks(doc, baseURL)
+	checkLinks(context.Background(), links, nil)
+	return countLinks(links)
+}
+
+// collectLinks resolves every anchor on the page against baseURL.
+func collectLinks(doc *goquery.Document, baseURL string) []model.Link {
+	var links []model.Link
 
 	base, _ := url.Parse(baseURL)
 
@@ -26,17 +35,67 @@ func analyzeLinks(doc *goquery.Document, baseURL string) (int, int, int) {
 		}
 
 		resolved := base.ResolveReference(linkURL)
-		if resolved.Hostname() == base.Hostname() {
-			internalLinks++
-		} else {
-			externalLinks++
+		links = append(links, model.Link{
+			Href:     resolved.String(),
+			Internal: resolved.Hostname() == base.Hostname(),
+		})
+	})
+
+	return links
+}
+
+// checkLinks sends a HEAD request to every link and records whether it is
+// reachable. It stops with ctx's error when ctx ends, leaving the links not
+// yet checked, and the one being checked, unmarked. Each outcome is counted
+// in m when it is not nil.
+func checkLinks(ctx context.Context, links []model.Link, m *metrics.Registry) error {
+	for i := range links {
+		link := &links[i]
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 
-		linkResp, err := http.Head(resolved.String())
-		if err != nil || linkResp.StatusCode >= 400 {
-			brokenLinks++
+		linkResp, err := head(ctx, link.Href)
+		if err != nil {
+			if ctx.Err() != nil {
+				// The crawl ran out of time, which says nothing about the
+				// link.
+				return ctx.Err()
+			}
+			link.Broken = true
+			if m != nil {
+				m.LinkChecks.WithLabelValues(classifyFetchError(err)).Inc()
+			}
+		} else {
+			linkResp.Body.Close()
+			link.StatusCode = linkResp.StatusCode
+			link.Broken = linkResp.StatusCode >= 400
+			if m != nil {
+				m.LinkChecks.WithLabelValues(metrics.StatusClass(linkResp.StatusCode)).Inc()
+			}
 		}
-	})
+	}
+	return nil
+}
+
+func head(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
 
-	return internalLinks, externalLinks, brokenLinks
+func countLinks(links []model.Link) (internal, external, broken int) {
+	for _, link := range links {
+		if link.Internal {
+			internal++
+		} else {
+			external++
+		}
+		if link.Broken {
+			broken++
+		}
+	}
+	return internal, external, broken
 }
@@ -19,7 +19,7 @@ func parseDocument(htmlStr string) (*goquery.Document, error) {
 }
 
 func extractTitle(doc *goquery.Document) string {
-	return strings.TrimSpace(doc.Find("title").Text())
+	return strings.TrimSpace(doc.Find("title").First().Text())
 }
 
 func extractHeadingSummary(doc *goquery.Document) string {
@@ -2,43 +2,90 @@ package db
 
 import (
 	"fmt"
-	"os"
-	"url-crawler-backend/internal/model"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"url-crawler-backend/internal/config"
+	"url-crawler-backend/internal/metrics"
 
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-var DB *gorm.DB
-
-func Connect() {
-	dbUser := os.Getenv("DB_USER")
-	dbPass := os.Getenv("DB_PASS")
-	dbHost := os.Getenv("DB_HOST")
-	dbPort := os.Getenv("DB_PORT")
-	dbName := os.Getenv("DB_NAME")
-
-	if dbPort == "" {
-		dbPort = "3306"
+// Open connects to the database cfg describes, records the time each
+// statement takes in m unless it is nil, and logs statements through slog.
+// It leaves the schema alone; package migrate creates and updates it.
+func Open(cfg config.Database, m *metrics.Registry) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch cfg.Driver {
+	case config.DriverMySQL:
+		dialector = mysql.Open(mysqlDSN(cfg))
+	case config.DriverPostgres:
+		dialector = postgres.Open(postgresDSN(cfg))
+	case config.DriverSQLite:
+		dialector = sqlite.Open(sqliteDSN(cfg.DSN))
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
 	}
+	conn, err := gorm.Open(dialector, &gorm.Config{Logger: queryLogger{}})
+	if err != nil {
+		return nil, err
+	}
+	if err := instrument(conn, m); err != nil {
+		return nil, fmt.Errorf("instrument database: %w", err)
+	}
+	return conn, nil
+}
 
-	dsn := fmt.Sprintf(
-		"%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		dbUser,
-		dbPass,
-		dbHost,
-		dbPort,
-		dbName,
+func mysqlDSN(cfg config.Database) string {
+	if cfg.DSN != "" {
+		return cfg.DSN
+	}
+	return fmt.Sprintf(
+		"%s:%s@tcp(%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		cfg.User,
+		cfg.Password,
+		net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		cfg.Name,
 	)
+}
 
-	connection, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
-	if err != nil {
-		panic(fmt.Sprintf("Failed to connect to DB: %v", err))
+func postgresDSN(cfg config.Database) string {
+	if cfg.DSN != "" {
+		return cfg.DSN
+	}
+	u := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(cfg.User, cfg.Password),
+		Host:   net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		Path:   "/" + cfg.Name,
 	}
+	return u.String()
+}
 
-	if err := connection.AutoMigrate(&model.URL{}, &model.User{}); err != nil {
-		panic(fmt.Sprintf("Failed to run migrations: %v", err))
+// sqliteDSN adds the options that let background crawls write while
+// requests are served, and enforce foreign keys as the other databases do.
+// Options already in the DSN are kept.
+func sqliteDSN(dsn string) string {
+	options := []string{"_busy_timeout=5000", "_foreign_keys=on", "_txlock=immediate"}
+	if !strings.Contains(dsn, ":memory:") && !strings.Contains(dsn, "mode=memory") {
+		options = append(options, "_journal_mode=WAL")
 	}
 
-	DB = connection
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	for _, option := range options {
+		name, _, _ := strings.Cut(option, "=")
+		if !strings.Contains(dsn, name+"=") {
+			dsn += sep + option
+			sep = "&"
+		}
+	}
+	return dsn
 }
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"url-crawler-backend/internal/config"
+	"url-crawler-backend/internal/metrics"
+	"url-crawler-backend/internal/migrate"
+	"url-crawler-backend/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestOpenSQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crawler.db")
+	m := metrics.New()
+	conn, err := Open(config.Database{Driver: config.DriverSQLite, DSN: path}, m)
+	require.NoError(t, err)
+	sqlDB, err := conn.DB()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	_, err = migrate.Up(conn)
+	require.NoError(t, err)
+
+	workspace := model.Workspace{Name: "Team"}
+	require.NoError(t, conn.Create(&workspace).Error)
+	require.NoError(t, conn.Create(&model.URL{WorkspaceID: workspace.ID, URL: "https://example.com", Status: "queued"}).Error)
+
+	var count int64
+	require.NoError(t, conn.Model(&model.URL{}).Count(&count).Error)
+	assert.EqualValues(t, 1, count)
+
+	// Statements are timed per operation and table.
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), `url_crawler_db_query_duration_seconds_count{operation="create",table="urls"}`)
+
+	var mode string
+	require.NoError(t, conn.Raw("PRAGMA journal_mode").Scan(&mode).Error)
+	assert.Equal(t, "wal", mode)
+}
+
+func TestOpenTracesQueries(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	conn, err := Open(config.Database{Driver: config.DriverSQLite, DSN: filepath.Join(t.TempDir(), "crawler.db")}, nil)
+	require.NoError(t, err)
+	sqlDB, err := conn.DB()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	_, err = migrate.Up(conn)
+	require.NoError(t, err)
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	require.NoError(t, conn.WithContext(ctx).Create(&model.Workspace{Name: "Team"}).Error)
+	parent.End()
+
+	var create sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "db.create" && span.Parent().SpanID() == parent.SpanContext().SpanID() {
+			create = span
+		}
+	}
+	require.NotNil(t, create, "no db.create span under the request span")
+	assert.Contains(t, create.Attributes(), attribute.String("db.collection.name", "workspaces"))
+}
+
+func TestDSN(t *testing.T) {
+	assert.Equal(t,
+		"crawler:secret@tcp(db:3306)/crawler?charset=utf8mb4&parseTime=True&loc=Local",
+		mysqlDSN(config.Database{Host: "db", Port: 3306, User: "crawler", Password: "secret", Name: "crawler"}),
+	)
+	assert.Equal(t,
+		"postgres://crawler:p%40ss@db:5432/crawler",
+		postgresDSN(config.Database{Host: "db", Port: 5432, User: "crawler", Password: "p@ss", Name: "crawler"}),
+	)
+	assert.Equal(t, "custom", postgresDSN(config.Database{DSN: "custom", Host: "db"}))
+
+	assert.Equal(t,
+		"crawler.db?_busy_timeout=5000&_foreign_keys=on&_txlock=immediate&_journal_mode=WAL",
+		sqliteDSN("crawler.db"),
+	)
+	assert.Equal(t,
+		"file::memory:?cache=shared&_busy_timeout=1000&_foreign_keys=on&_txlock=immediate",
+		sqliteDSN("file::memory:?cache=shared&_busy_timeout=1000"),
+	)
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"url-crawler-backend/internal/metrics"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	queryStartKey = "metrics:query_start"
+	querySpanKey  = "tracing:query_span"
+)
+
+// tracerName is the instrumentation scope of the query spans.
+const tracerName = "url-crawler-backend/internal/db"
+
+// instrument times every statement run through conn into
+// m.DBQueryDuration and traces it as a child of the span in the
+// statement's context, if any.
+func instrument(conn *gorm.DB, m *metrics.Registry) error {
+	cb := conn.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("metrics:before_create", startQuery("create")),
+		cb.Create().After("gorm:create").Register("metrics:after_create", observeQuery("create", m)),
+		cb.Query().Before("gorm:query").Register("metrics:before_query", startQuery("query")),
+		cb.Query().After("gorm:query").Register("metrics:after_query", observeQuery("query", m)),
+		cb.Update().Before("gorm:update").Register("metrics:before_update", startQuery("update")),
+		cb.Update().After("gorm:update").Register("metrics:after_update", observeQuery("update", m)),
+		cb.Delete().Before("gorm:delete").Register("metrics:before_delete", startQuery("delete")),
+		cb.Delete().After("gorm:delete").Register("metrics:after_delete", observeQuery("delete", m)),
+		cb.Row().Before("gorm:row").Register("metrics:before_row", startQuery("row")),
+		cb.Row().After("gorm:row").Register("metrics:after_row", observeQuery("row", m)),
+		cb.Raw().Before("gorm:raw").Register("metrics:before_raw", startQuery("raw")),
+		cb.Raw().After("gorm:raw").Register("metrics:after_raw", observeQuery("raw", m)),
+	)
+}
+
+func startQuery(operation string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		_, span := otel.Tracer(tracerName).Start(tx.Statement.Context, "db."+operation, trace.WithSpanKind(trace.SpanKindClient))
+		tx.InstanceSet(querySpanKey, span)
+		tx.InstanceSet(queryStartKey, time.Now())
+	}
+}
+
+func observeQuery(operation string, m *metrics.Registry) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		table := tx.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		if value, ok := tx.InstanceGet(querySpanKey); ok {
+			span := value.(trace.Span)
+			span.SetAttributes(
+				attribute.String("db.system", tx.Dialector.Name()),
+				attribute.String("db.operation.name", operation),
+				attribute.String("db.collection.name", table),
+				attribute.Int64("db.rows_affected", tx.RowsAffected),
+			)
+			if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+				span.RecordError(tx.Error)
+				span.SetStatus(codes.Error, tx.Error.Error())
+			}
+			span.End()
+		}
+		if value, ok := tx.InstanceGet(queryStartKey); ok && m != nil {
+			m.DBQueryDuration.WithLabelValues(operation, table).Observe(time.Since(value.(time.Time)).Seconds())
+		}
+	}
+}
@@ -0,0 +1,244 @@
+// Package export writes crawl results as CSV, newline-delimited JSON or
+// XLSX. Writers stream one URL at a time so exports of any size run in
+// constant memory.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"url-crawler-backend/internal/model"
+)
+
+type Format string
+
+const (
+	CSV    Format = "csv"
+	NDJSON Format = "ndjson"
+	XLSX   Format = "xlsx"
+)
+
+// ParseFormat accepts a format name from a query string. The empty string
+// selects CSV.
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "", "csv":
+		return CSV, nil
+	case "ndjson", "jsonl":
+		return NDJSON, nil
+	case "xlsx":
+		return XLSX, nil
+	}
+	return "", fmt.Errorf("unknown export format %q, expected csv, ndjson or xlsx", name)
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case NDJSON:
+		return "application/x-ndjson"
+	case XLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+func (f Format) Extension() string {
+	if f == NDJSON {
+		return "jsonl"
+	}
+	return string(f)
+}
+
+// Writer writes exported URLs. When links are included they are taken
+// from each URL's Links field.
+type Writer interface {
+	Write(u model.URL) error
+	Close() error
+}
+
+// NewWriter returns a Writer for format f. Close must be called to flush
+// buffered output; it does not close w.
+func NewWriter(w io.Writer, f Format, withLinks bool) (Writer, error) {
+	switch f {
+	case CSV:
+		return newCSVWriter(w, withLinks)
+	case NDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(w), withLinks: withLinks}, nil
+	case XLSX:
+		return newXLSXWriter(w, withLinks)
+	}
+	return nil, fmt.Errorf("unknown export format %q", f)
+}
+
+var urlColumns = []string{
+	"id", "url", "status", "html_version", "page_title", "headings",
+	"internal_links", "external_links", "broken_links", "has_login_form",
+	"created_at", "updated_at", "tags", "notes",
+}
+
+var linkColumns = []string{"link_url", "link_internal", "link_status_code", "link_broken"}
+
+func urlValues(u model.URL) []any {
+	return []any{
+		u.ID, u.URL, u.Status, u.HTMLVersion, u.PageTitle, u.Headings,
+		u.InternalLinks, u.ExternalLinks, u.BrokenLinks, u.HasLoginForm,
+		u.CreatedAt, u.UpdatedAt, tagList(u.Tags), u.Notes,
+	}
+}
+
+// tagList joins tag names with "; ", the separator the CSV import reads.
+func tagList(tags []model.Tag) string {
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = tag.Name
+	}
+	return strings.Join(names, "; ")
+}
+
+func linkValues(l model.Link) []any {
+	return []any{l.Href, l.Internal, l.StatusCode, l.Broken}
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return formatTime(v)
+	}
+	return fmt.Sprint(v)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+type csvWriter struct {
+	w         *csv.Writer
+	withLinks bool
+}
+
+func newCSVWriter(w io.Writer, withLinks bool) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w), withLinks: withLinks}
+	header := urlColumns
+	if withLinks {
+		header = append(append([]string{}, urlColumns...), linkColumns...)
+	}
+	return cw, cw.w.Write(header)
+}
+
+// Write emits one row per URL, or with links one row per link repeating
+// the URL's columns.
+func (cw *csvWriter) Write(u model.URL) error {
+	values := urlValues(u)
+	if !cw.withLinks || len(u.Links) == 0 {
+		if cw.withLinks {
+			values = append(values, "", "", "", "")
+		}
+		return cw.w.Write(csvRecord(values))
+	}
+
+	for _, link := range u.Links {
+		if err := cw.w.Write(csvRecord(append(values[:len(values):len(values)], linkValues(link)...))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// csvRecord formats values, quoting strings that a spreadsheet would
+// otherwise evaluate as a formula. Page titles come from crawled sites and
+// cannot be trusted.
+func csvRecord(values []any) []string {
+	record := make([]string, len(values))
+	for i, v := range values {
+		s := formatValue(v)
+		if _, isString := v.(string); isString && s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+			s = "'" + s
+		}
+		record[i] = s
+	}
+	return record
+}
+
+type ndjsonURL struct {
+	ID            uint         `json:"id"`
+	URL           string       `json:"url"`
+	Status        string       `json:"status"`
+	HTMLVersion   string       `json:"html_version"`
+	PageTitle     string       `json:"page_title"`
+	Headings      string       `json:"headings"`
+	InternalLinks int          `json:"internal_links"`
+	ExternalLinks int          `json:"external_links"`
+	BrokenLinks   int          `json:"broken_links"`
+	HasLoginForm  bool         `json:"has_login_form"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+	Tags          []string     `json:"tags"`
+	Notes         string       `json:"notes"`
+	Links         []ndjsonLink `json:"links,omitzero"`
+}
+
+type ndjsonLink struct {
+	URL        string `json:"url"`
+	Internal   bool   `json:"internal"`
+	StatusCode int    `json:"status_code"`
+	Broken     bool   `json:"broken"`
+}
+
+type ndjsonWriter struct {
+	enc       *json.Encoder
+	withLinks bool
+}
+
+func (nw *ndjsonWriter) Write(u model.URL) error {
+	record := ndjsonURL{
+		ID:            u.ID,
+		URL:           u.URL,
+		Status:        u.Status,
+		HTMLVersion:   u.HTMLVersion,
+		PageTitle:     u.PageTitle,
+		Headings:      u.Headings,
+		InternalLinks: u.InternalLinks,
+		ExternalLinks: u.ExternalLinks,
+		BrokenLinks:   u.BrokenLinks,
+		HasLoginForm:  u.HasLoginForm,
+		CreatedAt:     u.CreatedAt,
+		UpdatedAt:     u.UpdatedAt,
+		Tags:          make([]string, len(u.Tags)),
+		Notes:         u.Notes,
+	}
+	for i, tag := range u.Tags {
+		record.Tags[i] = tag.Name
+	}
+	if nw.withLinks {
+		record.Links = make([]ndjsonLink, len(u.Links))
+		for i, l := range u.Links {
+			record.Links[i] = ndjsonLink{URL: l.Href, Internal: l.Internal, StatusCode: l.StatusCode, Broken: l.Broken}
+		}
+	}
+	return nw.enc.Encode(record)
+}
+
+func (nw *ndjsonWriter) Close() error {
+	return nil
+}
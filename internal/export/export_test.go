@@ -0,0 +1,129 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"url-crawler-backend/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var sample = []model.URL{
+	{
+		ID: 1, URL: "https://example.com", Status: "done", PageTitle: "=HYPERLINK(\"x\")",
+		InternalLinks: 1, ExternalLinks: 1, BrokenLinks: 1,
+		CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Tags:      []model.Tag{{Name: "client-a"}, {Name: "seo"}},
+		Notes:     "homepage",
+		Links: []model.Link{
+			{Href: "https://example.com/a", Internal: true, StatusCode: 200},
+			{Href: "https://broken.example", Broken: true},
+		},
+	},
+	{ID: 2, URL: "https://example.org", Status: "queued"},
+}
+
+func writeAll(t *testing.T, f Format, withLinks bool) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, f, withLinks)
+	require.NoError(t, err)
+	for _, u := range sample {
+		require.NoError(t, w.Write(u))
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestParseFormat(t *testing.T) {
+	for name, want := range map[string]Format{"": CSV, "CSV": CSV, "jsonl": NDJSON, "ndjson": NDJSON, "xlsx": XLSX} {
+		got, err := ParseFormat(name)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := ParseFormat("pdf")
+	assert.Error(t, err)
+}
+
+func TestCSV(t *testing.T) {
+	records, err := csv.NewReader(bytes.NewReader(writeAll(t, CSV, false))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, urlColumns, records[0])
+	assert.Equal(t, "https://example.com", records[1][1])
+	assert.Equal(t, `'=HYPERLINK("x")`, records[1][4])
+	assert.Equal(t, "2024-05-01T12:00:00Z", records[1][10])
+	assert.Equal(t, []string{"client-a; seo", "homepage"}, records[1][12:14])
+
+	records, err = csv.NewReader(bytes.NewReader(writeAll(t, CSV, true))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4, "one row per link plus one for the URL without links")
+	assert.Equal(t, "link_url", records[0][14])
+	assert.Equal(t, []string{"https://example.com/a", "true", "200", "false"}, records[1][14:])
+	assert.Equal(t, []string{"https://broken.example", "false", "0", "true"}, records[2][14:])
+	assert.Equal(t, "2", records[3][0])
+	assert.Equal(t, []string{"", "", "", ""}, records[3][14:])
+}
+
+func TestNDJSON(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(string(writeAll(t, NDJSON, true))), "\n")
+	require.Len(t, lines, 2)
+
+	var first, second map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &second))
+	assert.Equal(t, "https://example.com", first["url"])
+	assert.Len(t, first["links"], 2)
+	assert.Equal(t, []any{"client-a", "seo"}, first["tags"])
+	assert.Equal(t, []any{}, second["tags"])
+	assert.Equal(t, []any{}, second["links"])
+
+	lines = strings.Split(strings.TrimSpace(string(writeAll(t, NDJSON, false))), "\n")
+	var withoutLinks map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &withoutLinks))
+	assert.NotContains(t, withoutLinks, "links")
+}
+
+func TestXLSX(t *testing.T) {
+	data := writeAll(t, XLSX, true)
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	files := map[string]string{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		body, _ := io.ReadAll(r)
+		r.Close()
+		files[f.Name] = string(body)
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels"} {
+		assert.Contains(t, files, name)
+	}
+	assert.Contains(t, files["xl/workbook.xml"], `<sheet name="Links" sheetId="2" r:id="rId2"/>`)
+
+	urls := files["xl/worksheets/sheet1.xml"]
+	assert.Contains(t, urls, `<c r="A2"><v>1</v></c>`)
+	assert.Contains(t, urls, `<c r="E2" t="inlineStr"><is><t xml:space="preserve">=HYPERLINK(&#34;x&#34;)</t></is></c>`)
+	assert.Contains(t, urls, `<c r="J2" t="b"><v>0</v></c>`)
+	assert.True(t, strings.HasSuffix(urls, "</sheetData></worksheet>"))
+
+	links := files["xl/worksheets/sheet2.xml"]
+	assert.Equal(t, 3, strings.Count(links, "<row "))
+	assert.Contains(t, links, `<c r="C3" t="inlineStr"><is><t xml:space="preserve">https://broken.example</t></is></c>`)
+}
+
+func TestXLSXColumn(t *testing.T) {
+	assert.Equal(t, "A", xlsxColumn(0))
+	assert.Equal(t, "Z", xlsxColumn(25))
+	assert.Equal(t, "AA", xlsxColumn(26))
+	assert.Equal(t, "BA", xlsxColumn(52))
+}
@@ -0,0 +1,214 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"url-crawler-backend/internal/model"
+)
+
+const (
+	xlsxSheetHead = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxSheetTail = `</sheetData></worksheet>`
+)
+
+// xlsxWriter streams a workbook with a "URLs" sheet and, when links are
+// included, a "Links" sheet. A zip archive can only have one entry open at
+// a time, so link rows are spooled to a temporary file and copied into the
+// archive on Close.
+type xlsxWriter struct {
+	zip     *zip.Writer
+	urls    *bufio.Writer
+	urlRow  int
+	links   *bufio.Writer
+	spool   *os.File
+	linkRow int
+}
+
+var linkSheetColumns = []string{"url_id", "url", "link_url", "internal", "status_code", "broken"}
+
+func newXLSXWriter(w io.Writer, withLinks bool) (*xlsxWriter, error) {
+	xw := &xlsxWriter{zip: zip.NewWriter(w)}
+
+	entry, err := xw.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	xw.urls = bufio.NewWriter(entry)
+	xw.urls.WriteString(xlsxSheetHead)
+	xw.urlRow++
+	writeXLSXRow(xw.urls, xw.urlRow, stringValues(urlColumns))
+
+	if withLinks {
+		xw.spool, err = os.CreateTemp("", "export-links-*.xml")
+		if err != nil {
+			return nil, err
+		}
+		xw.links = bufio.NewWriter(xw.spool)
+		xw.linkRow++
+		writeXLSXRow(xw.links, xw.linkRow, stringValues(linkSheetColumns))
+	}
+
+	return xw, nil
+}
+
+func (xw *xlsxWriter) Write(u model.URL) error {
+	xw.urlRow++
+	writeXLSXRow(xw.urls, xw.urlRow, urlValues(u))
+
+	if xw.links != nil {
+		for _, l := range u.Links {
+			xw.linkRow++
+			writeXLSXRow(xw.links, xw.linkRow, []any{u.ID, u.URL, l.Href, l.Internal, l.StatusCode, l.Broken})
+		}
+		if err := xw.links.Flush(); err != nil {
+			return err
+		}
+	}
+	return xw.urls.Flush()
+}
+
+func (xw *xlsxWriter) Close() error {
+	if xw.spool != nil {
+		defer os.Remove(xw.spool.Name())
+		defer xw.spool.Close()
+	}
+
+	xw.urls.WriteString(xlsxSheetTail)
+	if err := xw.urls.Flush(); err != nil {
+		return err
+	}
+
+	sheets := []string{"URLs"}
+	if xw.spool != nil {
+		sheets = append(sheets, "Links")
+		if err := xw.copyLinkSheet(); err != nil {
+			return err
+		}
+	}
+
+	if err := xw.writeWorkbook(sheets); err != nil {
+		return err
+	}
+	return xw.zip.Close()
+}
+
+func (xw *xlsxWriter) copyLinkSheet() error {
+	if err := xw.links.Flush(); err != nil {
+		return err
+	}
+	if _, err := xw.spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	entry, err := xw.zip.Create("xl/worksheets/sheet2.xml")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(entry, xlsxSheetHead); err != nil {
+		return err
+	}
+	if _, err := io.Copy(entry, xw.spool); err != nil {
+		return err
+	}
+	_, err = io.WriteString(entry, xlsxSheetTail)
+	return err
+}
+
+func (xw *xlsxWriter) writeWorkbook(sheets []string) error {
+	var workbook, rels, overrides strings.Builder
+	for i, name := range sheets {
+		n := i + 1
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, name, n, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+		fmt.Fprintf(&overrides, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+	}
+
+	parts := []struct{ name, body string }{
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+			`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>` +
+			workbook.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			rels.String() + `</Relationships>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			overrides.String() + `</Types>`},
+	}
+
+	for _, part := range parts {
+		entry, err := xw.zip.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(entry, part.body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeXLSXRow writes one <row> element. Write errors are reported when
+// the buffered writer is flushed.
+func writeXLSXRow(w *bufio.Writer, row int, values []any) {
+	fmt.Fprintf(w, `<row r="%d">`, row)
+	for i, v := range values {
+		ref := xlsxColumn(i) + strconv.Itoa(row)
+		switch v := v.(type) {
+		case int, uint:
+			fmt.Fprintf(w, `<c r="%s"><v>%d</v></c>`, ref, v)
+		case bool:
+			b := 0
+			if v {
+				b = 1
+			}
+			fmt.Fprintf(w, `<c r="%s" t="b"><v>%d</v></c>`, ref, b)
+		case time.Time:
+			writeXLSXString(w, ref, formatTime(v))
+		default:
+			writeXLSXString(w, ref, formatValue(v))
+		}
+	}
+	w.WriteString(`</row>`)
+}
+
+func writeXLSXString(w *bufio.Writer, ref, s string) {
+	if s == "" {
+		return
+	}
+	fmt.Fprintf(w, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+	xml.EscapeText(w, []byte(s))
+	w.WriteString(`</t></is></c>`)
+}
+
+// xlsxColumn converts a zero-based column index to its letter name.
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func stringValues(columns []string) []any {
+	values := make([]any, len(columns))
+	for i, c := range columns {
+		values[i] = c
+	}
+	return values
+}
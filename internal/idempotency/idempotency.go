@@ -0,0 +1,148 @@
+// Package idempotency stores the responses of requests made with an
+// Idempotency-Key header so that retries of the same request get the
+// original response instead of repeating its effects.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"url-crawler-backend/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultTTL = 24 * time.Hour
+	// MaxKeyLength is the longest key accepted.
+	MaxKeyLength = 255
+
+	purgeInterval = time.Hour
+	pollInterval  = 100 * time.Millisecond
+	// lockTimeout is how long a request may hold a key before another
+	// request with the key assumes it died and takes over.
+	lockTimeout = 5 * time.Minute
+)
+
+var (
+	// ErrMismatch means the key was already used for a different request.
+	ErrMismatch = errors.New("idempotency key was used for a different request")
+	// ErrInProgress means the request holding the key did not finish in
+	// time.
+	ErrInProgress = errors.New("a request with this idempotency key is in progress")
+)
+
+// Fingerprint hashes the parts of a request that must match for a key to
+// be reused.
+func Fingerprint(parts ...[]byte) string {
+	h := sha256.New()
+	for _, part := range parts {
+		fmt.Fprintf(h, "%d:", len(part))
+		h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Acquire claims the user's key for a request. If the key is new, or its
+// previous use has expired, the returned record has a zero StatusCode and
+// the caller must finish it with Complete or Release. If the key already
+// has a stored response, that record is returned for replay. While another
+// request holds the key Acquire waits for it until ctx is done, so
+// concurrent retries run one at a time.
+func Acquire(ctx context.Context, conn *gorm.DB, userID uint, key, fingerprint string, ttl time.Duration) (*model.IdempotencyKey, error) {
+	for {
+		now := time.Now()
+		record := &model.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			Fingerprint: fingerprint,
+			ExpiresAt:   now.Add(ttl),
+		}
+		result := conn.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			return record, nil
+		}
+
+		var existing model.IdempotencyKey
+		err := conn.WithContext(ctx).Where("user_id = ? AND idempotency_key = ?", userID, key).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Released or purged since the insert; try again.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		abandoned := existing.StatusCode == 0 && existing.CreatedAt.Before(now.Add(-lockTimeout))
+		if existing.ExpiresAt.Before(now) || abandoned {
+			if err := conn.WithContext(ctx).Where("id = ?", existing.ID).Delete(&model.IdempotencyKey{}).Error; err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if existing.Fingerprint != fingerprint {
+			return nil, ErrMismatch
+		}
+		if existing.StatusCode != 0 {
+			return &existing, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ErrInProgress
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// Complete stores the response for replay.
+func Complete(conn *gorm.DB, record *model.IdempotencyKey, status int, contentType string, body []byte) error {
+	return conn.Model(record).Updates(map[string]interface{}{
+		"status_code":  status,
+		"content_type": contentType,
+		"body":         body,
+	}).Error
+}
+
+// Release gives up the key without storing a response, so the request can
+// be retried.
+func Release(conn *gorm.DB, record *model.IdempotencyKey) error {
+	return conn.Delete(record).Error
+}
+
+// Purge deletes keys that expired before now.
+func Purge(conn *gorm.DB, now time.Time) (int64, error) {
+	result := conn.Where("expires_at < ?", now).Delete(&model.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
+
+// StartPurger deletes expired keys every hour until ctx is cancelled.
+func StartPurger(ctx context.Context, conn *gorm.DB) {
+	go func() {
+		ticker := time.NewTicker(purgeInterval)
+		defer ticker.Stop()
+
+		for {
+			if n, err := Purge(conn, time.Now()); err != nil {
+				slog.ErrorContext(ctx, "Failed to purge idempotency keys", "error", err)
+			} else if n > 0 {
+				slog.InfoContext(ctx, "Purged expired idempotency keys", "count", n)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
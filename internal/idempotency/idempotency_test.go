@@ -0,0 +1,57 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"url-crawler-backend/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestAcquire(t *testing.T) {
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, conn.AutoMigrate(&model.IdempotencyKey{}))
+	ctx := context.Background()
+
+	record, err := Acquire(ctx, conn, 1, "k", "a", time.Hour)
+	require.NoError(t, err)
+	assert.Zero(t, record.StatusCode)
+
+	// A retry while the first request runs gives up when ctx is done.
+	short, cancel := context.WithTimeout(ctx, 3*pollInterval)
+	defer cancel()
+	_, err = Acquire(short, conn, 1, "k", "a", time.Hour)
+	assert.ErrorIs(t, err, ErrInProgress)
+
+	require.NoError(t, Complete(conn, record, 201, "application/json", []byte(`{"id":1}`)))
+	replay, err := Acquire(ctx, conn, 1, "k", "a", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 201, replay.StatusCode)
+	assert.Equal(t, `{"id":1}`, string(replay.Body))
+
+	_, err = Acquire(ctx, conn, 1, "k", "b", time.Hour)
+	assert.ErrorIs(t, err, ErrMismatch)
+
+	// Expired keys can be reused for any request.
+	conn.Model(replay).Update("expires_at", time.Now().Add(-time.Minute))
+	record, err = Acquire(ctx, conn, 1, "k", "b", time.Hour)
+	require.NoError(t, err)
+	assert.Zero(t, record.StatusCode)
+
+	// Released keys run again.
+	require.NoError(t, Release(conn, record))
+	record, err = Acquire(ctx, conn, 1, "k", "b", time.Hour)
+	require.NoError(t, err)
+	assert.Zero(t, record.StatusCode)
+
+	conn.Model(record).Update("expires_at", time.Now().Add(-time.Minute))
+	purged, err := Purge(conn, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+}
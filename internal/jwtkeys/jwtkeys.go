@@ -0,0 +1,301 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+type Key struct {
+	ID         string
+	Algorithm  string
+	ActiveFrom time.Time
+	ExpiresAt  time.Time
+	// VerifyOnly keys never sign. Load keeps JWT_SECRET as one while a
+	// deployment moves to a key manifest.
+	VerifyOnly bool
+
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// KeySet holds every key that may sign or verify tokens. The signing key is
+// the most recently activated, unexpired key; all unexpired keys verify, so
+// tokens issued before a rotation stay valid until their key expires.
+type KeySet struct {
+	keys []*Key
+	now  func() time.Time
+}
+
+type manifest struct {
+	Keys []struct {
+		ID             string     `json:"kid"`
+		Algorithm      string     `json:"alg"`
+		PrivateKeyFile string     `json:"private_key_file"`
+		ActiveFrom     time.Time  `json:"active_from"`
+		ExpiresAt      *time.Time `json:"expires_at"`
+	} `json:"keys"`
+}
+
+// JWK is a public JSON Web Key as served from the JWKS endpoint.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Load returns the key set to sign and verify tokens with. Without a
+// keysFile, tokens are signed with HS256 and secret. With one, the manifest
+// keys sign, and a non-empty secret is kept as a verify-only key so tokens
+// issued before the switch stay valid until JWT_SECRET is removed.
+func Load(secret, keysFile string) (*KeySet, error) {
+	if keysFile == "" {
+		return NewHMAC([]byte(secret)), nil
+	}
+
+	keys, err := LoadFile(keysFile)
+	if err != nil {
+		return nil, err
+	}
+	if secret != "" {
+		legacy := NewHMAC([]byte(secret)).keys[0]
+		legacy.VerifyOnly = true
+		keys.keys = append([]*Key{legacy}, keys.keys...)
+	}
+	return keys, nil
+}
+
+func NewHMAC(secret []byte) *KeySet {
+	return &KeySet{
+		keys: []*Key{{
+			Algorithm: AlgorithmHS256,
+			signKey:   secret,
+			verifyKey: secret,
+		}},
+		now: time.Now,
+	}
+}
+
+// LoadFile reads a JSON manifest listing the keys and their rotation
+// schedule. Relative private_key_file paths resolve against the manifest.
+func LoadFile(path string) (*KeySet, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key manifest: %w", err)
+	}
+
+	var m manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("parse key manifest: %w", err)
+	}
+	if len(m.Keys) == 0 {
+		return nil, errors.New("key manifest lists no keys")
+	}
+
+	set := &KeySet{now: time.Now}
+	seen := map[string]bool{}
+	for _, entry := range m.Keys {
+		if entry.ID == "" {
+			return nil, errors.New("every key needs a kid")
+		}
+		if seen[entry.ID] {
+			return nil, fmt.Errorf("duplicate kid %q", entry.ID)
+		}
+		seen[entry.ID] = true
+
+		keyPath := entry.PrivateKeyFile
+		if !filepath.IsAbs(keyPath) {
+			keyPath = filepath.Join(filepath.Dir(path), keyPath)
+		}
+		pemBytes, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", entry.ID, err)
+		}
+
+		key, err := ParsePrivateKey(entry.ID, entry.Algorithm, pemBytes)
+		if err != nil {
+			return nil, err
+		}
+		key.ActiveFrom = entry.ActiveFrom
+		if entry.ExpiresAt != nil {
+			key.ExpiresAt = *entry.ExpiresAt
+		}
+		set.keys = append(set.keys, key)
+	}
+
+	sort.Slice(set.keys, func(i, j int) bool {
+		return set.keys[i].ActiveFrom.Before(set.keys[j].ActiveFrom)
+	})
+
+	return set, nil
+}
+
+// ParsePrivateKey decodes a PEM encoded RSA or Ed25519 private key. An empty
+// algorithm is inferred from the key type.
+func ParsePrivateKey(kid, alg string, pemBytes []byte) (*Key, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, fmt.Errorf("key %s: no PEM block found", kid)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		rsaKey, rsaErr := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if rsaErr != nil {
+			return nil, fmt.Errorf("key %s: %w", kid, err)
+		}
+		parsed = rsaKey
+	}
+
+	key := &Key{ID: kid, Algorithm: alg}
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		if key.Algorithm == "" {
+			key.Algorithm = AlgorithmRS256
+		}
+		key.signKey = private
+		key.verifyKey = &private.PublicKey
+	case ed25519.PrivateKey:
+		if key.Algorithm == "" {
+			key.Algorithm = AlgorithmEdDSA
+		}
+		key.signKey = private
+		key.verifyKey = private.Public()
+	default:
+		return nil, fmt.Errorf("key %s: unsupported key type %T", kid, parsed)
+	}
+
+	switch key.Algorithm {
+	case AlgorithmRS256:
+		if _, ok := key.signKey.(*rsa.PrivateKey); !ok {
+			return nil, fmt.Errorf("key %s: RS256 requires an RSA key", kid)
+		}
+	case AlgorithmEdDSA:
+		if _, ok := key.signKey.(ed25519.PrivateKey); !ok {
+			return nil, fmt.Errorf("key %s: EdDSA requires an Ed25519 key", kid)
+		}
+	default:
+		return nil, fmt.Errorf("key %s: unsupported algorithm %q", kid, key.Algorithm)
+	}
+
+	return key, nil
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+func (k *Key) usable(now time.Time) bool {
+	return k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt)
+}
+
+// SigningKey returns the newest key whose activation time has passed.
+func (s *KeySet) SigningKey() (*Key, error) {
+	now := s.now()
+	var current *Key
+	for _, k := range s.keys {
+		if !k.VerifyOnly && !k.ActiveFrom.After(now) && k.usable(now) {
+			current = k
+		}
+	}
+	if current == nil {
+		return nil, errors.New("no active signing key")
+	}
+	return current, nil
+}
+
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key, err := s.SigningKey()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.method(), claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	return token.SignedString(key.signKey)
+}
+
+// Keyfunc resolves the verification key for a token by its kid header and
+// rejects tokens whose alg does not match that key. HMAC keys have no kid,
+// so tokens without one only verify while an HMAC key is in the set.
+func (s *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	now := s.now()
+
+	for _, k := range s.keys {
+		if k.ID != kid || !k.usable(now) {
+			continue
+		}
+		if token.Method.Alg() != k.Algorithm {
+			return nil, fmt.Errorf("unexpected signing method %q", token.Method.Alg())
+		}
+		return k.verifyKey, nil
+	}
+
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// JWKS lists the public half of every unexpired asymmetric key, including
+// keys scheduled for the future so verifiers can cache them ahead of a
+// rotation. Shared HMAC secrets are never published.
+func (s *KeySet) JWKS() JWKS {
+	now := s.now()
+	set := JWKS{Keys: []JWK{}}
+
+	for _, k := range s.keys {
+		if !k.usable(now) {
+			continue
+		}
+		switch public := k.verifyKey.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "RSA",
+				KeyID:     k.ID,
+				Algorithm: k.Algorithm,
+				Use:       "sig",
+				N:         base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "OKP",
+				KeyID:     k.ID,
+				Algorithm: k.Algorithm,
+				Use:       "sig",
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+
+	return set
+}
@@ -0,0 +1,162 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePEM(t *testing.T, dir, name string, key interface{}) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0o600))
+}
+
+// writeRotatingSet creates an expired RSA key, a current RSA key and an
+// Ed25519 key scheduled for the future.
+func writeRotatingSet(t *testing.T, now time.Time) string {
+	dir := t.TempDir()
+
+	for _, name := range []string{"old.pem", "current.pem"} {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		writePEM(t, dir, name, key)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	writePEM(t, dir, "next.pem", edKey)
+
+	manifest := map[string]interface{}{
+		"keys": []map[string]interface{}{
+			{"kid": "old", "private_key_file": "old.pem", "active_from": now.Add(-60 * 24 * time.Hour), "expires_at": now.Add(-time.Hour)},
+			{"kid": "current", "private_key_file": "current.pem", "active_from": now.Add(-24 * time.Hour)},
+			{"kid": "next", "alg": "EdDSA", "private_key_file": "next.pem", "active_from": now.Add(24 * time.Hour)},
+		},
+	}
+	raw, _ := json.Marshal(manifest)
+	path := filepath.Join(dir, "keys.json")
+	require.NoError(t, os.WriteFile(path, raw, 0o600))
+
+	return path
+}
+
+func TestRotationSchedule(t *testing.T) {
+	now := time.Now()
+	set, err := LoadFile(writeRotatingSet(t, now))
+	require.NoError(t, err)
+
+	set.now = func() time.Time { return now }
+	key, err := set.SigningKey()
+	require.NoError(t, err)
+	assert.Equal(t, "current", key.ID)
+	assert.Equal(t, AlgorithmRS256, key.Algorithm)
+
+	set.now = func() time.Time { return now.Add(48 * time.Hour) }
+	key, err = set.SigningKey()
+	require.NoError(t, err)
+	assert.Equal(t, "next", key.ID)
+	assert.Equal(t, AlgorithmEdDSA, key.Algorithm)
+}
+
+func TestSignAndVerifyAcrossRotation(t *testing.T) {
+	now := time.Now()
+	set, err := LoadFile(writeRotatingSet(t, now))
+	require.NoError(t, err)
+
+	set.now = func() time.Time { return now }
+	signed, err := set.Sign(jwt.MapClaims{"id": 1, "exp": now.Add(time.Hour).Unix()})
+	require.NoError(t, err)
+
+	// After the next key takes over, tokens from the previous key still verify.
+	set.now = func() time.Time { return now.Add(48 * time.Hour) }
+	parsed, err := jwt.Parse(signed, set.Keyfunc, jwt.WithoutClaimsValidation())
+	require.NoError(t, err)
+	assert.Equal(t, "current", parsed.Header["kid"])
+	assert.Equal(t, "RS256", parsed.Method.Alg())
+
+	rotated, err := set.Sign(jwt.MapClaims{"id": 1})
+	require.NoError(t, err)
+	parsed, err = jwt.Parse(rotated, set.Keyfunc)
+	require.NoError(t, err)
+	assert.Equal(t, "EdDSA", parsed.Method.Alg())
+}
+
+func TestKeyfuncRejectsUnknownAndMismatchedKeys(t *testing.T) {
+	set, err := LoadFile(writeRotatingSet(t, time.Now()))
+	require.NoError(t, err)
+
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"id": 1})
+	forged.Header["kid"] = "current"
+	signed, _ := forged.SignedString([]byte("guess"))
+	_, err = jwt.Parse(signed, set.Keyfunc)
+	assert.Error(t, err)
+
+	expired := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"id": 1})
+	expired.Header["kid"] = "old"
+	signed, _ = expired.SignedString([]byte("guess"))
+	_, err = jwt.Parse(signed, set.Keyfunc)
+	assert.Error(t, err)
+}
+
+func TestJWKSPublishesUnexpiredPublicKeys(t *testing.T) {
+	set, err := LoadFile(writeRotatingSet(t, time.Now()))
+	require.NoError(t, err)
+
+	jwks := set.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "current", jwks.Keys[0].KeyID)
+	assert.Equal(t, "RSA", jwks.Keys[0].KeyType)
+	assert.NotEmpty(t, jwks.Keys[0].N)
+	assert.Equal(t, "next", jwks.Keys[1].KeyID)
+	assert.Equal(t, "OKP", jwks.Keys[1].KeyType)
+	assert.Equal(t, "Ed25519", jwks.Keys[1].Curve)
+}
+
+func TestHMACFallback(t *testing.T) {
+	set := NewHMAC([]byte("secret"))
+
+	signed, err := set.Sign(jwt.MapClaims{"id": 1})
+	require.NoError(t, err)
+
+	parsed, err := jwt.Parse(signed, set.Keyfunc)
+	require.NoError(t, err)
+	assert.Equal(t, "HS256", parsed.Method.Alg())
+	assert.Empty(t, set.JWKS().Keys)
+}
+
+func TestLoadKeepsSecretVerifyOnly(t *testing.T) {
+	legacy, err := NewHMAC([]byte("secret")).Sign(jwt.MapClaims{"id": 1})
+	require.NoError(t, err)
+	path := writeRotatingSet(t, time.Now())
+
+	set, err := Load("secret", path)
+	require.NoError(t, err)
+
+	// Tokens signed with JWT_SECRET before the switch still verify...
+	_, err = jwt.Parse(legacy, set.Keyfunc)
+	require.NoError(t, err)
+
+	// ...but new tokens are signed by the manifest.
+	key, err := set.SigningKey()
+	require.NoError(t, err)
+	assert.Equal(t, "current", key.ID)
+	assert.Len(t, set.JWKS().Keys, 2)
+
+	// Once JWT_SECRET is removed, tokens without a kid are rejected.
+	retired, err := Load("", path)
+	require.NoError(t, err)
+	_, err = jwt.Parse(legacy, retired.Keyfunc)
+	assert.Error(t, err)
+}
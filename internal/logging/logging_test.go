@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Config{Level: "warn", Format: FormatJSON})
+	require.NoError(t, err)
+
+	ctx := With(context.Background(), "request_id", "abc")
+	crawl := With(ctx, "url_id", 7)
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1}, SpanID: trace.SpanID{2}, TraceFlags: trace.FlagsSampled,
+	})
+
+	logger.InfoContext(crawl, "Skipped")
+	logger.WarnContext(trace.ContextWithSpanContext(crawl, sc), "Crawl failed", "error_class", "dns")
+	logger.ErrorContext(ctx, "Request failed")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	var record map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, "Crawl failed", record["msg"])
+	assert.Equal(t, "dns", record["error_class"])
+	assert.Equal(t, "abc", record["request_id"])
+	assert.EqualValues(t, 7, record["url_id"])
+	assert.Equal(t, sc.TraceID().String(), record["trace_id"])
+	assert.Equal(t, sc.SpanID().String(), record["span_id"])
+
+	// Attributes added for the crawl do not leak into the request.
+	record = nil
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+	assert.Equal(t, "abc", record["request_id"])
+	assert.NotContains(t, record, "url_id")
+	assert.NotContains(t, record, "trace_id")
+}
+
+func TestNewText(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Config{Level: "DEBUG", Format: "Text"})
+	require.NoError(t, err)
+	logger.DebugContext(With(context.Background(), "url_id", 7), "Crawl phase finished", "phase", "fetch")
+	assert.Contains(t, buf.String(), `level=DEBUG msg="Crawl phase finished" phase=fetch url_id=7`)
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{Level: "error", Format: FormatText}.Validate())
+	err := Config{Level: "verbose", Format: "xml"}.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "LOG_LEVEL")
+	assert.Contains(t, err.Error(), "LOG_FORMAT")
+}
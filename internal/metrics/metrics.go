@@ -0,0 +1,109 @@
+// Package metrics defines the Prometheus metrics the server exposes on
+// /metrics. The API, the crawler and the database layer update the
+// Registry they are given; its Handler serves them along with the Go
+// runtime and process metrics.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "url_crawler"
+
+// Registry holds the metrics of one server. The server, its crawls and its
+// database connection all record into the same Registry.
+type Registry struct {
+	registry *prometheus.Registry
+
+	HTTPRequests    *prometheus.CounterVec
+	HTTPDuration    *prometheus.HistogramVec
+	CrawlDuration   *prometheus.HistogramVec
+	FetchBytes      prometheus.Histogram
+	LinkChecks      *prometheus.CounterVec
+	CrawlQueueDepth prometheus.Gauge
+	ActiveCrawls    prometheus.Gauge
+	DBQueryDuration *prometheus.HistogramVec
+}
+
+// New returns a Registry with every metric at zero, along with the Go
+// runtime and process metrics.
+func New() *Registry {
+	r := &Registry{
+		registry: prometheus.NewRegistry(),
+
+		HTTPRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+
+		HTTPDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time to serve HTTP requests by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+
+		CrawlDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "crawl_duration_seconds",
+			Help:      "Time to crawl a page, including its link checks, by outcome: done or the error class.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 10),
+		}, []string{"outcome"}),
+
+		FetchBytes: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "crawl_fetch_bytes",
+			Help:      "Size of the fetched pages.",
+			Buckets:   prometheus.ExponentialBuckets(1024, 4, 8),
+		}),
+
+		LinkChecks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "link_checks_total",
+			Help:      "Link checks by outcome: the status class (2xx to 5xx) or the error class of a failed request.",
+		}, []string{"outcome"}),
+
+		CrawlQueueDepth: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "crawl_queue_depth",
+			Help:      "Crawls waiting for their crawl quota.",
+		}),
+
+		ActiveCrawls: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "crawl_active_workers",
+			Help:      "Crawls running now.",
+		}),
+
+		DBQueryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Time taken by database statements, by operation and table.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}, []string{"operation", "table"}),
+	}
+	r.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		r.HTTPRequests, r.HTTPDuration,
+		r.CrawlDuration, r.FetchBytes, r.LinkChecks, r.CrawlQueueDepth, r.ActiveCrawls,
+		r.DBQueryDuration,
+	)
+	return r
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (r *Registry) Handler() http.Handler {
+	return promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{})
+}
+
+// StatusClass groups an HTTP status code as "2xx", "3xx" and so on.
+func StatusClass(code int) string {
+	return strconv.Itoa(code/100) + "xx"
+}
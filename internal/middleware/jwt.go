@@ -1,14 +1,36 @@
 package middleware
 
 import (
-	"os"
+	"errors"
 
+	"url-crawler-backend/internal/jwtkeys"
+
+	"github.com/golang-jwt/jwt/v5"
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 )
 
-func JWTMiddleware() echo.MiddlewareFunc {
+func JWTMiddleware(keys *jwtkeys.KeySet) echo.MiddlewareFunc {
 	return echojwt.WithConfig(echojwt.Config{
-		SigningKey: []byte(os.Getenv("JWT_SECRET")),
+		ParseTokenFunc: func(c echo.Context, auth string) (interface{}, error) {
+			return parseAccessToken(keys, auth)
+		},
 	})
 }
+
+// parseAccessToken verifies the token against keys. Tokens with a typ
+// claim, such as 2FA challenge tokens, are not access tokens.
+func parseAccessToken(keys *jwtkeys.KeySet, auth string) (interface{}, error) {
+	token, err := jwt.Parse(auth, keys.Keyfunc)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := token.Claims.(jwt.MapClaims); ok {
+		if typ, _ := claims["typ"].(string); typ != "" {
+			return nil, errors.New("not an access token")
+		}
+	}
+
+	return token, nil
+}
@@ -0,0 +1,227 @@
+// Package migrate applies versioned schema migrations and records them in
+// the schema_migrations table. Migrations are Go functions rather than SQL
+// files so that one migration works on MySQL, PostgreSQL and SQLite.
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration changes the schema, or the data in it, from one version to the
+// next. Down undoes Up; a nil Down means there is nothing to undo, as for
+// data backfills.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// schemaMigration is a row of schema_migrations, one per applied migration.
+type schemaMigration struct {
+	Version   int    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"type:varchar(255);not null"`
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Status describes a migration and whether it has been applied. Migrations
+// recorded in the database but unknown to this build have no Up or Down.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+type Migrator struct {
+	conn       *gorm.DB
+	migrations []Migration
+}
+
+// New returns a Migrator for the given migrations, which may be in any
+// order but must have distinct positive versions.
+func New(conn *gorm.DB, migrations []Migration) (*Migrator, error) {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i, m := range sorted {
+		if m.Version <= 0 || m.Up == nil {
+			return nil, fmt.Errorf("migration %s needs a positive version and an Up function", m)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("migrations %s and %s share a version", sorted[i-1], m)
+		}
+	}
+	return &Migrator{conn: conn, migrations: sorted}, nil
+}
+
+// Up applies every pending migration in order, each in its own
+// transaction, and returns the ones it applied. It stops at the first
+// failure. MySQL commits schema changes immediately, so a migration that
+// fails there halfway may need cleaning up by hand.
+func (m *Migrator) Up() ([]Migration, error) {
+	if err := m.conn.AutoMigrate(&schemaMigration{}); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		err := m.conn.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %s: %w", migration, err)
+		}
+		slog.Info("Applied migration", "migration", migration.String())
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down rolls back the latest steps applied migrations, newest first, and
+// returns the ones it rolled back.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		err := m.conn.Transaction(func(tx *gorm.DB) error {
+			if migration.Down != nil {
+				if err := migration.Down(tx); err != nil {
+					return err
+				}
+			}
+			return tx.Delete(&schemaMigration{}, migration.Version).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("roll back migration %s: %w", migration, err)
+		}
+		slog.Info("Rolled back migration", "migration", migration.String())
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Status lists every known migration, followed by any applied migrations
+// this build does not know about.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		s := Status{Migration: migration}
+		if record, ok := applied[migration.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = &record.AppliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, s)
+	}
+
+	var unknown []Status
+	for _, record := range applied {
+		appliedAt := record.AppliedAt
+		unknown = append(unknown, Status{
+			Migration: Migration{Version: record.Version, Name: record.Name},
+			Applied:   true,
+			AppliedAt: &appliedAt,
+		})
+	}
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i].Version < unknown[j].Version })
+	return append(statuses, unknown...), nil
+}
+
+// Pending returns the migrations that have not been applied yet.
+func (m *Migrator) Pending() ([]Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, s := range statuses {
+		if !s.Applied {
+			pending = append(pending, s.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// applied returns the rows of schema_migrations by version. It only reads,
+// so Check can run on every readiness probe; before the first Up the table
+// does not exist and nothing has been applied.
+func (m *Migrator) applied() (map[int]schemaMigration, error) {
+	if !m.conn.Migrator().HasTable(&schemaMigration{}) {
+		return map[int]schemaMigration{}, nil
+	}
+
+	var records []schemaMigration
+	if err := m.conn.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]schemaMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// ErrPending is returned by Check when the schema is behind this build.
+var ErrPending = errors.New("database has pending migrations")
+
+// Check returns ErrPending, wrapped with the migrations' names, unless every
+// migration in All has been applied.
+func Check(conn *gorm.DB) error {
+	m, err := New(conn, All)
+	if err != nil {
+		return err
+	}
+	pending, err := m.Pending()
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %v", ErrPending, pending)
+	}
+	return nil
+}
+
+// Up applies every pending migration in All.
+func Up(conn *gorm.DB) ([]Migration, error) {
+	m, err := New(conn, All)
+	if err != nil {
+		return nil, err
+	}
+	return m.Up()
+}
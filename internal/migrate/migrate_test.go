@@ -0,0 +1,186 @@
+package migrate
+
+import (
+	"errors"
+	"testing"
+
+	"url-crawler-backend/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := conn.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	return conn
+}
+
+func TestMigrator(t *testing.T) {
+	conn := openTestDB(t)
+
+	type widget struct {
+		ID   uint
+		Name string
+	}
+	migrations := []Migration{
+		{Version: 2, Name: "add_widget_label", Up: func(tx *gorm.DB) error {
+			return tx.Exec("ALTER TABLE widgets ADD COLUMN label TEXT").Error
+		}, Down: func(tx *gorm.DB) error {
+			return tx.Exec("ALTER TABLE widgets DROP COLUMN label").Error
+		}},
+		{Version: 1, Name: "create_widgets", Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&widget{})
+		}, Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("widgets")
+		}},
+	}
+	m, err := New(conn, migrations)
+	require.NoError(t, err)
+
+	pending, err := m.Pending()
+	require.NoError(t, err)
+	assert.Len(t, pending, 2)
+
+	done, err := m.Up()
+	require.NoError(t, err)
+	require.Len(t, done, 2)
+	assert.Equal(t, "0001_create_widgets", done[0].String())
+	assert.True(t, conn.Migrator().HasColumn("widgets", "label"))
+
+	done, err = m.Up()
+	require.NoError(t, err)
+	assert.Empty(t, done)
+
+	done, err = m.Down(1)
+	require.NoError(t, err)
+	require.Len(t, done, 1)
+	assert.Equal(t, 2, done[0].Version)
+	assert.False(t, conn.Migrator().HasColumn("widgets", "label"))
+
+	statuses, err := m.Status()
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.True(t, statuses[0].Applied)
+	assert.NotNil(t, statuses[0].AppliedAt)
+	assert.False(t, statuses[1].Applied)
+
+	// A failing migration is rolled back and not recorded.
+	m, err = New(conn, append(migrations, Migration{Version: 3, Name: "broken", Up: func(tx *gorm.DB) error {
+		if err := tx.Exec("CREATE TABLE gadgets (id INTEGER)").Error; err != nil {
+			return err
+		}
+		return errors.New("boom")
+	}}))
+	require.NoError(t, err)
+	done, err = m.Up()
+	assert.ErrorContains(t, err, "0003_broken")
+	assert.Len(t, done, 1)
+	assert.False(t, conn.Migrator().HasTable("gadgets"))
+	pending, err = m.Pending()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 3, pending[0].Version)
+
+	_, err = New(conn, []Migration{migrations[0], migrations[0]})
+	assert.Error(t, err)
+}
+
+// Check runs on every readiness probe and must not change the schema.
+func TestCheckDoesNotWrite(t *testing.T) {
+	conn := openTestDB(t)
+
+	assert.ErrorIs(t, Check(conn), ErrPending)
+	assert.False(t, conn.Migrator().HasTable("schema_migrations"))
+}
+
+// The migrations must produce every column the models use.
+func TestAllMatchesModels(t *testing.T) {
+	conn := openTestDB(t)
+	_, err := Up(conn)
+	require.NoError(t, err)
+	require.NoError(t, Check(conn))
+
+	for _, m := range []interface{}{
+		&model.URL{}, &model.Link{}, &model.Tag{}, &model.User{}, &model.UserIdentity{}, &model.RecoveryCode{},
+		&model.Workspace{}, &model.Membership{}, &model.AuditLog{}, &model.IdempotencyKey{},
+	} {
+		stmt := &gorm.Statement{DB: conn}
+		require.NoError(t, stmt.Parse(m))
+		require.True(t, conn.Migrator().HasTable(stmt.Schema.Table), stmt.Schema.Table)
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" || field.IgnoreMigration {
+				continue
+			}
+			assert.True(t, conn.Migrator().HasColumn(stmt.Schema.Table, field.DBName), "%s.%s", stmt.Schema.Table, field.DBName)
+		}
+	}
+	assert.True(t, conn.Migrator().HasTable("url_tags"))
+
+	m, err := New(conn, All)
+	require.NoError(t, err)
+	_, err = m.Down(len(All))
+	require.NoError(t, err)
+	assert.False(t, conn.Migrator().HasTable("urls"))
+	assert.ErrorIs(t, Check(conn), ErrPending)
+}
+
+// Databases created by releases that ran AutoMigrate on boot are adopted
+// and their legacy rows backfilled.
+func TestUpAdoptsAutoMigratedSchema(t *testing.T) {
+	conn := openTestDB(t)
+	require.NoError(t, conn.AutoMigrate(&model.URL{}, &model.User{}))
+	require.NoError(t, conn.Create(&model.User{Username: "alice", Password: "x"}).Error)
+	require.NoError(t, conn.Create(&model.User{Username: "root", Password: "x", IsAdmin: true}).Error)
+	require.NoError(t, conn.Session(&gorm.Session{SkipHooks: true}).Create(&model.URL{URL: "HTTPS://Example.com/a/", Status: "done"}).Error)
+
+	_, err := Up(conn)
+	require.NoError(t, err)
+
+	var u model.URL
+	require.NoError(t, conn.First(&u).Error)
+	assert.NotZero(t, u.WorkspaceID)
+	assert.NotNil(t, u.URLHash)
+	assert.Equal(t, "example.com", u.Host)
+
+	// Only system administrators become admins of the shared workspace.
+	var members []model.Membership
+	require.NoError(t, conn.Where("workspace_id = ?", u.WorkspaceID).Order("user_id").Find(&members).Error)
+	require.Len(t, members, 2)
+	assert.Equal(t, model.RoleEditor, members[0].Role)
+	assert.Equal(t, model.RoleAdmin, members[1].Role)
+}
+
+func TestUpRecordsPersonalWorkspaceUsers(t *testing.T) {
+	conn := openTestDB(t)
+	m, err := New(conn, All[:6])
+	require.NoError(t, err)
+	_, err = m.Up()
+	require.NoError(t, err)
+
+	// Before the fix a user could end up with two personal workspaces.
+	require.NoError(t, conn.Exec("INSERT INTO users (id, username, password) VALUES (1, 'alice', 'x')").Error)
+	for id := 1; id <= 2; id++ {
+		require.NoError(t, conn.Exec("INSERT INTO workspaces (id, name, personal) VALUES (?, 'alice', ?)", id, true).Error)
+		require.NoError(t, conn.Exec("INSERT INTO memberships (workspace_id, user_id, role) VALUES (?, 1, ?)", id, model.RoleOwner).Error)
+	}
+
+	_, err = Up(conn)
+	require.NoError(t, err)
+
+	var workspaces []model.Workspace
+	require.NoError(t, conn.Order("id").Find(&workspaces).Error)
+	require.Len(t, workspaces, 2)
+	require.NotNil(t, workspaces[0].PersonalUserID)
+	assert.Equal(t, uint(1), *workspaces[0].PersonalUserID)
+	assert.Nil(t, workspaces[1].PersonalUserID)
+
+	userID := uint(1)
+	assert.Error(t, conn.Create(&model.Workspace{Name: "again", Personal: true, PersonalUserID: &userID}).Error)
+}
@@ -0,0 +1,414 @@
+package migrate
+
+import (
+	"log/slog"
+	"time"
+
+	"url-crawler-backend/internal/model"
+	"url-crawler-backend/internal/urlnorm"
+
+	"gorm.io/gorm"
+)
+
+// All lists the schema's migrations. Append new ones with the next
+// version; never edit or reorder a migration that has been released.
+//
+// Migrations declare the tables they touch as local types rather than
+// using package model, so they keep describing the schema as it was at
+// their version when the models change later.
+var All = []Migration{
+	{Version: 1, Name: "baseline", Up: baselineUp, Down: baselineDown},
+	{Version: 2, Name: "assign_legacy_urls", Up: assignLegacyURLs},
+	{Version: 3, Name: "normalize_urls", Up: normalizeURLs},
+	{Version: 4, Name: "record_url_hosts", Up: recordURLHosts},
+	{Version: 5, Name: "count_two_factor_failures", Up: countTwoFactorFailuresUp, Down: countTwoFactorFailuresDown},
+	{Version: 6, Name: "lock_two_factor", Up: lockTwoFactorUp, Down: lockTwoFactorDown},
+	{Version: 7, Name: "record_personal_workspace_users", Up: recordPersonalWorkspaceUsersUp, Down: recordPersonalWorkspaceUsersDown},
+	{Version: 8, Name: "mark_pending_crawls", Up: markPendingCrawlsUp, Down: markPendingCrawlsDown},
+}
+
+// baselineTables returns the schema as it was when migrations were
+// introduced. AutoMigrate creates it on an empty database and brings a
+// database created by earlier releases, which ran AutoMigrate on every
+// boot, up to the same shape.
+func baselineTables() []interface{} {
+	type Tag struct {
+		ID          uint   `gorm:"primaryKey"`
+		WorkspaceID uint   `gorm:"uniqueIndex:idx_tags_workspace_name;not null"`
+		Name        string `gorm:"type:varchar(64);uniqueIndex:idx_tags_workspace_name;not null"`
+		CreatedAt   time.Time
+		UpdatedAt   time.Time
+	}
+	type Link struct {
+		ID         uint   `gorm:"primaryKey"`
+		URLID      uint   `gorm:"index;not null"`
+		Href       string `gorm:"type:text"`
+		Internal   bool
+		StatusCode int
+		Broken     bool
+		CreatedAt  time.Time
+	}
+	type URL struct {
+		ID            uint `gorm:"primaryKey"`
+		WorkspaceID   uint `gorm:"index;uniqueIndex:idx_urls_workspace_hash"`
+		URL           string
+		NormalizedURL string  `gorm:"type:text"`
+		URLHash       *string `gorm:"type:char(64);uniqueIndex:idx_urls_workspace_hash"`
+		Host          string  `gorm:"type:varchar(255);index"`
+		HTMLVersion   string
+		PageTitle     string
+		Headings      string
+		InternalLinks int
+		ExternalLinks int
+		BrokenLinks   int
+		HasLoginForm  bool
+		Status        string
+		ErrorClass    string     `gorm:"type:varchar(32)"`
+		ErrorMessage  string     `gorm:"type:text"`
+		Notes         string     `gorm:"type:text"`
+		Tags          []Tag      `gorm:"many2many:url_tags"`
+		CrawledAt     *time.Time `gorm:"index"`
+		CreatedAt     time.Time
+		UpdatedAt     time.Time
+		DeletedAt     gorm.DeletedAt `gorm:"index"`
+
+		Links []Link `gorm:"foreignKey:URLID"`
+	}
+	type User struct {
+		ID           uint   `gorm:"primaryKey"`
+		Username     string `gorm:"type:varchar(255);uniqueIndex;not null"`
+		Password     string `gorm:"type:varchar(255);not null"`
+		IsAdmin      bool   `gorm:"not null;default:false"`
+		TOTPSecret   string `gorm:"type:varchar(64)"`
+		TOTPEnabled  bool   `gorm:"not null;default:false"`
+		TOTPLastStep int64
+		CreatedAt    time.Time
+		UpdatedAt    time.Time
+	}
+	type UserIdentity struct {
+		ID        uint   `gorm:"primaryKey"`
+		UserID    uint   `gorm:"index;not null"`
+		Issuer    string `gorm:"type:varchar(255);uniqueIndex:idx_identity_issuer_subject;not null"`
+		Subject   string `gorm:"type:varchar(255);uniqueIndex:idx_identity_issuer_subject;not null"`
+		Email     string `gorm:"type:varchar(255)"`
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+	type RecoveryCode struct {
+		ID        uint   `gorm:"primaryKey"`
+		UserID    uint   `gorm:"index;not null"`
+		CodeHash  string `gorm:"type:char(64);uniqueIndex;not null"`
+		UsedAt    *time.Time
+		CreatedAt time.Time
+	}
+	type Workspace struct {
+		ID        uint   `gorm:"primaryKey"`
+		Name      string `gorm:"type:varchar(255);not null"`
+		Personal  bool   `gorm:"not null;default:false"`
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+	type Membership struct {
+		ID          uint   `gorm:"primaryKey"`
+		WorkspaceID uint   `gorm:"uniqueIndex:idx_membership_workspace_user;not null"`
+		UserID      uint   `gorm:"uniqueIndex:idx_membership_workspace_user;index;not null"`
+		Role        string `gorm:"type:varchar(32);not null"`
+		Workspace   *Workspace
+		User        *User
+		CreatedAt   time.Time
+		UpdatedAt   time.Time
+	}
+	type AuditLog struct {
+		ID            uint      `gorm:"primaryKey"`
+		ActorID       *uint     `gorm:"index"`
+		ActorUsername string    `gorm:"type:varchar(255)"`
+		Action        string    `gorm:"type:varchar(64);index;not null"`
+		Outcome       string    `gorm:"type:varchar(16);not null"`
+		WorkspaceID   *uint     `gorm:"index"`
+		TargetType    string    `gorm:"type:varchar(64)"`
+		TargetIDs     string    `gorm:"type:text"`
+		Detail        string    `gorm:"type:text"`
+		IP            string    `gorm:"type:varchar(64)"`
+		CreatedAt     time.Time `gorm:"index"`
+	}
+	type IdempotencyKey struct {
+		ID          uint   `gorm:"primaryKey"`
+		UserID      uint   `gorm:"uniqueIndex:idx_idempotency_user_key;not null"`
+		Key         string `gorm:"column:idempotency_key;type:varchar(255);uniqueIndex:idx_idempotency_user_key;not null"`
+		Fingerprint string `gorm:"type:char(64);not null"`
+		StatusCode  int
+		ContentType string `gorm:"type:varchar(255)"`
+		Body        []byte
+		ExpiresAt   time.Time `gorm:"index"`
+		CreatedAt   time.Time
+	}
+
+	return []interface{}{
+		&URL{}, &Link{}, &Tag{}, &User{}, &UserIdentity{}, &RecoveryCode{},
+		&Workspace{}, &Membership{}, &AuditLog{}, &IdempotencyKey{},
+	}
+}
+
+func baselineUp(tx *gorm.DB) error {
+	return tx.AutoMigrate(baselineTables()...)
+}
+
+func baselineDown(tx *gorm.DB) error {
+	if err := tx.Migrator().DropTable("url_tags"); err != nil {
+		return err
+	}
+	tables := baselineTables()
+	for i := len(tables) - 1; i >= 0; i-- {
+		if err := tx.Migrator().DropTable(tables[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assignLegacyURLs moves URLs created before workspaces existed into a
+// shared "Default" workspace whose members are all existing users, so
+// nobody loses access to what they could see before. System administrators
+// become workspace admins; everyone else can edit but not delete.
+func assignLegacyURLs(tx *gorm.DB) error {
+	type workspace struct {
+		ID        uint
+		Name      string
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+	type membership struct {
+		ID          uint
+		WorkspaceID uint
+		UserID      uint
+		Role        string
+		CreatedAt   time.Time
+		UpdatedAt   time.Time
+	}
+
+	var count int64
+	if err := tx.Table("urls").Where("workspace_id = 0 OR workspace_id IS NULL").Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+
+	ws := workspace{Name: "Default"}
+	if err := tx.Table("workspaces").Create(&ws).Error; err != nil {
+		return err
+	}
+
+	var users []struct {
+		ID      uint
+		IsAdmin bool
+	}
+	if err := tx.Table("users").Select("id, is_admin").Order("id").Find(&users).Error; err != nil {
+		return err
+	}
+	for _, user := range users {
+		role := model.RoleEditor
+		if user.IsAdmin {
+			role = model.RoleAdmin
+		}
+		m := membership{WorkspaceID: ws.ID, UserID: user.ID, Role: role}
+		if err := tx.Table("memberships").Create(&m).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Table("urls").
+		Where("workspace_id = 0 OR workspace_id IS NULL").
+		Update("workspace_id", ws.ID).Error
+}
+
+type legacyURL struct {
+	ID            uint
+	WorkspaceID   uint
+	URL           string
+	NormalizedURL string
+}
+
+// normalizeURLs normalizes URLs stored before normalization existed. When
+// several rows in a workspace normalize to the same URL only the oldest
+// gets the hash; the others stay as they are so no data is lost.
+func normalizeURLs(tx *gorm.DB) error {
+	var batch []legacyURL
+	return tx.Table("urls").Where("url_hash IS NULL AND (normalized_url IS NULL OR normalized_url = ?)", "").FindInBatches(&batch, 500, func(_ *gorm.DB, _ int) error {
+		for _, u := range batch {
+			normalized, err := urlnorm.Normalize(u.URL)
+			if err != nil {
+				normalized = u.URL
+			}
+			hash := urlnorm.Hash(normalized)
+
+			var taken int64
+			if err := tx.Table("urls").
+				Where("workspace_id = ? AND url_hash = ?", u.WorkspaceID, hash).
+				Count(&taken).Error; err != nil {
+				return err
+			}
+
+			updates := map[string]interface{}{"normalized_url": normalized}
+			if taken == 0 {
+				updates["url_hash"] = hash
+			} else {
+				slog.Warn("URL duplicates another URL after normalization", "url_id", u.ID, "workspace_id", u.WorkspaceID)
+			}
+			if err := tx.Table("urls").Where("id = ?", u.ID).UpdateColumns(updates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// recordURLHosts fills in the host of URLs stored before it was recorded.
+func recordURLHosts(tx *gorm.DB) error {
+	var batch []legacyURL
+	return tx.Table("urls").Where("host IS NULL OR host = ?", "").FindInBatches(&batch, 500, func(_ *gorm.DB, _ int) error {
+		for _, u := range batch {
+			host := model.HostOf(u.NormalizedURL, u.URL)
+			if host == "" {
+				continue
+			}
+			if err := tx.Table("urls").Where("id = ?", u.ID).UpdateColumn("host", host).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+type twoFactorFailuresUser struct {
+	TwoFactorFailures int `gorm:"not null;default:0"`
+}
+
+func (twoFactorFailuresUser) TableName() string { return "users" }
+
+// countTwoFactorFailuresUp adds the counter of wrong 2FA codes. Databases
+// adopted from AutoMigrate may have it already.
+func countTwoFactorFailuresUp(tx *gorm.DB) error {
+	if tx.Migrator().HasColumn(&twoFactorFailuresUser{}, "TwoFactorFailures") {
+		return nil
+	}
+	return tx.Migrator().AddColumn(&twoFactorFailuresUser{}, "TwoFactorFailures")
+}
+
+func countTwoFactorFailuresDown(tx *gorm.DB) error {
+	return tx.Migrator().DropColumn(&twoFactorFailuresUser{}, "TwoFactorFailures")
+}
+
+type twoFactorLockUser struct {
+	TwoFactorLockedUntil *time.Time
+}
+
+func (twoFactorLockUser) TableName() string { return "users" }
+
+// lockTwoFactorUp adds the end of a 2FA lockout. Databases adopted from
+// AutoMigrate may have it already.
+func lockTwoFactorUp(tx *gorm.DB) error {
+	if tx.Migrator().HasColumn(&twoFactorLockUser{}, "TwoFactorLockedUntil") {
+		return nil
+	}
+	return tx.Migrator().AddColumn(&twoFactorLockUser{}, "TwoFactorLockedUntil")
+}
+
+func lockTwoFactorDown(tx *gorm.DB) error {
+	return tx.Migrator().DropColumn(&twoFactorLockUser{}, "TwoFactorLockedUntil")
+}
+
+type personalWorkspace struct {
+	ID             uint
+	PersonalUserID *uint `gorm:"uniqueIndex"`
+}
+
+func (personalWorkspace) TableName() string { return "workspaces" }
+
+// recordPersonalWorkspaceUsersUp links each personal workspace to the user
+// it was created for. A user who already got two keeps the oldest as their
+// personal workspace; the others stay as shared workspaces.
+func recordPersonalWorkspaceUsersUp(tx *gorm.DB) error {
+	migrator := tx.Migrator()
+	if !migrator.HasColumn(&personalWorkspace{}, "PersonalUserID") {
+		if err := migrator.AddColumn(&personalWorkspace{}, "PersonalUserID"); err != nil {
+			return err
+		}
+	}
+
+	var owners []struct {
+		WorkspaceID uint
+		UserID      uint
+	}
+	err := tx.Table("memberships").
+		Select("memberships.workspace_id, memberships.user_id").
+		Joins("JOIN workspaces ON workspaces.id = memberships.workspace_id").
+		Where("workspaces.personal = ? AND workspaces.personal_user_id IS NULL AND memberships.role = ?", true, model.RoleOwner).
+		Order("memberships.workspace_id").
+		Find(&owners).Error
+	if err != nil {
+		return err
+	}
+
+	var taken []uint
+	if err := tx.Table("workspaces").Where("personal_user_id IS NOT NULL").Pluck("personal_user_id", &taken).Error; err != nil {
+		return err
+	}
+	seen := map[uint]bool{}
+	for _, id := range taken {
+		seen[id] = true
+	}
+	for _, owner := range owners {
+		if seen[owner.UserID] {
+			continue
+		}
+		seen[owner.UserID] = true
+		if err := tx.Table("workspaces").Where("id = ?", owner.WorkspaceID).Update("personal_user_id", owner.UserID).Error; err != nil {
+			return err
+		}
+	}
+
+	if migrator.HasIndex(&personalWorkspace{}, "PersonalUserID") {
+		return nil
+	}
+	return migrator.CreateIndex(&personalWorkspace{}, "PersonalUserID")
+}
+
+func recordPersonalWorkspaceUsersDown(tx *gorm.DB) error {
+	if err := tx.Migrator().DropIndex(&personalWorkspace{}, "PersonalUserID"); err != nil {
+		return err
+	}
+	return tx.Migrator().DropColumn(&personalWorkspace{}, "PersonalUserID")
+}
+
+type pendingCrawlURL struct {
+	CrawlPending bool `gorm:"not null;default:false;index"`
+}
+
+func (pendingCrawlURL) TableName() string { return "urls" }
+
+// markPendingCrawlsUp adds the flag of crawls that have not finished.
+// URLs left running by the previous release were interrupted by its
+// shutdown or a crash, so their crawls are resumed; queued URLs cannot be
+// told apart from ones nobody started a crawl for, and are left alone.
+func markPendingCrawlsUp(tx *gorm.DB) error {
+	migrator := tx.Migrator()
+	if !migrator.HasColumn(&pendingCrawlURL{}, "CrawlPending") {
+		if err := migrator.AddColumn(&pendingCrawlURL{}, "CrawlPending"); err != nil {
+			return err
+		}
+	}
+	if !migrator.HasIndex(&pendingCrawlURL{}, "CrawlPending") {
+		if err := migrator.CreateIndex(&pendingCrawlURL{}, "CrawlPending"); err != nil {
+			return err
+		}
+	}
+	return tx.Table("urls").Where("status = ?", "running").Update("crawl_pending", true).Error
+}
+
+func markPendingCrawlsDown(tx *gorm.DB) error {
+	if err := tx.Migrator().DropIndex(&pendingCrawlURL{}, "CrawlPending"); err != nil {
+		return err
+	}
+	return tx.Migrator().DropColumn(&pendingCrawlURL{}, "CrawlPending")
+}
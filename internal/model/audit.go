@@ -0,0 +1,83 @@
+package model
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+var ErrAuditLogImmutable = errors.New("audit log is append-only")
+
+// AuditLog records one user action. Rows are never updated or deleted.
+type AuditLog struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	ActorID       *uint     `gorm:"index" json:"actor_id"`
+	ActorUsername string    `gorm:"type:varchar(255)" json:"actor_username"`
+	Action        string    `gorm:"type:varchar(64);index;not null" json:"action"`
+	Outcome       string    `gorm:"type:varchar(16);not null" json:"outcome"`
+	WorkspaceID   *uint     `gorm:"index" json:"workspace_id"`
+	TargetType    string    `gorm:"type:varchar(64)" json:"target_type"`
+	TargetIDs     IDList    `gorm:"type:text" json:"target_ids"`
+	Detail        string    `gorm:"type:text" json:"detail,omitempty"`
+	IP            string    `gorm:"type:varchar(64)" json:"ip"`
+	CreatedAt     time.Time `gorm:"index" json:"created_at"`
+}
+
+func (a *AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+func (a *AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+// IDList is stored as ",1,2,3," so a single ID can be matched with
+// LIKE '%,2,%' on every database.
+type IDList []uint
+
+func (l IDList) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return "", nil
+	}
+	parts := make([]string, len(l))
+	for i, id := range l {
+		parts[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return "," + strings.Join(parts, ",") + ",", nil
+}
+
+func (l *IDList) Scan(value interface{}) error {
+	var raw string
+	switch v := value.(type) {
+	case nil:
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into IDList", value)
+	}
+
+	*l = IDList{}
+	for _, part := range strings.Split(strings.Trim(raw, ","), ",") {
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return err
+		}
+		*l = append(*l, uint(id))
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// IdempotencyKey records a POST request made with an Idempotency-Key
+// header and, once it has finished, the response to replay for retries.
+type IdempotencyKey struct {
+	ID     uint   `gorm:"primaryKey"`
+	UserID uint   `gorm:"uniqueIndex:idx_idempotency_user_key;not null"`
+	Key    string `gorm:"column:idempotency_key;type:varchar(255);uniqueIndex:idx_idempotency_user_key;not null"`
+	// Fingerprint is a hash of the request; a key may only be reused for
+	// the same request.
+	Fingerprint string `gorm:"type:char(64);not null"`
+	// StatusCode is zero while the original request is still running.
+	StatusCode  int
+	ContentType string `gorm:"type:varchar(255)"`
+	Body        []byte
+	ExpiresAt   time.Time `gorm:"index"`
+	CreatedAt   time.Time
+}
@@ -0,0 +1,16 @@
+package model
+
+import (
+	"time"
+)
+
+// UserIdentity links a user to an external OIDC subject.
+type UserIdentity struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	Issuer    string    `gorm:"type:varchar(255);uniqueIndex:idx_identity_issuer_subject;not null" json:"issuer"`
+	Subject   string    `gorm:"type:varchar(255);uniqueIndex:idx_identity_issuer_subject;not null" json:"subject"`
+	Email     string    `gorm:"type:varchar(255)" json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
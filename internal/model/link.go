@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// Link is one anchor found on a crawled page. A URL's links are replaced
+// every time it is crawled.
+type Link struct {
+	ID         uint   `gorm:"primaryKey"`
+	URLID      uint   `gorm:"index;not null"`
+	Href       string `gorm:"type:text"`
+	Internal   bool
+	StatusCode int
+	Broken     bool
+	CreatedAt  time.Time
+}
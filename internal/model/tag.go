@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// Tag labels URLs within one workspace, e.g. by client or campaign.
+type Tag struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	WorkspaceID uint      `gorm:"uniqueIndex:idx_tags_workspace_name;not null" json:"workspace_id"`
+	Name        string    `gorm:"type:varchar(64);uniqueIndex:idx_tags_workspace_name;not null" json:"name"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -1,12 +1,22 @@
 package model
 
 import (
+	"net/url"
+	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type URL struct {
 	ID            uint `gorm:"primaryKey"`
+	WorkspaceID   uint `gorm:"index;uniqueIndex:idx_urls_workspace_hash"`
 	URL           string
+	NormalizedURL string `gorm:"type:text"`
+	// URLHash identifies NormalizedURL within the workspace. It is nil only
+	// for URLs stored before normalization that duplicate an older row.
+	URLHash       *string `gorm:"type:char(64);uniqueIndex:idx_urls_workspace_hash" json:"-"`
+	Host          string  `gorm:"type:varchar(255);index"`
 	HTMLVersion   string
 	PageTitle     string
 	Headings      string
@@ -15,6 +25,40 @@ type URL struct {
 	BrokenLinks   int
 	HasLoginForm  bool
 	Status        string
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
+	// CrawlPending is set while a crawl someone started has not finished.
+	// It survives a shutdown or crash, so those crawls can be resumed.
+	CrawlPending bool `gorm:"not null;default:false;index" json:"-"`
+	// ErrorClass and ErrorMessage say why the last crawl failed; both are
+	// empty unless Status is "error".
+	ErrorClass   string     `gorm:"type:varchar(32)"`
+	ErrorMessage string     `gorm:"type:text"`
+	Notes        string     `gorm:"type:text"`
+	Tags         []Tag      `gorm:"many2many:url_tags"`
+	CrawledAt    *time.Time `gorm:"index"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+
+	// Links holds the anchors found by the last crawl. It is filled in by
+	// the crawler and only loaded from the database on request.
+	Links []Link `gorm:"foreignKey:URLID" json:"-"`
+}
+
+// BeforeCreate records the host the URL points at, which statistics group
+// by.
+func (u *URL) BeforeCreate(tx *gorm.DB) error {
+	if u.Host == "" {
+		u.Host = HostOf(u.NormalizedURL, u.URL)
+	}
+	return nil
+}
+
+// HostOf returns the lower-cased host of the first candidate that parses.
+func HostOf(candidates ...string) string {
+	for _, raw := range candidates {
+		if parsed, err := url.Parse(raw); err == nil && parsed.Hostname() != "" {
+			return strings.ToLower(parsed.Hostname())
+		}
+	}
+	return ""
 }
@@ -5,9 +5,25 @@ import (
 )
 
 type User struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Username  string    `gorm:"type:varchar(255);uniqueIndex;not null" json:"username"`
-	Password  string    `gorm:"type:varchar(255);not null" json:"-"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                   uint       `gorm:"primaryKey" json:"id"`
+	Username             string     `gorm:"type:varchar(255);uniqueIndex;not null" json:"username"`
+	Password             string     `gorm:"type:varchar(255);not null" json:"-"`
+	IsAdmin              bool       `gorm:"not null;default:false" json:"is_admin"`
+	TOTPSecret           string     `gorm:"type:varchar(64)" json:"-"`
+	TOTPEnabled          bool       `gorm:"not null;default:false" json:"totp_enabled"`
+	TOTPLastStep         int64      `json:"-"`
+	TwoFactorFailures    int        `gorm:"not null;default:0" json:"-"`
+	TwoFactorLockedUntil *time.Time `json:"-"`
+	CreatedAt            time.Time  `json:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at"`
+}
+
+// RecoveryCode is a single-use 2FA fallback. Only a SHA-256 hash of the
+// code is stored.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	CodeHash  string     `gorm:"type:char(64);uniqueIndex;not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
 }
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"time"
+)
+
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+	RoleOwner  = "owner"
+)
+
+var roleRank = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+	RoleOwner:  4,
+}
+
+// ValidRole reports whether role is one of the workspace roles.
+func ValidRole(role string) bool {
+	return roleRank[role] > 0
+}
+
+// RoleAtLeast reports whether role grants at least the rights of min.
+// Viewers read, editors add and crawl URLs, admins delete URLs and manage
+// members, and owners may also appoint other owners.
+func RoleAtLeast(role, min string) bool {
+	return roleRank[role] >= roleRank[min] && roleRank[min] > 0
+}
+
+type Workspace struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	Name     string `gorm:"type:varchar(255);not null" json:"name"`
+	Personal bool   `gorm:"not null;default:false" json:"personal"`
+	// PersonalUserID is the user a personal workspace was created for. Its
+	// unique index keeps a user from getting two.
+	PersonalUserID *uint     `gorm:"uniqueIndex" json:"-"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type Membership struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	WorkspaceID uint       `gorm:"uniqueIndex:idx_membership_workspace_user;not null" json:"workspace_id"`
+	UserID      uint       `gorm:"uniqueIndex:idx_membership_workspace_user;index;not null" json:"user_id"`
+	Role        string     `gorm:"type:varchar(32);not null" json:"role"`
+	Workspace   *Workspace `json:"workspace,omitempty"`
+	User        *User      `json:"user,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
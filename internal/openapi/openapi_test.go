@@ -0,0 +1,46 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type child struct {
+	Name string `json:"name"`
+}
+
+type parent struct {
+	ID       uint       `json:"id"`
+	Role     string     `json:"role" validate:"required,oneof=viewer editor"`
+	Codes    []string   `json:"codes" validate:"required,min=1,dive,max=8"`
+	Seen     *time.Time `json:"seen"`
+	Child    *child     `json:"child,omitempty"`
+	Children []child    `json:"children"`
+	Secret   string     `json:"-"`
+	internal string
+}
+
+func TestSchemaOf(t *testing.T) {
+	doc := New(Info{Title: "Test", Version: "1"})
+
+	ref := doc.SchemaOf(parent{})
+	assert.Equal(t, "#/components/schemas/parent", ref.Ref)
+
+	out, err := json.Marshal(doc.Components.Schemas)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"parent": {"type": "object", "required": ["role", "codes"], "properties": {
+			"id": {"type": "integer", "minimum": 0},
+			"role": {"type": "string", "enum": ["viewer", "editor"]},
+			"codes": {"type": "array", "minItems": 1, "items": {"type": "string", "maxLength": 8}},
+			"seen": {"type": "string", "format": "date-time", "nullable": true},
+			"child": {"$ref": "#/components/schemas/child"},
+			"children": {"type": "array", "items": {"$ref": "#/components/schemas/child"}}
+		}},
+		"child": {"type": "object", "properties": {"name": {"type": "string"}}}
+	}`, string(out))
+}
@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+)
+
+// Concurrency caps the number of tasks running at once for each key.
+type Concurrency struct {
+	limit int
+
+	mu    sync.Mutex
+	slots map[string]*slots
+}
+
+type slots struct {
+	sem     chan struct{}
+	waiting int
+}
+
+// NewConcurrency allows limit tasks per key; zero or less means no cap.
+func NewConcurrency(limit int) *Concurrency {
+	return &Concurrency{limit: limit, slots: map[string]*slots{}}
+}
+
+func (c *Concurrency) Limit() int {
+	return c.limit
+}
+
+// TryAcquire takes a slot for key if one is free. The returned function
+// gives it back.
+func (c *Concurrency) TryAcquire(key string) (func(), bool) {
+	if c.limit <= 0 {
+		return func() {}, true
+	}
+
+	s := c.hold(key)
+	select {
+	case s.sem <- struct{}{}:
+		return c.releaser(key, s), true
+	default:
+		c.drop(key, s)
+		return nil, false
+	}
+}
+
+// Acquire waits for a slot for key until ctx is done.
+func (c *Concurrency) Acquire(ctx context.Context, key string) (func(), error) {
+	if c.limit <= 0 {
+		return func() {}, nil
+	}
+
+	s := c.hold(key)
+	select {
+	case s.sem <- struct{}{}:
+		return c.releaser(key, s), nil
+	case <-ctx.Done():
+		c.drop(key, s)
+		return nil, ctx.Err()
+	}
+}
+
+// Active returns the number of slots in use for key.
+func (c *Concurrency) Active(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.slots[key]; ok {
+		return len(s.sem)
+	}
+	return 0
+}
+
+// hold registers interest in key's slots so they are not dropped while a
+// caller waits for or holds one.
+func (c *Concurrency) hold(key string) *slots {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.slots[key]
+	if !ok {
+		s = &slots{sem: make(chan struct{}, c.limit)}
+		c.slots[key] = s
+	}
+	s.waiting++
+	return s
+}
+
+func (c *Concurrency) drop(key string, s *slots) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s.waiting--
+	if s.waiting == 0 {
+		delete(c.slots, key)
+	}
+}
+
+func (c *Concurrency) releaser(key string, s *slots) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-s.sem
+			c.drop(key, s)
+		})
+	}
+}
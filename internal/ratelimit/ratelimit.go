@@ -0,0 +1,171 @@
+// Package ratelimit provides token bucket rate limiting and a per-key cap
+// on concurrent work.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit allows Requests per Period, with bursts of up to Requests.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// Unlimited reports whether the limit lets every request through.
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+// ParseLimit parses "60/m", "5/s", "1000/h" or "0" for no limit.
+func ParseLimit(raw string) (Limit, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "0" {
+		return Limit{}, nil
+	}
+
+	count, unit, ok := strings.Cut(raw, "/")
+	n, err := strconv.Atoi(strings.TrimSpace(count))
+	if !ok || err != nil || n < 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q, want e.g. 60/m", raw)
+	}
+
+	periods := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}
+	period, ok := periods[strings.TrimSpace(unit)]
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q, the period must be s, m or h", raw)
+	}
+	return Limit{Requests: n, Period: period}, nil
+}
+
+// Config holds the default limit and per-route overrides keyed by
+// "METHOD /path", using the route's registered path.
+type Config struct {
+	Default Limit
+	Routes  map[string]Limit
+}
+
+// For returns the limit for a route and the bucket it draws from. Routes
+// without an override share the default bucket.
+func (c Config) For(route string) (Limit, string) {
+	if l, ok := c.Routes[route]; ok {
+		return l, route
+	}
+	return c.Default, "default"
+}
+
+// ParseRoutes parses a comma-separated list of route=limit pairs, for
+// example "POST /api/urls/crawl=10/m,POST /login=5/m".
+func ParseRoutes(raw string) (map[string]Limit, error) {
+	routes := map[string]Limit{}
+	for _, pair := range strings.Split(raw, ",") {
+		route, limit, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not route=limit", pair)
+		}
+		l, err := ParseLimit(limit)
+		if err != nil {
+			return nil, err
+		}
+		routes[NormalizeRoute(route)] = l
+	}
+	return routes, nil
+}
+
+// NormalizeRoute collapses the whitespace in "METHOD /path".
+func NormalizeRoute(route string) string {
+	return strings.Join(strings.Fields(route), " ")
+}
+
+// Result describes a bucket after a request was counted against it.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed; zero when
+	// this one was.
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	period time.Duration
+}
+
+// sweepInterval is how often idle buckets are dropped.
+const sweepInterval = time.Minute
+
+// Limiter keeps a token bucket per key. Buckets refill continuously, so a
+// limit of 60/m allows a burst of 60 requests and then one a second.
+type Limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+// Allow takes a token from key's bucket under limit l.
+func (lim *Limiter) Allow(key string, l Limit) Result {
+	if l.Unlimited() {
+		return Result{Allowed: true}
+	}
+
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	now := lim.now()
+	lim.sweep(now)
+
+	capacity := float64(l.Requests)
+	perToken := l.Period / time.Duration(l.Requests)
+
+	b, ok := lim.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now, period: l.Period}
+		lim.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+float64(now.Sub(b.last))/float64(perToken))
+	b.last = now
+	b.period = l.Period
+
+	res := Result{Limit: l.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - b.tokens) * float64(perToken))
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = time.Duration((capacity - b.tokens) * float64(perToken))
+	return res
+}
+
+// sweep drops buckets that have been idle long enough to be full again,
+// which is the same as having no bucket.
+func (lim *Limiter) sweep(now time.Time) {
+	if now.Sub(lim.lastSweep) < sweepInterval {
+		return
+	}
+	lim.lastSweep = now
+	for key, b := range lim.buckets {
+		if now.Sub(b.last) > b.period {
+			delete(lim.buckets, key)
+		}
+	}
+}
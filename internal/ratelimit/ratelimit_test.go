@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLimit(t *testing.T) {
+	l, err := ParseLimit("60/m")
+	require.NoError(t, err)
+	assert.Equal(t, Limit{Requests: 60, Period: time.Minute}, l)
+
+	l, err = ParseLimit("0")
+	require.NoError(t, err)
+	assert.True(t, l.Unlimited())
+
+	for _, bad := range []string{"", "60", "x/m", "60/d", "-1/s"} {
+		_, err := ParseLimit(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := ParseRoutes("POST  /api/urls/crawl=5/m, GET /api/stats=0")
+	require.NoError(t, err)
+
+	cfg := Config{Default: Limit{Requests: 100, Period: time.Minute}, Routes: routes}
+	l, bucket := cfg.For("POST /api/urls/crawl")
+	assert.Equal(t, Limit{Requests: 5, Period: time.Minute}, l)
+	assert.Equal(t, "POST /api/urls/crawl", bucket)
+	l, _ = cfg.For("GET /api/stats")
+	assert.True(t, l.Unlimited())
+	l, bucket = cfg.For("GET /api/urls")
+	assert.Equal(t, 100, l.Requests)
+	assert.Equal(t, "default", bucket)
+
+	_, err = ParseRoutes("POST /login")
+	assert.Error(t, err)
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Now()
+	lim := NewLimiter()
+	lim.now = func() time.Time { return now }
+	l := Limit{Requests: 3, Period: 3 * time.Second}
+
+	for i := 2; i >= 0; i-- {
+		res := lim.Allow("a", l)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, i, res.Remaining)
+	}
+
+	res := lim.Allow("a", l)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, time.Second, res.RetryAfter)
+	assert.Equal(t, 3*time.Second, res.Reset)
+	assert.True(t, lim.Allow("b", l).Allowed, "keys have separate buckets")
+
+	now = now.Add(time.Second)
+	assert.True(t, lim.Allow("a", l).Allowed)
+	assert.False(t, lim.Allow("a", l).Allowed)
+}
+
+func TestConcurrency(t *testing.T) {
+	c := NewConcurrency(2)
+
+	release1, ok := c.TryAcquire("u")
+	require.True(t, ok)
+	_, ok = c.TryAcquire("u")
+	require.True(t, ok)
+	_, ok = c.TryAcquire("u")
+	assert.False(t, ok)
+	assert.Equal(t, 2, c.Active("u"))
+
+	_, ok = c.TryAcquire("other")
+	assert.True(t, ok)
+
+	acquired := make(chan func())
+	go func() {
+		release, err := c.Acquire(context.Background(), "u")
+		assert.NoError(t, err)
+		acquired <- release
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired a slot over the limit")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	release1()
+	release1()
+	(<-acquired)()
+	assert.Equal(t, 1, c.Active("u"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.TryAcquire("u")
+	_, err := c.Acquire(ctx, "u")
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,144 @@
+// Package stats computes dashboard statistics for a workspace's URLs. All
+// aggregation happens in the database.
+package stats
+
+import (
+	"time"
+
+	"url-crawler-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// Options bound the time series and top-N parts of the statistics.
+type Options struct {
+	// Days is the number of days, ending today, covered by CrawlsPerDay.
+	Days int
+	// TopDomains is the number of domains returned.
+	TopDomains int
+	// Now is the end of the window; it defaults to the current time.
+	Now time.Time
+}
+
+type Stats struct {
+	Total        int64            `json:"total"`
+	ByStatus     map[string]int64 `json:"by_status"`
+	HTMLVersions map[string]int64 `json:"html_versions"`
+	BrokenLinks  BrokenLinks      `json:"broken_links"`
+	LoginForms   int64            `json:"login_forms"`
+	CrawlsPerDay []DayCount       `json:"crawls_per_day"`
+	TopDomains   []DomainCount    `json:"top_domains"`
+}
+
+type BrokenLinks struct {
+	Total int64 `json:"total"`
+	// Average is per crawled URL.
+	Average float64 `json:"average"`
+	// URLs is the number of URLs with at least one broken link.
+	URLs int64 `json:"urls"`
+}
+
+type DayCount struct {
+	Date  string `json:"date"`
+	Count int64  `json:"count"`
+}
+
+type DomainCount struct {
+	Domain string `json:"domain"`
+	Count  int64  `json:"count"`
+}
+
+type groupCount struct {
+	Bucket string
+	Total  int64
+}
+
+// Compute returns the statistics for the workspace's URLs, excluding the
+// trash. Link and login form figures only count URLs that have been
+// crawled successfully. CrawlsPerDay counts URLs by the day their most
+// recent crawl finished, with a zero entry for days without any.
+func Compute(conn *gorm.DB, workspaceID uint, opts Options) (*Stats, error) {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	urls := func() *gorm.DB {
+		return conn.Model(&model.URL{}).Where("workspace_id = ?", workspaceID)
+	}
+
+	s := &Stats{
+		ByStatus:     map[string]int64{},
+		HTMLVersions: map[string]int64{},
+		CrawlsPerDay: []DayCount{},
+		TopDomains:   []DomainCount{},
+	}
+
+	var byStatus []groupCount
+	if err := urls().Select("status AS bucket, COUNT(*) AS total").Group("status").Scan(&byStatus).Error; err != nil {
+		return nil, err
+	}
+	for _, g := range byStatus {
+		s.ByStatus[g.Bucket] = g.Total
+		s.Total += g.Total
+	}
+
+	var versions []groupCount
+	if err := urls().Where("status = ? AND html_version <> ''", "done").
+		Select("html_version AS bucket, COUNT(*) AS total").Group("html_version").Scan(&versions).Error; err != nil {
+		return nil, err
+	}
+	for _, g := range versions {
+		s.HTMLVersions[g.Bucket] = g.Total
+	}
+
+	var totals struct {
+		Broken     int64
+		Average    float64
+		BrokenURLs int64
+		LoginForms int64
+	}
+	if err := urls().Where("status = ?", "done").Select(
+		"COALESCE(SUM(broken_links), 0) AS broken, " +
+			"COALESCE(AVG(broken_links), 0) AS average, " +
+			"COALESCE(SUM(CASE WHEN broken_links > 0 THEN 1 ELSE 0 END), 0) AS broken_urls, " +
+			"COALESCE(SUM(CASE WHEN has_login_form THEN 1 ELSE 0 END), 0) AS login_forms",
+	).Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+	s.BrokenLinks = BrokenLinks{Total: totals.Broken, Average: totals.Average, URLs: totals.BrokenURLs}
+	s.LoginForms = totals.LoginForms
+
+	if opts.Days > 0 {
+		first := opts.Now.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-opts.Days)
+		var days []groupCount
+		if err := urls().Where("crawled_at >= ?", first).
+			Select("DATE(crawled_at) AS bucket, COUNT(*) AS total").Group("DATE(crawled_at)").Scan(&days).Error; err != nil {
+			return nil, err
+		}
+		counts := make(map[string]int64, len(days))
+		for _, g := range days {
+			// Drivers return DATE() as "2006-01-02" or as a timestamp at
+			// midnight, depending on the database.
+			if len(g.Bucket) >= 10 {
+				counts[g.Bucket[:10]] += g.Total
+			}
+		}
+		for d := 0; d < opts.Days; d++ {
+			date := first.AddDate(0, 0, d).Format("2006-01-02")
+			s.CrawlsPerDay = append(s.CrawlsPerDay, DayCount{Date: date, Count: counts[date]})
+		}
+	}
+
+	if opts.TopDomains > 0 {
+		var domains []groupCount
+		if err := urls().Where("host <> ''").
+			Select("host AS bucket, COUNT(*) AS total").Group("host").
+			Order("total DESC, host").Limit(opts.TopDomains).Scan(&domains).Error; err != nil {
+			return nil, err
+		}
+		for _, g := range domains {
+			s.TopDomains = append(s.TopDomains, DomainCount{Domain: g.Bucket, Count: g.Total})
+		}
+	}
+
+	return s, nil
+}
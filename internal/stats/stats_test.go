@@ -0,0 +1,69 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"url-crawler-backend/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestCompute(t *testing.T) {
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	conn.AutoMigrate(&model.URL{})
+
+	now := time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC)
+	at := func(daysAgo int) *time.Time {
+		t := now.AddDate(0, 0, -daysAgo)
+		return &t
+	}
+
+	urls := []model.URL{
+		{WorkspaceID: 1, URL: "https://a.example/1", Status: "done", HTMLVersion: "HTML5", BrokenLinks: 3, HasLoginForm: true, CrawledAt: at(0)},
+		{WorkspaceID: 1, URL: "https://a.example/2", Status: "done", HTMLVersion: "HTML5", BrokenLinks: 0, CrawledAt: at(0)},
+		{WorkspaceID: 1, URL: "https://B.example/", Status: "done", HTMLVersion: "Older HTML", BrokenLinks: 1, CrawledAt: at(2)},
+		{WorkspaceID: 1, URL: "https://c.example/", Status: "error", CrawledAt: at(10)},
+		{WorkspaceID: 1, URL: "https://c.example/queued", Status: "queued"},
+		{WorkspaceID: 2, URL: "https://other.example/", Status: "done", HTMLVersion: "HTML5", BrokenLinks: 50, CrawledAt: at(0)},
+		{WorkspaceID: 1, URL: "https://trashed.example/", Status: "done", DeletedAt: gorm.DeletedAt{Time: now, Valid: true}},
+	}
+	require.NoError(t, conn.Create(&urls).Error)
+
+	s, err := Compute(conn, 1, Options{Days: 3, TopDomains: 2, Now: now})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(5), s.Total)
+	assert.Equal(t, map[string]int64{"done": 3, "error": 1, "queued": 1}, s.ByStatus)
+	assert.Equal(t, map[string]int64{"HTML5": 2, "Older HTML": 1}, s.HTMLVersions)
+	assert.Equal(t, int64(4), s.BrokenLinks.Total)
+	assert.InDelta(t, 4.0/3, s.BrokenLinks.Average, 0.0001)
+	assert.Equal(t, int64(2), s.BrokenLinks.URLs)
+	assert.Equal(t, int64(1), s.LoginForms)
+	assert.Equal(t, []DayCount{
+		{Date: "2026-03-08", Count: 1},
+		{Date: "2026-03-09", Count: 0},
+		{Date: "2026-03-10", Count: 2},
+	}, s.CrawlsPerDay)
+	assert.Equal(t, []DomainCount{
+		{Domain: "a.example", Count: 2},
+		{Domain: "c.example", Count: 2},
+	}, s.TopDomains)
+}
+
+func TestComputeEmptyWorkspace(t *testing.T) {
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	conn.AutoMigrate(&model.URL{})
+
+	s, err := Compute(conn, 1, Options{Days: 2, TopDomains: 5})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), s.Total)
+	assert.Zero(t, s.BrokenLinks.Average)
+	assert.Len(t, s.CrawlsPerDay, 2)
+	assert.Empty(t, s.TopDomains)
+}
@@ -0,0 +1,26 @@
+package store
+
+import (
+	"context"
+
+	"url-crawler-backend/internal/audit"
+	"url-crawler-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type GormAuditStore struct {
+	db *gorm.DB
+}
+
+func NewGormAuditStore(conn *gorm.DB) *GormAuditStore {
+	return &GormAuditStore{db: conn}
+}
+
+func (s *GormAuditStore) Record(ctx context.Context, e audit.Entry) error {
+	return audit.Record(s.db.WithContext(ctx), e)
+}
+
+func (s *GormAuditStore) Query(ctx context.Context, f audit.Filter) ([]model.AuditLog, int64, error) {
+	return audit.Query(s.db.WithContext(ctx), f)
+}
@@ -0,0 +1,304 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"url-crawler-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// crawlResultColumns are the columns a finished crawl writes. Saving only
+// these keeps a crawl from undoing changes made while it ran, such as the
+// URL being moved to the trash.
+var crawlResultColumns = []string{
+	"html_version", "page_title", "headings", "internal_links", "external_links",
+	"broken_links", "has_login_form", "status", "error_class", "error_message",
+	"crawl_pending", "crawled_at", "updated_at",
+}
+
+const (
+	// hashLookupBatch caps the hashes FindByHashes puts in one query.
+	hashLookupBatch = 500
+	// eachBatchSize is the number of URLs Each loads at a time.
+	eachBatchSize = 500
+)
+
+type GormURLStore struct {
+	db *gorm.DB
+}
+
+func NewGormURLStore(conn *gorm.DB) *GormURLStore {
+	return &GormURLStore{db: conn}
+}
+
+func (s *GormURLStore) Create(ctx context.Context, u *model.URL, tags []string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ensured, err := ensureTags(tx, u.WorkspaceID, tags)
+		if err != nil {
+			return err
+		}
+		u.Tags = ensured
+		return tx.Create(u).Error
+	})
+}
+
+func (s *GormURLStore) Get(ctx context.Context, workspaceID, id uint) (model.URL, error) {
+	var u model.URL
+	err := s.db.WithContext(ctx).Where("workspace_id = ?", workspaceID).First(&u, id).Error
+	return u, notFound(err)
+}
+
+func (s *GormURLStore) FindByHash(ctx context.Context, workspaceID uint, hash string) (model.URL, error) {
+	var u model.URL
+	err := s.db.WithContext(ctx).Unscoped().Where("workspace_id = ? AND url_hash = ?", workspaceID, hash).First(&u).Error
+	return u, notFound(err)
+}
+
+func (s *GormURLStore) List(ctx context.Context, filter URLFilter) ([]model.URL, error) {
+	var urls []model.URL
+	err := filterURLs(s.db.WithContext(ctx), filter).Preload("Tags").Order("id").Find(&urls).Error
+	return urls, err
+}
+
+func (s *GormURLStore) IDs(ctx context.Context, filter URLFilter) ([]uint, error) {
+	var ids []uint
+	err := filterURLs(s.db.WithContext(ctx), filter).Order("id").Pluck("id", &ids).Error
+	return ids, err
+}
+
+func (s *GormURLStore) Interrupted(ctx context.Context) ([]model.URL, error) {
+	var urls []model.URL
+	err := s.db.WithContext(ctx).Where("crawl_pending = ?", true).Order("id").Find(&urls).Error
+	return urls, err
+}
+
+func (s *GormURLStore) BeginCrawl(ctx context.Context, id uint, status string) error {
+	return s.db.WithContext(ctx).Model(&model.URL{ID: id}).Updates(map[string]interface{}{
+		"status":        status,
+		"crawl_pending": true,
+	}).Error
+}
+
+func (s *GormURLStore) SetStatus(ctx context.Context, id uint, status string) error {
+	return s.db.WithContext(ctx).Model(&model.URL{ID: id}).Update("status", status).Error
+}
+
+func (s *GormURLStore) SaveCrawlResult(ctx context.Context, u *model.URL) error {
+	u.CrawlPending = false
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(u).Select(crawlResultColumns).Updates(u)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		if err := tx.Where("url_id = ?", u.ID).Delete(&model.Link{}).Error; err != nil {
+			return err
+		}
+		if len(u.Links) == 0 {
+			return nil
+		}
+		for i := range u.Links {
+			u.Links[i].ID = 0
+			u.Links[i].URLID = u.ID
+		}
+		return tx.CreateInBatches(u.Links, 200).Error
+	})
+}
+
+func (s *GormURLStore) Update(ctx context.Context, workspaceID, id uint, notes *string, tags *[]string) (model.URL, error) {
+	var u model.URL
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("workspace_id = ?", workspaceID).First(&u, id).Error; err != nil {
+			return err
+		}
+		if notes != nil {
+			if err := tx.Model(&u).Update("notes", *notes).Error; err != nil {
+				return err
+			}
+		}
+		if tags != nil {
+			ensured, err := ensureTags(tx, workspaceID, *tags)
+			if err != nil {
+				return err
+			}
+			if err := tx.Exec("DELETE FROM url_tags WHERE url_id = ?", u.ID).Error; err != nil {
+				return err
+			}
+			if err := addURLTags(tx, []uint{u.ID}, ensured); err != nil {
+				return err
+			}
+		}
+		return tx.Preload("Tags").First(&u, u.ID).Error
+	})
+	return u, notFound(err)
+}
+
+func (s *GormURLStore) Delete(ctx context.Context, workspaceID uint, ids []uint) error {
+	return s.db.WithContext(ctx).Where("workspace_id = ?", workspaceID).Delete(&model.URL{}, ids).Error
+}
+
+func (s *GormURLStore) Trash(ctx context.Context, workspaceID uint) ([]model.URL, error) {
+	var urls []model.URL
+	err := s.db.WithContext(ctx).Unscoped().
+		Where("workspace_id = ? AND deleted_at IS NOT NULL", workspaceID).
+		Order("deleted_at DESC").
+		Find(&urls).Error
+	return urls, err
+}
+
+func (s *GormURLStore) Restore(ctx context.Context, workspaceID uint, ids []uint) ([]uint, error) {
+	var trashed []uint
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&model.URL{}).
+			Where("workspace_id = ? AND deleted_at IS NOT NULL AND id IN ?", workspaceID, ids).
+			Pluck("id", &trashed).Error; err != nil {
+			return err
+		}
+		if len(trashed) == 0 {
+			return nil
+		}
+		return tx.Unscoped().Model(&model.URL{}).Where("id IN ?", trashed).Update("deleted_at", nil).Error
+	})
+	return trashed, err
+}
+
+func (s *GormURLStore) FindByHashes(ctx context.Context, workspaceID uint, hashes []string) (map[string]model.URL, error) {
+	found := map[string]model.URL{}
+	for start := 0; start < len(hashes); start += hashLookupBatch {
+		end := min(start+hashLookupBatch, len(hashes))
+		var urls []model.URL
+		if err := s.db.WithContext(ctx).Unscoped().
+			Where("workspace_id = ? AND url_hash IN ?", workspaceID, hashes[start:end]).
+			Find(&urls).Error; err != nil {
+			return nil, err
+		}
+		for _, u := range urls {
+			found[*u.URLHash] = u
+		}
+	}
+	return found, nil
+}
+
+func (s *GormURLStore) CreateAll(ctx context.Context, urls []model.URL, tags [][]string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ensured := map[uint]map[string]model.Tag{}
+		for i := range urls {
+			workspaceID := urls[i].WorkspaceID
+			if ensured[workspaceID] == nil {
+				ensured[workspaceID] = map[string]model.Tag{}
+			}
+			urls[i].Tags = nil
+			for _, name := range tags[i] {
+				tag, ok := ensured[workspaceID][name]
+				if !ok {
+					created, err := ensureTags(tx, workspaceID, []string{name})
+					if err != nil {
+						return err
+					}
+					tag = created[0]
+					ensured[workspaceID][name] = tag
+				}
+				urls[i].Tags = append(urls[i].Tags, tag)
+			}
+		}
+		return tx.CreateInBatches(&urls, 100).Error
+	})
+}
+
+func (s *GormURLStore) Each(ctx context.Context, filter URLFilter, withLinks bool, fn func([]model.URL) error) error {
+	query := filterURLs(s.db.WithContext(ctx), filter).Preload("Tags")
+	if withLinks {
+		query = query.Preload("Links", func(tx *gorm.DB) *gorm.DB {
+			return tx.Order("id")
+		})
+	}
+
+	var batch []model.URL
+	return query.FindInBatches(&batch, eachBatchSize, func(*gorm.DB, int) error {
+		return fn(batch)
+	}).Error
+}
+
+// filterURLs narrows conn to the URLs matching filter.
+func filterURLs(conn *gorm.DB, filter URLFilter) *gorm.DB {
+	query := conn.Model(&model.URL{}).Where("workspace_id = ?", filter.WorkspaceID)
+
+	if len(filter.IDs) > 0 {
+		query = query.Where("id IN ?", filter.IDs)
+	}
+
+	if len(filter.Tags) > 0 {
+		query = query.Where(
+			"id IN (SELECT url_tags.url_id FROM url_tags JOIN tags ON tags.id = url_tags.tag_id WHERE tags.workspace_id = ? AND tags.name IN ?)",
+			filter.WorkspaceID, filter.Tags,
+		)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
+	}
+	if filter.Query != "" {
+		// LIKE is case-sensitive on PostgreSQL only; lower both sides so
+		// search behaves the same on every database.
+		pattern := "%" + strings.ToLower(filter.Query) + "%"
+		query = query.Where("LOWER(url) LIKE ? OR LOWER(page_title) LIKE ?", pattern, pattern)
+	}
+	if filter.HasBrokenLinks != nil {
+		if *filter.HasBrokenLinks {
+			query = query.Where("broken_links > 0")
+		} else {
+			query = query.Where("broken_links = 0")
+		}
+	}
+	if filter.HasLoginForm != nil {
+		query = query.Where("has_login_form = ?", *filter.HasLoginForm)
+	}
+	return query
+}
+
+// ensureTags returns the workspace's tags with the given names, creating
+// the missing ones.
+func ensureTags(tx *gorm.DB, workspaceID uint, names []string) ([]model.Tag, error) {
+	tags := make([]model.Tag, 0, len(names))
+	for _, name := range names {
+		tag := model.Tag{WorkspaceID: workspaceID, Name: name}
+		if err := tx.Where(&tag).FirstOrCreate(&tag).Error; err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+type GormUserStore struct {
+	db *gorm.DB
+}
+
+func NewGormUserStore(conn *gorm.DB) *GormUserStore {
+	return &GormUserStore{db: conn}
+}
+
+func (s *GormUserStore) Get(ctx context.Context, id uint) (model.User, error) {
+	var u model.User
+	err := s.db.WithContext(ctx).First(&u, id).Error
+	return u, notFound(err)
+}
+
+func (s *GormUserStore) ByUsername(ctx context.Context, username string) (model.User, error) {
+	var u model.User
+	err := s.db.WithContext(ctx).Where("username = ?", username).First(&u).Error
+	return u, notFound(err)
+}
+
+func (s *GormUserStore) Create(ctx context.Context, u *model.User) error {
+	return s.db.WithContext(ctx).Create(u).Error
+}
+
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,29 @@
+package store
+
+import (
+	"context"
+
+	"url-crawler-backend/internal/migrate"
+
+	"gorm.io/gorm"
+)
+
+type GormHealth struct {
+	db *gorm.DB
+}
+
+func NewGormHealth(conn *gorm.DB) *GormHealth {
+	return &GormHealth{db: conn}
+}
+
+func (s *GormHealth) Ping(ctx context.Context) error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func (s *GormHealth) CheckMigrations(ctx context.Context) error {
+	return migrate.Check(s.db.WithContext(ctx))
+}
@@ -0,0 +1,31 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"url-crawler-backend/internal/idempotency"
+	"url-crawler-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type GormIdempotencyStore struct {
+	db *gorm.DB
+}
+
+func NewGormIdempotencyStore(conn *gorm.DB) *GormIdempotencyStore {
+	return &GormIdempotencyStore{db: conn}
+}
+
+func (s *GormIdempotencyStore) Acquire(ctx context.Context, userID uint, key, fingerprint string, ttl time.Duration) (*model.IdempotencyKey, error) {
+	return idempotency.Acquire(ctx, s.db, userID, key, fingerprint, ttl)
+}
+
+func (s *GormIdempotencyStore) Complete(ctx context.Context, record *model.IdempotencyKey, status int, contentType string, body []byte) error {
+	return idempotency.Complete(s.db.WithContext(ctx), record, status, contentType, body)
+}
+
+func (s *GormIdempotencyStore) Release(ctx context.Context, record *model.IdempotencyKey) error {
+	return idempotency.Release(s.db.WithContext(ctx), record)
+}
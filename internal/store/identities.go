@@ -0,0 +1,35 @@
+package store
+
+import (
+	"context"
+
+	"url-crawler-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type GormIdentityStore struct {
+	db *gorm.DB
+}
+
+func NewGormIdentityStore(conn *gorm.DB) *GormIdentityStore {
+	return &GormIdentityStore{db: conn}
+}
+
+func (s *GormIdentityStore) User(ctx context.Context, issuer, subject string) (model.User, error) {
+	var user model.User
+	err := s.db.WithContext(ctx).
+		Where("id = (SELECT user_id FROM user_identities WHERE issuer = ? AND subject = ?)", issuer, subject).
+		First(&user).Error
+	return user, notFound(err)
+}
+
+func (s *GormIdentityStore) Provision(ctx context.Context, u *model.User, identity model.UserIdentity) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(u).Error; err != nil {
+			return err
+		}
+		identity.UserID = u.ID
+		return tx.Create(&identity).Error
+	})
+}
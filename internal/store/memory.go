@@ -0,0 +1,422 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"url-crawler-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+var errDuplicate = errors.New("duplicate record")
+
+// MemoryURLStore keeps URLs in memory. It behaves like GormURLStore,
+// including the per-workspace uniqueness of normalized URLs.
+type MemoryURLStore struct {
+	mu     sync.Mutex
+	urls   map[uint]*model.URL
+	tags   map[uint]map[string]model.Tag
+	nextID uint
+}
+
+func NewMemoryURLStore() *MemoryURLStore {
+	return &MemoryURLStore{urls: map[uint]*model.URL{}, tags: map[uint]map[string]model.Tag{}}
+}
+
+func (s *MemoryURLStore) Create(_ context.Context, u *model.URL, tags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.exists(u) {
+		return errDuplicate
+	}
+	s.create(u, tags)
+	return nil
+}
+
+// exists reports whether the workspace of u has a URL with its hash.
+func (s *MemoryURLStore) exists(u *model.URL) bool {
+	if u.URLHash == nil {
+		return false
+	}
+	for _, existing := range s.urls {
+		if existing.WorkspaceID == u.WorkspaceID && existing.URLHash != nil && *existing.URLHash == *u.URLHash {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryURLStore) create(u *model.URL, tags []string) {
+	u.Tags = make([]model.Tag, 0, len(tags))
+	for _, name := range tags {
+		u.Tags = append(u.Tags, s.ensureTag(u.WorkspaceID, name))
+	}
+
+	s.nextID++
+	now := time.Now()
+	u.ID = s.nextID
+	u.CreatedAt, u.UpdatedAt = now, now
+	if u.Host == "" {
+		u.Host = model.HostOf(u.NormalizedURL, u.URL)
+	}
+	stored := *u
+	s.urls[u.ID] = &stored
+}
+
+func (s *MemoryURLStore) ensureTag(workspaceID uint, name string) model.Tag {
+	if s.tags[workspaceID] == nil {
+		s.tags[workspaceID] = map[string]model.Tag{}
+	}
+	tag, ok := s.tags[workspaceID][name]
+	if !ok {
+		s.nextID++
+		now := time.Now()
+		tag = model.Tag{ID: s.nextID, WorkspaceID: workspaceID, Name: name, CreatedAt: now, UpdatedAt: now}
+		s.tags[workspaceID][name] = tag
+	}
+	return tag
+}
+
+func (s *MemoryURLStore) Get(_ context.Context, workspaceID, id uint) (model.URL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.urls[id]
+	if !ok || u.WorkspaceID != workspaceID || u.DeletedAt.Valid {
+		return model.URL{}, ErrNotFound
+	}
+	return copyURL(u), nil
+}
+
+func (s *MemoryURLStore) FindByHash(_ context.Context, workspaceID uint, hash string) (model.URL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.sorted() {
+		if u.WorkspaceID == workspaceID && u.URLHash != nil && *u.URLHash == hash {
+			return copyURL(u), nil
+		}
+	}
+	return model.URL{}, ErrNotFound
+}
+
+func (s *MemoryURLStore) List(_ context.Context, filter URLFilter) ([]model.URL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	urls := []model.URL{}
+	for _, u := range s.sorted() {
+		if !u.DeletedAt.Valid && matches(u, filter) {
+			c := copyURL(u)
+			c.Links = nil
+			urls = append(urls, c)
+		}
+	}
+	return urls, nil
+}
+
+func (s *MemoryURLStore) IDs(_ context.Context, filter URLFilter) ([]uint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := []uint{}
+	for _, u := range s.sorted() {
+		if !u.DeletedAt.Valid && matches(u, filter) {
+			ids = append(ids, u.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (s *MemoryURLStore) Interrupted(_ context.Context) ([]model.URL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	urls := []model.URL{}
+	for _, u := range s.sorted() {
+		if !u.DeletedAt.Valid && u.CrawlPending {
+			c := copyURL(u)
+			c.Links = nil
+			urls = append(urls, c)
+		}
+	}
+	return urls, nil
+}
+
+func (s *MemoryURLStore) BeginCrawl(_ context.Context, id uint, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.urls[id]; ok {
+		u.Status = status
+		u.CrawlPending = true
+		u.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+func (s *MemoryURLStore) SetStatus(_ context.Context, id uint, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.urls[id]; ok {
+		u.Status = status
+		u.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+func (s *MemoryURLStore) SaveCrawlResult(_ context.Context, u *model.URL) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.urls[u.ID]
+	if !ok || stored.DeletedAt.Valid {
+		return nil
+	}
+	stored.HTMLVersion = u.HTMLVersion
+	stored.PageTitle = u.PageTitle
+	stored.Headings = u.Headings
+	stored.InternalLinks = u.InternalLinks
+	stored.ExternalLinks = u.ExternalLinks
+	stored.BrokenLinks = u.BrokenLinks
+	stored.HasLoginForm = u.HasLoginForm
+	stored.Status = u.Status
+	stored.CrawlPending = false
+	stored.ErrorClass = u.ErrorClass
+	stored.ErrorMessage = u.ErrorMessage
+	stored.CrawledAt = u.CrawledAt
+	stored.UpdatedAt = u.UpdatedAt
+
+	stored.Links = make([]model.Link, len(u.Links))
+	for i, link := range u.Links {
+		s.nextID++
+		link.ID = s.nextID
+		link.URLID = u.ID
+		stored.Links[i] = link
+		u.Links[i] = link
+	}
+	return nil
+}
+
+func (s *MemoryURLStore) Delete(_ context.Context, workspaceID uint, ids []uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, id := range ids {
+		if u, ok := s.urls[id]; ok && u.WorkspaceID == workspaceID && !u.DeletedAt.Valid {
+			u.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+		}
+	}
+	return nil
+}
+
+func (s *MemoryURLStore) Update(_ context.Context, workspaceID, id uint, notes *string, tags *[]string) (model.URL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.urls[id]
+	if !ok || u.WorkspaceID != workspaceID || u.DeletedAt.Valid {
+		return model.URL{}, ErrNotFound
+	}
+	if notes != nil {
+		u.Notes = *notes
+	}
+	if tags != nil {
+		u.Tags = make([]model.Tag, 0, len(*tags))
+		for _, name := range *tags {
+			u.Tags = append(u.Tags, s.ensureTag(workspaceID, name))
+		}
+	}
+	u.UpdatedAt = time.Now()
+	c := copyURL(u)
+	c.Links = nil
+	return c, nil
+}
+
+func (s *MemoryURLStore) Trash(_ context.Context, workspaceID uint) ([]model.URL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	urls := []model.URL{}
+	for _, u := range s.sorted() {
+		if u.WorkspaceID == workspaceID && u.DeletedAt.Valid {
+			c := copyURL(u)
+			c.Tags, c.Links = nil, nil
+			urls = append(urls, c)
+		}
+	}
+	sort.SliceStable(urls, func(i, j int) bool { return urls[i].DeletedAt.Time.After(urls[j].DeletedAt.Time) })
+	return urls, nil
+}
+
+func (s *MemoryURLStore) Restore(_ context.Context, workspaceID uint, ids []uint) ([]uint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	restored := []uint{}
+	for _, u := range s.sorted() {
+		if u.WorkspaceID == workspaceID && u.DeletedAt.Valid && slices.Contains(ids, u.ID) {
+			u.DeletedAt = gorm.DeletedAt{}
+			restored = append(restored, u.ID)
+		}
+	}
+	return restored, nil
+}
+
+func (s *MemoryURLStore) FindByHashes(_ context.Context, workspaceID uint, hashes []string) (map[string]model.URL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := map[string]model.URL{}
+	for _, u := range s.sorted() {
+		if u.WorkspaceID == workspaceID && u.URLHash != nil && slices.Contains(hashes, *u.URLHash) {
+			found[*u.URLHash] = copyURL(u)
+		}
+	}
+	return found, nil
+}
+
+func (s *MemoryURLStore) CreateAll(_ context.Context, urls []model.URL, tags [][]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range urls {
+		if s.exists(&urls[i]) || slices.ContainsFunc(urls[:i], func(other model.URL) bool {
+			return other.WorkspaceID == urls[i].WorkspaceID && other.URLHash != nil && urls[i].URLHash != nil && *other.URLHash == *urls[i].URLHash
+		}) {
+			return errDuplicate
+		}
+	}
+	for i := range urls {
+		s.create(&urls[i], tags[i])
+	}
+	return nil
+}
+
+func (s *MemoryURLStore) Each(_ context.Context, filter URLFilter, withLinks bool, fn func([]model.URL) error) error {
+	s.mu.Lock()
+	urls := []model.URL{}
+	for _, u := range s.sorted() {
+		if !u.DeletedAt.Valid && matches(u, filter) {
+			c := copyURL(u)
+			if !withLinks {
+				c.Links = nil
+			}
+			urls = append(urls, c)
+		}
+	}
+	s.mu.Unlock()
+
+	for start := 0; start < len(urls); start += eachBatchSize {
+		if err := fn(urls[start:min(start+eachBatchSize, len(urls))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sorted returns the stored URLs ordered by ID.
+func (s *MemoryURLStore) sorted() []*model.URL {
+	urls := make([]*model.URL, 0, len(s.urls))
+	for _, u := range s.urls {
+		urls = append(urls, u)
+	}
+	sort.Slice(urls, func(i, j int) bool { return urls[i].ID < urls[j].ID })
+	return urls
+}
+
+func matches(u *model.URL, f URLFilter) bool {
+	if u.WorkspaceID != f.WorkspaceID {
+		return false
+	}
+	if len(f.IDs) > 0 && !slices.Contains(f.IDs, u.ID) {
+		return false
+	}
+	if len(f.Statuses) > 0 && !slices.Contains(f.Statuses, u.Status) {
+		return false
+	}
+	if len(f.Tags) > 0 && !slices.ContainsFunc(u.Tags, func(t model.Tag) bool { return slices.Contains(f.Tags, t.Name) }) {
+		return false
+	}
+	if f.Query != "" {
+		q := strings.ToLower(f.Query)
+		if !strings.Contains(strings.ToLower(u.URL), q) && !strings.Contains(strings.ToLower(u.PageTitle), q) {
+			return false
+		}
+	}
+	if f.HasBrokenLinks != nil && (u.BrokenLinks > 0) != *f.HasBrokenLinks {
+		return false
+	}
+	if f.HasLoginForm != nil && u.HasLoginForm != *f.HasLoginForm {
+		return false
+	}
+	return true
+}
+
+// copyURL copies u so callers cannot change the stored record.
+func copyURL(u *model.URL) model.URL {
+	c := *u
+	c.Tags = slices.Clone(u.Tags)
+	c.Links = slices.Clone(u.Links)
+	return c
+}
+
+// MemoryUserStore keeps users in memory, with unique usernames.
+type MemoryUserStore struct {
+	mu     sync.Mutex
+	users  map[uint]model.User
+	nextID uint
+}
+
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{users: map[uint]model.User{}}
+}
+
+func (s *MemoryUserStore) Get(_ context.Context, id uint) (model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return model.User{}, ErrNotFound
+	}
+	return u, nil
+}
+
+func (s *MemoryUserStore) ByUsername(_ context.Context, username string) (model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Username == username {
+			return u, nil
+		}
+	}
+	return model.User{}, ErrNotFound
+}
+
+func (s *MemoryUserStore) Create(_ context.Context, u *model.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.users {
+		if existing.Username == u.Username {
+			return errDuplicate
+		}
+	}
+	s.nextID++
+	now := time.Now()
+	u.ID = s.nextID
+	u.CreatedAt, u.UpdatedAt = now, now
+	s.users[u.ID] = *u
+	return nil
+}
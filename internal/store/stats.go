@@ -0,0 +1,21 @@
+package store
+
+import (
+	"context"
+
+	"url-crawler-backend/internal/stats"
+
+	"gorm.io/gorm"
+)
+
+type GormStatsStore struct {
+	db *gorm.DB
+}
+
+func NewGormStatsStore(conn *gorm.DB) *GormStatsStore {
+	return &GormStatsStore{db: conn}
+}
+
+func (s *GormStatsStore) Compute(ctx context.Context, workspaceID uint, opts stats.Options) (*stats.Stats, error) {
+	return stats.Compute(s.db.WithContext(ctx), workspaceID, opts)
+}
@@ -0,0 +1,210 @@
+// Package store defines the repositories the API reads and writes its data
+// through, with GORM implementations for the server. URLs and users also
+// have in-memory ones for tests and tools that run without a database.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"url-crawler-backend/internal/audit"
+	"url-crawler-backend/internal/model"
+	"url-crawler-backend/internal/stats"
+)
+
+// ErrNotFound is returned when the requested record does not exist, or is
+// outside the given workspace.
+var ErrNotFound = errors.New("record not found")
+
+// ErrConflict is returned when a record would duplicate one that exists.
+var ErrConflict = errors.New("record already exists")
+
+// URLFilter narrows a workspace's URLs. Zero fields match everything.
+type URLFilter struct {
+	WorkspaceID uint
+	// IDs matches any of the listed URLs.
+	IDs []uint
+	// Statuses matches any of the listed statuses.
+	Statuses []string
+	// Tags matches URLs carrying any of the named tags.
+	Tags []string
+	// Query matches URLs whose address or page title contains it, ignoring
+	// case.
+	Query          string
+	HasBrokenLinks *bool
+	HasLoginForm   *bool
+}
+
+// URLStore keeps the URLs of every workspace. URLs in the trash are only
+// visible to FindByHash.
+type URLStore interface {
+	// Create stores u, attaching the named tags and creating those the
+	// workspace does not have yet.
+	Create(ctx context.Context, u *model.URL, tags []string) error
+	Get(ctx context.Context, workspaceID, id uint) (model.URL, error)
+	// FindByHash finds a URL by the hash of its normalized form, including
+	// URLs in the trash.
+	FindByHash(ctx context.Context, workspaceID uint, hash string) (model.URL, error)
+	// List returns the matching URLs with their tags, ordered by ID.
+	List(ctx context.Context, filter URLFilter) ([]model.URL, error)
+	// IDs returns the IDs of the matching URLs in order, without loading
+	// the URLs.
+	IDs(ctx context.Context, filter URLFilter) ([]uint, error)
+	// Interrupted returns the URLs of every workspace whose crawl was
+	// started but never finished, ordered by ID.
+	Interrupted(ctx context.Context) ([]model.URL, error)
+	// BeginCrawl sets the status of a URL whose crawl has started and marks
+	// the crawl pending until SaveCrawlResult records its result.
+	BeginCrawl(ctx context.Context, id uint, status string) error
+	SetStatus(ctx context.Context, id uint, status string) error
+	// SaveCrawlResult writes the crawl result fields of u, clears
+	// CrawlPending and replaces its links. Nothing is written if the URL was
+	// moved to the trash.
+	SaveCrawlResult(ctx context.Context, u *model.URL) error
+	// Update changes the notes of a workspace's URL when notes is not nil
+	// and replaces its tags when tags is not nil, creating those the
+	// workspace does not have yet. It returns the URL with its tags.
+	Update(ctx context.Context, workspaceID, id uint, notes *string, tags *[]string) (model.URL, error)
+	// Delete moves the workspace's URLs with the given IDs to the trash.
+	Delete(ctx context.Context, workspaceID uint, ids []uint) error
+	// Trash returns the workspace's URLs in the trash, most recently deleted
+	// first.
+	Trash(ctx context.Context, workspaceID uint) ([]model.URL, error)
+	// Restore takes the workspace's URLs with the given IDs out of the trash
+	// and returns the IDs of those that were in it.
+	Restore(ctx context.Context, workspaceID uint, ids []uint) ([]uint, error)
+
+	// FindByHashes is FindByHash for many hashes at once. The URLs found
+	// are keyed by hash.
+	FindByHashes(ctx context.Context, workspaceID uint, hashes []string) (map[string]model.URL, error)
+	// CreateAll stores every URL with the tags named at the same index of
+	// tags, all or none.
+	CreateAll(ctx context.Context, urls []model.URL, tags [][]string) error
+	// Each calls fn with the matching URLs and their tags in batches ordered
+	// by ID, loading their links too when withLinks is set. It stops at the
+	// first error fn returns.
+	Each(ctx context.Context, filter URLFilter, withLinks bool, fn func([]model.URL) error) error
+}
+
+// UserStore keeps user accounts.
+type UserStore interface {
+	Get(ctx context.Context, id uint) (model.User, error)
+	ByUsername(ctx context.Context, username string) (model.User, error)
+	Create(ctx context.Context, u *model.User) error
+}
+
+// WorkspaceStore keeps workspaces and their members.
+type WorkspaceStore interface {
+	// Create stores w and makes ownerID its owner. It returns the owner's
+	// membership with the workspace.
+	Create(ctx context.Context, w *model.Workspace, ownerID uint) (model.Membership, error)
+	// Membership returns the user's membership in the workspace, with the
+	// workspace.
+	Membership(ctx context.Context, workspaceID, userID uint) (model.Membership, error)
+	// FirstMembership returns the user's oldest membership, with its
+	// workspace.
+	FirstMembership(ctx context.Context, userID uint) (model.Membership, error)
+	// Memberships returns the user's memberships with their workspaces,
+	// oldest first.
+	Memberships(ctx context.Context, userID uint) ([]model.Membership, error)
+	// Member returns the user's membership in the workspace alone.
+	Member(ctx context.Context, workspaceID, userID uint) (model.Membership, error)
+	// Members returns the workspace's memberships with their users, oldest
+	// first.
+	Members(ctx context.Context, workspaceID uint) ([]model.Membership, error)
+	// AddMember stores m, or returns ErrConflict if the user is already a
+	// member.
+	AddMember(ctx context.Context, m *model.Membership) error
+	SetRole(ctx context.Context, m *model.Membership, role string) error
+	RemoveMember(ctx context.Context, m model.Membership) error
+	// OtherOwners counts the owners of the workspace besides userID.
+	OtherOwners(ctx context.Context, workspaceID, userID uint) (int64, error)
+}
+
+// TagStore keeps the tags of every workspace and which URLs carry them.
+type TagStore interface {
+	// List returns the workspace's tags ordered by name.
+	List(ctx context.Context, workspaceID uint) ([]model.Tag, error)
+	Get(ctx context.Context, workspaceID, id uint) (model.Tag, error)
+	// Create stores tag, or returns ErrConflict if the workspace has a tag
+	// of that name.
+	Create(ctx context.Context, tag *model.Tag) error
+	// Rename renames tag, or returns ErrConflict if the workspace has a tag
+	// of that name.
+	Rename(ctx context.Context, tag *model.Tag, name string) error
+	// Delete removes the tag from every URL and deletes it.
+	Delete(ctx context.Context, tag model.Tag) error
+	// AddToURLs adds the named tags to the workspace's URLs with the given
+	// IDs, creating the tags the workspace does not have yet. It returns
+	// the IDs of the URLs found.
+	AddToURLs(ctx context.Context, workspaceID uint, ids []uint, names []string) ([]uint, error)
+	// RemoveFromURLs removes the named tags from the workspace's URLs with
+	// the given IDs and returns the IDs of the URLs found. The tags
+	// themselves are kept.
+	RemoveFromURLs(ctx context.Context, workspaceID uint, ids []uint, names []string) ([]uint, error)
+}
+
+// TwoFactorStore keeps the TOTP secrets, recovery codes and wrong-code
+// counts of users. Recovery codes are stored as hashes.
+type TwoFactorStore interface {
+	// SetSecret stores a secret that is not enforced until Enable.
+	SetSecret(ctx context.Context, userID uint, secret string) error
+	// Enable turns on 2FA with step as the last TOTP step used, and replaces
+	// the user's recovery codes.
+	Enable(ctx context.Context, userID uint, step int64, codeHashes []string) error
+	ReplaceRecoveryCodes(ctx context.Context, userID uint, codeHashes []string) error
+	// Disable turns off 2FA and deletes the secret and recovery codes.
+	Disable(ctx context.Context, userID uint) error
+	// UseStep records step as the last TOTP step used. It reports false if
+	// it is not newer than the last one.
+	UseStep(ctx context.Context, userID uint, step int64) (bool, error)
+	// UseRecoveryCode marks the unused recovery code with the hash as used.
+	// It reports false if there is none.
+	UseRecoveryCode(ctx context.Context, userID uint, codeHash string) (bool, error)
+	// ReserveAttempt counts an attempt before the code is checked. It
+	// reports false if the user has made max attempts, or is locked out at
+	// now.
+	ReserveAttempt(ctx context.Context, userID uint, max int, now time.Time) (bool, error)
+	ResetAttempts(ctx context.Context, userID uint) error
+	// Lock locks a user who has made max attempts out until the given time,
+	// and starts counting from zero again.
+	Lock(ctx context.Context, userID uint, max int, until time.Time) error
+}
+
+// IdentityStore links users to their identities at OIDC providers.
+type IdentityStore interface {
+	// User returns the user linked to the subject at the issuer.
+	User(ctx context.Context, issuer, subject string) (model.User, error)
+	// Provision creates u and links identity to it.
+	Provision(ctx context.Context, u *model.User, identity model.UserIdentity) error
+}
+
+// AuditStore keeps the audit log.
+type AuditStore interface {
+	Record(ctx context.Context, e audit.Entry) error
+	// Query returns the matching entries, newest first, and how many match
+	// in total.
+	Query(ctx context.Context, f audit.Filter) ([]model.AuditLog, int64, error)
+}
+
+// StatsStore computes the statistics of a workspace's URLs.
+type StatsStore interface {
+	Compute(ctx context.Context, workspaceID uint, opts stats.Options) (*stats.Stats, error)
+}
+
+// IdempotencyStore keeps the responses to requests with an
+// Idempotency-Key, as described in package idempotency.
+type IdempotencyStore interface {
+	Acquire(ctx context.Context, userID uint, key, fingerprint string, ttl time.Duration) (*model.IdempotencyKey, error)
+	Complete(ctx context.Context, record *model.IdempotencyKey, status int, contentType string, body []byte) error
+	Release(ctx context.Context, record *model.IdempotencyKey) error
+}
+
+// Health checks the database the stores use.
+type Health interface {
+	Ping(ctx context.Context) error
+	// CheckMigrations returns an error unless every migration has been
+	// applied.
+	CheckMigrations(ctx context.Context) error
+}
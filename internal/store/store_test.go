@@ -0,0 +1,212 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"url-crawler-backend/internal/model"
+	"url-crawler-backend/internal/urlnorm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := conn.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	require.NoError(t, conn.AutoMigrate(&model.URL{}, &model.Link{}, &model.Tag{}, &model.User{}))
+	return conn
+}
+
+// Both implementations must pass the same tests, so the in-memory store
+// can stand in for the database.
+func TestURLStore(t *testing.T) {
+	stores := map[string]func(t *testing.T) URLStore{
+		"gorm":   func(t *testing.T) URLStore { return NewGormURLStore(openTestDB(t)) },
+		"memory": func(t *testing.T) URLStore { return NewMemoryURLStore() },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			testURLStore(t, newStore(t))
+		})
+	}
+}
+
+func newURL(workspaceID uint, raw string) *model.URL {
+	normalized, _ := urlnorm.Normalize(raw)
+	hash := urlnorm.Hash(normalized)
+	return &model.URL{WorkspaceID: workspaceID, URL: raw, NormalizedURL: normalized, URLHash: &hash, Status: "queued"}
+}
+
+func testURLStore(t *testing.T, s URLStore) {
+	ctx := context.Background()
+
+	docs := newURL(1, "https://Example.com/docs")
+	require.NoError(t, s.Create(ctx, docs, []string{"docs", "prod"}))
+	require.NotZero(t, docs.ID)
+	assert.Equal(t, "example.com", docs.Host)
+	require.Len(t, docs.Tags, 2)
+
+	blog := newURL(1, "https://blog.example.com")
+	require.NoError(t, s.Create(ctx, blog, []string{"prod"}))
+	assert.Equal(t, docs.Tags[1].ID, blog.Tags[0].ID)
+	other := newURL(2, "https://example.com/docs")
+	require.NoError(t, s.Create(ctx, other, nil))
+
+	assert.Error(t, s.Create(ctx, newURL(1, "https://example.com/docs"), nil))
+
+	got, err := s.Get(ctx, 1, docs.ID)
+	require.NoError(t, err)
+	assert.Equal(t, docs.URL, got.URL)
+	_, err = s.Get(ctx, 2, docs.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	list := func(f URLFilter) []uint {
+		urls, err := s.List(ctx, f)
+		require.NoError(t, err)
+		ids := []uint{}
+		for _, u := range urls {
+			ids = append(ids, u.ID)
+		}
+		return ids
+	}
+	assert.Equal(t, []uint{docs.ID, blog.ID}, list(URLFilter{WorkspaceID: 1}))
+	assert.Equal(t, []uint{docs.ID}, list(URLFilter{WorkspaceID: 1, Tags: []string{"docs", "missing"}}))
+	assert.Equal(t, []uint{blog.ID}, list(URLFilter{WorkspaceID: 1, Query: "BLOG"}))
+	assert.Equal(t, []uint{blog.ID}, list(URLFilter{WorkspaceID: 1, IDs: []uint{blog.ID, other.ID}}))
+
+	ids, err := s.IDs(ctx, URLFilter{WorkspaceID: 1, Tags: []string{"prod"}})
+	require.NoError(t, err)
+	assert.Equal(t, []uint{docs.ID, blog.ID}, ids)
+
+	crawled := *blog
+	now := time.Now()
+	crawled.Status = "done"
+	crawled.PageTitle = "Blog"
+	crawled.BrokenLinks = 1
+	crawled.CrawledAt = &now
+	crawled.Links = []model.Link{{Href: "https://example.com/missing", StatusCode: 404, Broken: true}}
+	require.NoError(t, s.SaveCrawlResult(ctx, &crawled))
+
+	yes := true
+	assert.Equal(t, []uint{blog.ID}, list(URLFilter{WorkspaceID: 1, HasBrokenLinks: &yes}))
+	assert.Equal(t, []uint{blog.ID}, list(URLFilter{WorkspaceID: 1, Statuses: []string{"done", "error"}}))
+	got, err = s.Get(ctx, 1, blog.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Blog", got.PageTitle)
+
+	require.NoError(t, s.SetStatus(ctx, docs.ID, "running"))
+	got, err = s.Get(ctx, 1, docs.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "running", got.Status)
+
+	// Only crawls that were started and have not finished are interrupted,
+	// not URLs that are merely queued.
+	require.NoError(t, s.BeginCrawl(ctx, docs.ID, "running"))
+	require.NoError(t, s.BeginCrawl(ctx, other.ID, "queued"))
+	require.NoError(t, s.BeginCrawl(ctx, blog.ID, "running"))
+	finished := crawled
+	finished.Status = "done"
+	require.NoError(t, s.SaveCrawlResult(ctx, &finished))
+	interrupted, err := s.Interrupted(ctx)
+	require.NoError(t, err)
+	require.Len(t, interrupted, 2)
+	assert.Equal(t, docs.ID, interrupted[0].ID)
+	assert.Equal(t, other.ID, interrupted[1].ID)
+
+	require.NoError(t, s.Delete(ctx, 1, []uint{docs.ID}))
+	_, err = s.Get(ctx, 1, docs.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	trashed, err := s.FindByHash(ctx, 1, *docs.URLHash)
+	require.NoError(t, err)
+	assert.True(t, trashed.DeletedAt.Valid)
+	assert.Equal(t, []uint{blog.ID}, list(URLFilter{WorkspaceID: 1}))
+
+	// A crawl finishing after the URL was trashed writes nothing.
+	late := *docs
+	late.Status = "done"
+	require.NoError(t, s.SaveCrawlResult(ctx, &late))
+	trashed, err = s.FindByHash(ctx, 1, *docs.URLHash)
+	require.NoError(t, err)
+	assert.Equal(t, "running", trashed.Status)
+
+	inTrash, err := s.Trash(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, inTrash, 1)
+	assert.Equal(t, docs.ID, inTrash[0].ID)
+	restored, err := s.Restore(ctx, 1, []uint{docs.ID, blog.ID, other.ID})
+	require.NoError(t, err)
+	assert.Equal(t, []uint{docs.ID}, restored)
+	assert.Equal(t, []uint{docs.ID, blog.ID}, list(URLFilter{WorkspaceID: 1}))
+
+	notes := "Checked"
+	updated, err := s.Update(ctx, 1, blog.ID, &notes, &[]string{"docs"})
+	require.NoError(t, err)
+	assert.Equal(t, "Checked", updated.Notes)
+	require.Len(t, updated.Tags, 1)
+	assert.Equal(t, "docs", updated.Tags[0].Name)
+	_, err = s.Update(ctx, 2, blog.ID, &notes, nil)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// CreateAll adds nothing if any of the URLs exists.
+	imported := []model.URL{*newURL(1, "https://example.com/a"), *newURL(1, "https://blog.example.com")}
+	assert.Error(t, s.CreateAll(ctx, imported, [][]string{{"new"}, nil}))
+	imported = []model.URL{*newURL(1, "https://example.com/a"), *newURL(1, "https://example.com/b")}
+	require.NoError(t, s.CreateAll(ctx, imported, [][]string{{"new"}, {"new", "docs"}}))
+	require.Len(t, imported[1].Tags, 2)
+	assert.Equal(t, imported[0].Tags[0].ID, imported[1].Tags[0].ID)
+
+	found, err := s.FindByHashes(ctx, 1, []string{*docs.URLHash, *imported[1].URLHash, "missing"})
+	require.NoError(t, err)
+	require.Len(t, found, 2)
+	assert.Equal(t, imported[1].ID, found[*imported[1].URLHash].ID)
+
+	var batches [][]model.URL
+	require.NoError(t, s.Each(ctx, URLFilter{WorkspaceID: 1, Tags: []string{"docs"}}, true, func(batch []model.URL) error {
+		batches = append(batches, batch)
+		return nil
+	}))
+	require.Len(t, batches, 1)
+	require.Len(t, batches[0], 3)
+	assert.Equal(t, []uint{docs.ID, blog.ID, imported[1].ID}, []uint{batches[0][0].ID, batches[0][1].ID, batches[0][2].ID})
+	assert.Len(t, batches[0][1].Links, 1)
+}
+
+func TestUserStore(t *testing.T) {
+	stores := map[string]func(t *testing.T) UserStore{
+		"gorm":   func(t *testing.T) UserStore { return NewGormUserStore(openTestDB(t)) },
+		"memory": func(t *testing.T) UserStore { return NewMemoryUserStore() },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			s := newStore(t)
+			ctx := context.Background()
+
+			alice := model.User{Username: "alice", Password: "hash"}
+			require.NoError(t, s.Create(ctx, &alice))
+			require.NotZero(t, alice.ID)
+			assert.Error(t, s.Create(ctx, &model.User{Username: "alice"}))
+
+			got, err := s.Get(ctx, alice.ID)
+			require.NoError(t, err)
+			assert.Equal(t, "alice", got.Username)
+			got, err = s.ByUsername(ctx, "alice")
+			require.NoError(t, err)
+			assert.Equal(t, alice.ID, got.ID)
+
+			_, err = s.ByUsername(ctx, "bob")
+			assert.ErrorIs(t, err, ErrNotFound)
+			_, err = s.Get(ctx, alice.ID+1)
+			assert.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"url-crawler-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// urlTag is a row of the url_tags join table behind URL.Tags.
+type urlTag struct {
+	URLID uint
+	TagID uint
+}
+
+type GormTagStore struct {
+	db *gorm.DB
+}
+
+func NewGormTagStore(conn *gorm.DB) *GormTagStore {
+	return &GormTagStore{db: conn}
+}
+
+func (s *GormTagStore) List(ctx context.Context, workspaceID uint) ([]model.Tag, error) {
+	var tags []model.Tag
+	err := s.db.WithContext(ctx).Where("workspace_id = ?", workspaceID).Order("name").Find(&tags).Error
+	return tags, err
+}
+
+func (s *GormTagStore) Get(ctx context.Context, workspaceID, id uint) (model.Tag, error) {
+	var tag model.Tag
+	err := s.db.WithContext(ctx).Where("workspace_id = ?", workspaceID).First(&tag, id).Error
+	return tag, notFound(err)
+}
+
+func (s *GormTagStore) Create(ctx context.Context, tag *model.Tag) error {
+	if err := s.checkNameFree(ctx, tag.WorkspaceID, tag.Name); err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Create(tag).Error
+}
+
+func (s *GormTagStore) Rename(ctx context.Context, tag *model.Tag, name string) error {
+	if err := s.checkNameFree(ctx, tag.WorkspaceID, name); err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Model(tag).Update("name", name).Error
+}
+
+func (s *GormTagStore) checkNameFree(ctx context.Context, workspaceID uint, name string) error {
+	err := s.db.WithContext(ctx).Where("workspace_id = ? AND name = ?", workspaceID, name).First(&model.Tag{}).Error
+	switch {
+	case err == nil:
+		return ErrConflict
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil
+	default:
+		return err
+	}
+}
+
+func (s *GormTagStore) Delete(ctx context.Context, tag model.Tag) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM url_tags WHERE tag_id = ?", tag.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&tag).Error
+	})
+}
+
+func (s *GormTagStore) AddToURLs(ctx context.Context, workspaceID uint, ids []uint, names []string) ([]uint, error) {
+	var found []uint
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if found, err = urlsInWorkspace(tx, workspaceID, ids); err != nil || len(found) == 0 {
+			return err
+		}
+		tags, err := ensureTags(tx, workspaceID, names)
+		if err != nil {
+			return err
+		}
+		return addURLTags(tx, found, tags)
+	})
+	return found, err
+}
+
+func (s *GormTagStore) RemoveFromURLs(ctx context.Context, workspaceID uint, ids []uint, names []string) ([]uint, error) {
+	var found []uint
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if found, err = urlsInWorkspace(tx, workspaceID, ids); err != nil || len(found) == 0 {
+			return err
+		}
+		return tx.Exec(
+			"DELETE FROM url_tags WHERE url_id IN ? AND tag_id IN (SELECT id FROM tags WHERE workspace_id = ? AND name IN ?)",
+			found, workspaceID, names,
+		).Error
+	})
+	return found, err
+}
+
+// urlsInWorkspace returns those of ids that belong to URLs in the
+// workspace.
+func urlsInWorkspace(tx *gorm.DB, workspaceID uint, ids []uint) ([]uint, error) {
+	var found []uint
+	err := tx.Model(&model.URL{}).Where("workspace_id = ? AND id IN ?", workspaceID, ids).Pluck("id", &found).Error
+	return found, err
+}
+
+// addURLTags links every URL to every tag, skipping pairs that already
+// exist.
+func addURLTags(tx *gorm.DB, urlIDs []uint, tags []model.Tag) error {
+	if len(urlIDs) == 0 || len(tags) == 0 {
+		return nil
+	}
+
+	tagIDs := make([]uint, len(tags))
+	for i, tag := range tags {
+		tagIDs[i] = tag.ID
+	}
+
+	var existing []urlTag
+	if err := tx.Table("url_tags").Where("url_id IN ? AND tag_id IN ?", urlIDs, tagIDs).Find(&existing).Error; err != nil {
+		return err
+	}
+	linked := make(map[urlTag]bool, len(existing))
+	for _, pair := range existing {
+		linked[pair] = true
+	}
+
+	var rows []urlTag
+	for _, urlID := range urlIDs {
+		for _, tagID := range tagIDs {
+			pair := urlTag{URLID: urlID, TagID: tagID}
+			if !linked[pair] {
+				rows = append(rows, pair)
+			}
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.Table("url_tags").CreateInBatches(&rows, 500).Error
+}
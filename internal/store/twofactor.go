@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"url-crawler-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type GormTwoFactorStore struct {
+	db *gorm.DB
+}
+
+func NewGormTwoFactorStore(conn *gorm.DB) *GormTwoFactorStore {
+	return &GormTwoFactorStore{db: conn}
+}
+
+func (s *GormTwoFactorStore) SetSecret(ctx context.Context, userID uint, secret string) error {
+	return s.db.WithContext(ctx).Model(&model.User{}).Where("id = ?", userID).Update("totp_secret", secret).Error
+}
+
+func (s *GormTwoFactorStore) Enable(ctx context.Context, userID uint, step int64, codeHashes []string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		}).Error; err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+func (s *GormTwoFactorStore) ReplaceRecoveryCodes(ctx context.Context, userID uint, codeHashes []string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, codeHashes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	records := make([]model.RecoveryCode, len(codeHashes))
+	for i, hash := range codeHashes {
+		records[i] = model.RecoveryCode{UserID: userID, CodeHash: hash}
+	}
+	return tx.Create(&records).Error
+}
+
+func (s *GormTwoFactorStore) Disable(ctx context.Context, userID uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"totp_enabled":   false,
+			"totp_secret":    "",
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error
+	})
+}
+
+func (s *GormTwoFactorStore) UseStep(ctx context.Context, userID uint, step int64) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (s *GormTwoFactorStore) UseRecoveryCode(ctx context.Context, userID uint, codeHash string) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+func (s *GormTwoFactorStore) ReserveAttempt(ctx context.Context, userID uint, max int, now time.Time) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ? AND two_factor_failures < ?", userID, max).
+		Where("two_factor_locked_until IS NULL OR two_factor_locked_until <= ?", now).
+		Update("two_factor_failures", gorm.Expr("two_factor_failures + 1"))
+	return result.RowsAffected > 0, result.Error
+}
+
+func (s *GormTwoFactorStore) ResetAttempts(ctx context.Context, userID uint) error {
+	return s.db.WithContext(ctx).Model(&model.User{}).Where("id = ?", userID).Update("two_factor_failures", 0).Error
+}
+
+func (s *GormTwoFactorStore) Lock(ctx context.Context, userID uint, max int, until time.Time) error {
+	return s.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ? AND two_factor_failures >= ?", userID, max).
+		Updates(map[string]interface{}{
+			"two_factor_failures":     0,
+			"two_factor_locked_until": until,
+		}).Error
+}
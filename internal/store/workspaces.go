@@ -0,0 +1,97 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"url-crawler-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type GormWorkspaceStore struct {
+	db *gorm.DB
+}
+
+func NewGormWorkspaceStore(conn *gorm.DB) *GormWorkspaceStore {
+	return &GormWorkspaceStore{db: conn}
+}
+
+func (s *GormWorkspaceStore) Create(ctx context.Context, w *model.Workspace, ownerID uint) (model.Membership, error) {
+	var m model.Membership
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(w).Error; err != nil {
+			return err
+		}
+		m = model.Membership{WorkspaceID: w.ID, UserID: ownerID, Role: model.RoleOwner}
+		if err := tx.Create(&m).Error; err != nil {
+			return err
+		}
+		m.Workspace = w
+		return nil
+	})
+	return m, err
+}
+
+func (s *GormWorkspaceStore) Membership(ctx context.Context, workspaceID, userID uint) (model.Membership, error) {
+	var m model.Membership
+	err := s.db.WithContext(ctx).Preload("Workspace").
+		Where("workspace_id = ? AND user_id = ?", workspaceID, userID).
+		First(&m).Error
+	return m, notFound(err)
+}
+
+func (s *GormWorkspaceStore) FirstMembership(ctx context.Context, userID uint) (model.Membership, error) {
+	var m model.Membership
+	err := s.db.WithContext(ctx).Preload("Workspace").Where("user_id = ?", userID).Order("id").First(&m).Error
+	return m, notFound(err)
+}
+
+func (s *GormWorkspaceStore) Memberships(ctx context.Context, userID uint) ([]model.Membership, error) {
+	var memberships []model.Membership
+	err := s.db.WithContext(ctx).Preload("Workspace").Where("user_id = ?", userID).Order("id").Find(&memberships).Error
+	return memberships, err
+}
+
+func (s *GormWorkspaceStore) Member(ctx context.Context, workspaceID, userID uint) (model.Membership, error) {
+	var m model.Membership
+	err := s.db.WithContext(ctx).Where("workspace_id = ? AND user_id = ?", workspaceID, userID).First(&m).Error
+	return m, notFound(err)
+}
+
+func (s *GormWorkspaceStore) Members(ctx context.Context, workspaceID uint) ([]model.Membership, error) {
+	var members []model.Membership
+	err := s.db.WithContext(ctx).Preload("User").Where("workspace_id = ?", workspaceID).Order("id").Find(&members).Error
+	return members, err
+}
+
+func (s *GormWorkspaceStore) AddMember(ctx context.Context, m *model.Membership) error {
+	_, err := s.Member(ctx, m.WorkspaceID, m.UserID)
+	if err == nil {
+		return ErrConflict
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return s.db.WithContext(ctx).Create(m).Error
+}
+
+func (s *GormWorkspaceStore) SetRole(ctx context.Context, m *model.Membership, role string) error {
+	if err := s.db.WithContext(ctx).Model(m).Update("role", role).Error; err != nil {
+		return err
+	}
+	m.Role = role
+	return nil
+}
+
+func (s *GormWorkspaceStore) RemoveMember(ctx context.Context, m model.Membership) error {
+	return s.db.WithContext(ctx).Delete(&m).Error
+}
+
+func (s *GormWorkspaceStore) OtherOwners(ctx context.Context, workspaceID, userID uint) (int64, error) {
+	var owners int64
+	err := s.db.WithContext(ctx).Model(&model.Membership{}).
+		Where("workspace_id = ? AND role = ? AND user_id <> ?", workspaceID, model.RoleOwner, userID).
+		Count(&owners).Error
+	return owners, err
+}
@@ -0,0 +1,37 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestSetup(t *testing.T) {
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	shutdown, err := Setup(context.Background(), Config{Exporter: ExporterNone, SampleRatio: 1})
+	require.NoError(t, err)
+	require.NoError(t, shutdown(context.Background()))
+	assert.Contains(t, otel.GetTextMapPropagator().Fields(), "traceparent")
+
+	shutdown, err = Setup(context.Background(), Config{Exporter: ExporterOTLP, Endpoint: "http://localhost:4318", SampleRatio: 0.5})
+	require.NoError(t, err)
+	_, span := otel.Tracer("test").Start(context.Background(), "test")
+	assert.True(t, span.SpanContext().IsValid())
+	span.End()
+	// Nothing is exported until the batch is flushed, so shutting down
+	// right away only fails if the collector is unreachable.
+	_ = shutdown(context.Background())
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{Exporter: ExporterStdout, SampleRatio: 0}.Validate())
+	err := Config{Exporter: "jaeger", SampleRatio: 2}.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "TRACING_EXPORTER")
+	assert.Contains(t, err.Error(), "TRACING_SAMPLE_RATIO")
+}
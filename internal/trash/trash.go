@@ -0,0 +1,79 @@
+package trash
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"url-crawler-backend/internal/audit"
+	"url-crawler-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultRetentionDays = 30
+	purgeInterval        = time.Hour
+)
+
+// Purge permanently deletes URLs that were moved to the trash before
+// cutoff and returns their IDs.
+func Purge(conn *gorm.DB, cutoff time.Time) ([]uint, error) {
+	var ids []uint
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&model.URL{}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := tx.Where("url_id IN ?", ids).Delete(&model.Link{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM url_tags WHERE url_id IN ?", ids).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&model.URL{}, ids).Error
+	})
+	return ids, err
+}
+
+// StartPurger purges the trash every hour until ctx is cancelled.
+func StartPurger(ctx context.Context, conn *gorm.DB, retention time.Duration) {
+	if retention <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(purgeInterval)
+		defer ticker.Stop()
+
+		for {
+			purgeOnce(ctx, conn, retention)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func purgeOnce(ctx context.Context, conn *gorm.DB, retention time.Duration) {
+	ids, err := Purge(conn, time.Now().Add(-retention))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to purge trash", "error", err)
+		return
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	slog.InfoContext(ctx, "Purged URLs from the trash", "count", len(ids))
+	if err := audit.Record(conn, audit.Entry{Action: "url.purge", TargetType: "url", TargetIDs: ids}); err != nil {
+		slog.ErrorContext(ctx, "Failed to write audit log entry", "action", "url.purge", "error", err)
+	}
+}
@@ -0,0 +1,45 @@
+package trash
+
+import (
+	"testing"
+	"time"
+
+	"url-crawler-backend/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestPurgeRemovesOnlyExpiredTrash(t *testing.T) {
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	conn.AutoMigrate(&model.URL{}, &model.Link{}, &model.AuditLog{})
+
+	now := time.Now()
+	active := model.URL{URL: "https://active.example"}
+	recent := model.URL{URL: "https://recent.example", DeletedAt: gorm.DeletedAt{Time: now.Add(-24 * time.Hour), Valid: true}}
+	expired := model.URL{URL: "https://expired.example", DeletedAt: gorm.DeletedAt{Time: now.Add(-40 * 24 * time.Hour), Valid: true}}
+	for _, u := range []*model.URL{&active, &recent, &expired} {
+		require.NoError(t, conn.Create(u).Error)
+	}
+
+	require.NoError(t, conn.Create(&model.Link{URLID: expired.ID, Href: "https://expired.example/a"}).Error)
+	require.NoError(t, conn.Create(&model.Link{URLID: active.ID, Href: "https://active.example/a"}).Error)
+
+	ids, err := Purge(conn, now.Add(-30*24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []uint{expired.ID}, ids)
+
+	var remaining []model.URL
+	conn.Unscoped().Order("id").Find(&remaining)
+	require.Len(t, remaining, 2)
+	assert.Equal(t, active.ID, remaining[0].ID)
+	assert.Equal(t, recent.ID, remaining[1].ID)
+
+	var links []model.Link
+	conn.Find(&links)
+	require.Len(t, links, 1)
+	assert.Equal(t, active.ID, links[0].URLID)
+}
@@ -0,0 +1,114 @@
+// Package urlnorm reduces URLs to a canonical form so that addresses which
+// point at the same page compare equal.
+package urlnorm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// trackingParams are query parameters that identify a campaign or click
+// rather than content. Parameters starting with "utm_" are dropped too.
+var trackingParams = map[string]bool{
+	"gclid":   true,
+	"dclid":   true,
+	"fbclid":  true,
+	"msclkid": true,
+	"yclid":   true,
+	"igshid":  true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"_ga":     true,
+	"_gl":     true,
+}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Normalize returns the canonical form of an absolute http or https URL:
+//
+//   - scheme and host are lower-cased and internationalized host names are
+//     converted to punycode
+//   - the default port for the scheme is removed
+//   - dot segments and trailing slashes are removed from the path, and an
+//     empty path becomes "/"
+//   - tracking parameters are removed and the remaining query parameters
+//     are sorted
+//   - the fragment is removed
+func Normalize(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+
+	host, port := u.Hostname(), u.Port()
+	if host == "" {
+		return "", fmt.Errorf("missing host in %q", raw)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		host = ip.String()
+	} else if host, err = idna.Lookup.ToASCII(strings.TrimSuffix(host, ".")); err != nil {
+		return "", fmt.Errorf("invalid host: %w", err)
+	}
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	switch {
+	case port != "":
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		u.Host = "[" + host + "]"
+	default:
+		u.Host = host
+	}
+
+	u.Path = cleanPath(u.Path)
+	u.RawPath = ""
+
+	query := u.Query()
+	for name := range query {
+		lower := strings.ToLower(name)
+		if trackingParams[lower] || strings.HasPrefix(lower, "utm_") {
+			query.Del(name)
+		}
+	}
+	u.RawQuery = query.Encode()
+	u.ForceQuery = false
+
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	return u.String(), nil
+}
+
+func cleanPath(p string) string {
+	if p == "" || p == "/" {
+		return "/"
+	}
+	cleaned := path.Clean(p)
+	if cleaned == "." {
+		return "/"
+	}
+	return cleaned
+}
+
+// Hash returns the hex SHA-256 of a normalized URL. It is short enough for
+// a unique index, which the URL itself is not.
+func Hash(normalized string) string {
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,64 @@
+package urlnorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		expected string
+	}{
+		{"lower-cases scheme and host", "HTTPS://Example.COM/Path", "https://example.com/Path"},
+		{"adds root path", "https://example.com", "https://example.com/"},
+		{"removes trailing slash", "https://example.com/blog/", "https://example.com/blog"},
+		{"removes dot segments", "https://example.com/a/./b/../c", "https://example.com/a/c"},
+		{"removes default http port", "http://example.com:80/", "http://example.com/"},
+		{"removes default https port", "https://example.com:443/", "https://example.com/"},
+		{"keeps other ports", "https://example.com:8443/", "https://example.com:8443/"},
+		{"removes fragment", "https://example.com/page#section", "https://example.com/page"},
+		{"strips tracking params", "https://example.com/?utm_source=x&UTM_Medium=y&gclid=1&fbclid=2", "https://example.com/"},
+		{"sorts remaining params", "https://example.com/search?q=go&page=2&utm_campaign=z", "https://example.com/search?page=2&q=go"},
+		{"drops empty query", "https://example.com/?", "https://example.com/"},
+		{"converts IDN to punycode", "https://Bücher.example/", "https://xn--bcher-kva.example/"},
+		{"removes trailing dot in host", "https://example.com./", "https://example.com/"},
+		{"keeps IPv6 host", "http://[::1]:80/", "http://[::1]/"},
+		{"keeps IPv6 host with port", "http://[::1]:8080/", "http://[::1]:8080/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.raw)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestNormalizeEquivalentURLs(t *testing.T) {
+	variants := []string{
+		"https://Example.com/",
+		"https://example.com",
+		"https://example.com/?utm_source=x",
+		"https://example.com:443/#top",
+	}
+	want, err := Normalize(variants[0])
+	require.NoError(t, err)
+	for _, v := range variants[1:] {
+		got, err := Normalize(v)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, v)
+		assert.Equal(t, Hash(want), Hash(got))
+	}
+}
+
+func TestNormalizeRejects(t *testing.T) {
+	for _, raw := range []string{"ftp://example.com/", "https://", "example.com", "https://exa mple.com/"} {
+		_, err := Normalize(raw)
+		assert.Error(t, err, raw)
+	}
+}
@@ -0,0 +1,51 @@
+// Package version reports which build of the server is running. The
+// values are set at link time:
+//
+//	go build -ldflags "-X url-crawler-backend/internal/version.Version=v1.2.0 \
+//	  -X url-crawler-backend/internal/version.Commit=$(git rev-parse HEAD) \
+//	  -X url-crawler-backend/internal/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build info. Without ldflags, the commit and build time
+// fall back to the VCS details the Go toolchain embeds in the binary.
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+	if info.Commit != "" && info.BuildTime != "" {
+		return info
+	}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.BuildTime == "" {
+				info.BuildTime = setting.Value
+			}
+		}
+	}
+	return info
+}
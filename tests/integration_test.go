@@ -8,7 +8,6 @@ import (
 	"testing"
 
 	"url-crawler-backend/internal/api"
-	"url-crawler-backend/internal/db"
 	"url-crawler-backend/internal/model"
 
 	"github.com/labstack/echo/v4"
@@ -18,6 +17,8 @@ import (
 	"gorm.io/gorm"
 )
 
+var testMembership = model.Membership{WorkspaceID: 1, UserID: 1, Role: model.RoleOwner}
+
 func setupTestEnvironment() (*echo.Echo, *gorm.DB) {
 	e := echo.New()
 
@@ -42,10 +43,7 @@ func setupTestEnvironment() (*echo.Echo, *gorm.DB) {
 func TestLoginFlow(t *testing.T) {
 	e, testDB := setupTestEnvironment()
 
-	// Temporarily replace the global DB
-	originalDB := db.DB
-	db.DB = testDB
-	defer func() { db.DB = originalDB }()
+	srv := api.NewServer(testDB)
 
 	// Test login with valid credentials
 	loginData := map[string]interface{}{
@@ -59,7 +57,7 @@ func TestLoginFlow(t *testing.T) {
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
 
-	err := api.Login(c)
+	err := srv.Login(c)
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusOK, rec.Code)
 
@@ -73,10 +71,7 @@ func TestLoginFlow(t *testing.T) {
 func TestURLManagementFlow(t *testing.T) {
 	e, testDB := setupTestEnvironment()
 
-	// Temporarily replace the global DB
-	originalDB := db.DB
-	db.DB = testDB
-	defer func() { db.DB = originalDB }()
+	srv := api.NewServer(testDB)
 
 	// Test adding a URL
 	urlData := map[string]interface{}{
@@ -88,8 +83,9 @@ func TestURLManagementFlow(t *testing.T) {
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
+	api.SetMembership(c, testMembership)
 
-	err := api.AddURL(c)
+	err := srv.AddURL(c)
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusCreated, rec.Code)
 
@@ -103,8 +99,9 @@ func TestURLManagementFlow(t *testing.T) {
 	req = httptest.NewRequest(http.MethodGet, "/api/urls", nil)
 	rec = httptest.NewRecorder()
 	c = e.NewContext(req, rec)
+	api.SetMembership(c, testMembership)
 
-	err = api.GetURLs(c)
+	err = srv.GetURLs(c)
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusOK, rec.Code)
 
@@ -118,15 +115,13 @@ func TestURLManagementFlow(t *testing.T) {
 func TestCrawlFlow(t *testing.T) {
 	e, testDB := setupTestEnvironment()
 
-	// Temporarily replace the global DB
-	originalDB := db.DB
-	db.DB = testDB
-	defer func() { db.DB = originalDB }()
+	srv := api.NewServer(testDB)
 
 	// Create a URL first
 	url := model.URL{
-		URL:    "https://example.com",
-		Status: "queued",
+		WorkspaceID: testMembership.WorkspaceID,
+		URL:         "https://example.com",
+		Status:      "queued",
 	}
 	testDB.Create(&url)
 
@@ -136,8 +131,9 @@ func TestCrawlFlow(t *testing.T) {
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
+	api.SetMembership(c, testMembership)
 
-	err := api.StartBulkCrawl(c)
+	err := srv.StartBulkCrawl(c)
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusOK, rec.Code)
 